@@ -0,0 +1,121 @@
+package driver
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"entgo.io/ent/dialect"
+	"go.uber.org/zap"
+)
+
+// ErrNotAllowed is returned for the statements rejected by an AllowlistDriver.
+// It is wrapped with the fingerprint of the statement, and can be checked
+// with errors.Is.
+var ErrNotAllowed = errors.New("entzlog: statement not allowed")
+
+// AllowlistConfig configures an AllowlistDriver.
+type AllowlistConfig struct {
+	// Fingerprints are the fingerprints of the allowed statements.
+	// See Fingerprint.
+	Fingerprints []string
+	// AuditOnly logs the statements that are not allowed without rejecting
+	// them, e.g. to collect the fingerprints of the allowlist.
+	AuditOnly bool
+	// Log is the log function. Optional.
+	Log LogFunc
+	// Metrics receives the number of statements that are not allowed. Optional.
+	Metrics Metrics
+}
+
+// AllowlistDriver is a driver that only executes the statements whose
+// fingerprint is allowed, and logs and rejects the others with ErrNotAllowed,
+// or only logs them in audit-only mode. See Fingerprint.
+type AllowlistDriver struct {
+	Driver  // underlying driver.
+	cfg     AllowlistConfig
+	allowed map[string]bool
+}
+
+// NewAllowlistDriver returns a new AllowlistDriver wrapping the given driver.
+func NewAllowlistDriver(d Driver, cfg AllowlistConfig) *AllowlistDriver {
+	if cfg.Log == nil {
+		cfg.Log = nopLog
+	}
+	if cfg.Metrics == nil {
+		cfg.Metrics = nopMetrics{}
+	}
+	allowed := make(map[string]bool, len(cfg.Fingerprints))
+	for _, f := range cfg.Fingerprints {
+		allowed[f] = true
+	}
+	return &AllowlistDriver{Driver: d, cfg: cfg, allowed: allowed}
+}
+
+// Exec calls the underlying driver Exec method if the statement is allowed.
+func (d *AllowlistDriver) Exec(ctx context.Context, query string, args, v any) error {
+	if err := d.check(ctx, "allowlist.Exec", query); err != nil {
+		return err
+	}
+	return d.Driver.Exec(ctx, query, args, v)
+}
+
+// ExecContext calls the underlying driver ExecContext method if the statement is allowed.
+func (d *AllowlistDriver) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	if err := d.check(ctx, "allowlist.ExecContext", query); err != nil {
+		return nil, err
+	}
+	return execContext(ctx, d.Driver, query, args)
+}
+
+// Query calls the underlying driver Query method if the statement is allowed.
+func (d *AllowlistDriver) Query(ctx context.Context, query string, args, v any) error {
+	if err := d.check(ctx, "allowlist.Query", query); err != nil {
+		return err
+	}
+	return d.Driver.Query(ctx, query, args, v)
+}
+
+// QueryContext calls the underlying driver QueryContext method if the statement is allowed.
+func (d *AllowlistDriver) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	if err := d.check(ctx, "allowlist.QueryContext", query); err != nil {
+		return nil, err
+	}
+	return queryContext(ctx, d.Driver, query, args)
+}
+
+// Tx starts a transaction executing the allowed statements only.
+func (d *AllowlistDriver) Tx(ctx context.Context) (dialect.Tx, error) {
+	tx, err := d.Driver.Tx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &checkedTx{Tx: tx, name: "allowlist", check: d.check}, nil
+}
+
+// BeginTx starts a transaction with options executing the allowed statements
+// only, if it is supported by the underlying driver.
+func (d *AllowlistDriver) BeginTx(ctx context.Context, opts *sql.TxOptions) (dialect.Tx, error) {
+	tx, err := beginTx(ctx, d.Driver, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &checkedTx{Tx: tx, name: "allowlist", check: d.check}, nil
+}
+
+// check returns ErrNotAllowed for the statements that are not allowed,
+// unless the driver is in audit-only mode.
+func (d *AllowlistDriver) check(ctx context.Context, op, query string) error {
+	fp := Fingerprint(query)
+	if d.allowed[fp] {
+		return nil
+	}
+	d.cfg.Metrics.Count(ctx, "entzlog_allowlist_violations_total", 1, Label{"audit_only", fmt.Sprint(d.cfg.AuditOnly)})
+	if d.cfg.AuditOnly {
+		d.cfg.Log(ctx, op+": not allowed", zap.String("fingerprint", fp), zap.String("query", query))
+		return nil
+	}
+	d.cfg.Log(ctx, op+": rejected", zap.String("fingerprint", fp), zap.String("query", query))
+	return fmt.Errorf("%w: %s", ErrNotAllowed, fp)
+}
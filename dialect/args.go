@@ -0,0 +1,191 @@
+package driver
+
+import (
+	"context"
+	"encoding/base64"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// argsField returns the field logging the arguments of a statement. The
+// arguments are encoded with their types when possible, instead of through
+// reflection.
+func argsField(args any) zap.Field {
+	return zap.Array("args", &argArray{args})
+}
+
+// WithFieldReuse returns an option that reuses the fields of the logged
+// entries once the LogFunc returns, which must then not retain them.
+func WithFieldReuse() Option {
+	return func(d *DebugDriver) {
+		d.reuseFields = true
+	}
+}
+
+// logEntry holds the state of a logged operation: the marshaler of its
+// arguments, the stringer of its table and its fields, stored inline.
+type logEntry struct {
+	args   argArray
+	table  tableStringer
+	fields []zap.Field
+	buf    [12]zap.Field
+}
+
+// logEntries pools the logEntry of the logged operations whose fields are reused.
+var logEntries = sync.Pool{New: func() any { return new(logEntry) }}
+
+// newLogEntry returns the entry of a logged operation, taken from the pool if
+// its fields are reused once logged, or else allocated, as log may retain them.
+func newLogEntry(reuse bool) *logEntry {
+	if !reuse {
+		e := new(logEntry)
+		e.fields = e.buf[:0]
+		return e
+	}
+	e := logEntries.Get().(*logEntry)
+	e.fields = e.buf[:0]
+	return e
+}
+
+// logFields logs the entry with the given fields. See write.
+func logFields(ctx context.Context, log LogFunc, reuse bool, msg string, fields ...zap.Field) {
+	e := newLogEntry(reuse)
+	e.fields = append(e.fields, fields...)
+	e.write(ctx, log, reuse, msg)
+}
+
+// logArgs logs the entry with the arguments of a statement. See write.
+func logArgs(ctx context.Context, log LogFunc, reuse bool, msg string, args any, fields ...zap.Field) {
+	e := newLogEntry(reuse)
+	e.fields = append(append(e.fields, fields...), e.argsField(args))
+	e.write(ctx, log, reuse, msg)
+}
+
+// argsField returns the field logging the arguments of the entry.
+func (e *logEntry) argsField(args any) zap.Field {
+	e.args.args = args
+	return zap.Array("args", &e.args)
+}
+
+// tableField returns the field logging the table of the query of the entry,
+// computed when the entry is encoded. See LazyString.
+func (e *logEntry) tableField(query string) zap.Field {
+	e.table.query = query
+	return zap.Stringer("table", &e.table)
+}
+
+// write logs the fields of the entry, and puts it back to the pool if they
+// are reused.
+func (e *logEntry) write(ctx context.Context, log LogFunc, reuse bool, msg string) {
+	log(ctx, msg, e.fields...)
+	if reuse {
+		*e = logEntry{}
+		logEntries.Put(e)
+	}
+}
+
+// tableStringer implements fmt.Stringer for the table of a query.
+type tableStringer struct {
+	query string
+}
+
+// String implements the fmt.Stringer interface.
+func (t *tableStringer) String() string {
+	return StatementTable(t.query)
+}
+
+// argArray implements zapcore.ArrayMarshaler for the args parameter of
+// Exec and Query, or the arguments of the context methods. The arguments
+// are encoded lazily, when the entry is written, without being copied.
+type argArray struct {
+	args any
+}
+
+// MarshalLogArray implements the zapcore.ArrayMarshaler interface.
+func (a *argArray) MarshalLogArray(enc zapcore.ArrayEncoder) error {
+	switch args := a.args.(type) {
+	case nil:
+	case []any:
+		for _, v := range args {
+			if err := appendArg(enc, v); err != nil {
+				return err
+			}
+		}
+	default:
+		return appendArg(enc, args)
+	}
+	return nil
+}
+
+// appendArg encodes the argument with its type. Byte slices that are not
+// valid UTF-8 are encoded in base64, and unknown types through reflection.
+func appendArg(enc zapcore.ArrayEncoder, v any) error {
+	switch v := v.(type) {
+	case nil:
+		enc.AppendReflected(nil)
+	case string:
+		enc.AppendString(v)
+	case int:
+		enc.AppendInt(v)
+	case int8:
+		enc.AppendInt8(v)
+	case int16:
+		enc.AppendInt16(v)
+	case int32:
+		enc.AppendInt32(v)
+	case int64:
+		enc.AppendInt64(v)
+	case uint:
+		enc.AppendUint(v)
+	case uint8:
+		enc.AppendUint8(v)
+	case uint16:
+		enc.AppendUint16(v)
+	case uint32:
+		enc.AppendUint32(v)
+	case uint64:
+		enc.AppendUint64(v)
+	case float32:
+		enc.AppendFloat32(v)
+	case float64:
+		enc.AppendFloat64(v)
+	case bool:
+		enc.AppendBool(v)
+	case time.Time:
+		enc.AppendTime(v)
+	case time.Duration:
+		enc.AppendDuration(v)
+	case []byte:
+		if utf8.Valid(v) {
+			enc.AppendByteString(v)
+		} else {
+			enc.AppendString(base64.StdEncoding.EncodeToString(v))
+		}
+	case uuid.UUID:
+		enc.AppendString(v.String())
+	default:
+		return enc.AppendReflected(v)
+	}
+	return nil
+}
+
+// LazyString returns a field whose value is computed by fn when the entry is
+// encoded, i.e. only if it was not dropped by the level, sampling or filters
+// of the logger. It defers the rendering of costly values, such as sanitized
+// or interpolated queries, until the entry is accepted.
+func LazyString(key string, fn func() string) zap.Field {
+	return zap.Stringer(key, lazyString(fn))
+}
+
+// lazyString implements fmt.Stringer for LazyString.
+type lazyString func() string
+
+// String implements the fmt.Stringer interface.
+func (s lazyString) String() string {
+	return s()
+}
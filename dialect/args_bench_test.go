@@ -0,0 +1,53 @@
+package driver
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"entgo.io/ent/dialect"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// benchDriver is a driver whose statements return immediately with err.
+type benchDriver struct {
+	err error
+}
+
+func (d benchDriver) Exec(context.Context, string, any, any) error  { return d.err }
+func (d benchDriver) Query(context.Context, string, any, any) error { return d.err }
+func (d benchDriver) Tx(context.Context) (dialect.Tx, error)        { return nil, d.err }
+func (d benchDriver) Close() error                                  { return nil }
+func (d benchDriver) Dialect() string                               { return dialect.Postgres }
+
+// ExecContext implements the ExecContext method of the SQL drivers.
+func (d benchDriver) ExecContext(context.Context, string, ...any) (sql.Result, error) {
+	return nil, d.err
+}
+
+func benchmarkExecContext(b *testing.B, err error, opts ...Option) {
+	core := zapcore.NewCore(zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()), zapcore.AddSync(io.Discard), zap.DebugLevel)
+	log, opt := Logger(zap.New(core), zap.DebugLevel)
+	drv := DebugWithContext(benchDriver{err: err}, log, append(opts, opt)...)
+	ctx := context.Background()
+	args := []any{42, "name", true, time.Unix(0, 0)}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		execContext(ctx, drv, "UPDATE users SET age = $1, name = $2, active = $3 WHERE created_at > $4", args)
+	}
+}
+
+func BenchmarkExecContext(b *testing.B) {
+	b.Run("ok", func(b *testing.B) { benchmarkExecContext(b, nil) })
+	b.Run("err", func(b *testing.B) { benchmarkExecContext(b, errors.New("boom")) })
+}
+
+func BenchmarkExecContextFieldReuse(b *testing.B) {
+	b.Run("ok", func(b *testing.B) { benchmarkExecContext(b, nil, WithFieldReuse()) })
+	b.Run("err", func(b *testing.B) { benchmarkExecContext(b, errors.New("boom"), WithFieldReuse()) })
+}
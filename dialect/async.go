@@ -0,0 +1,130 @@
+package driver
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// Backpressure is the policy of an AsyncSink when its buffer is full.
+type Backpressure int
+
+// Backpressure policies.
+const (
+	// Block blocks the operation until there is room in the buffer.
+	Block Backpressure = iota
+	// DropOldest drops the oldest buffered event.
+	DropOldest
+	// DropNewest drops the event being written.
+	DropNewest
+)
+
+// AsyncConfig configures an AsyncSink.
+type AsyncConfig struct {
+	// Buffer is the number of events buffered before the backpressure
+	// policy applies. Defaults to 1024.
+	Buffer int
+	// Policy is the backpressure policy. Defaults to Block.
+	Policy Backpressure
+}
+
+// AsyncSink is a Sink that writes the events to an underlying sink from a
+// background goroutine, removing the cost of the underlying sink from the
+// operations of the driver.
+type AsyncSink struct {
+	sink    Sink
+	cfg     AsyncConfig
+	events  chan asyncEvent
+	done    chan struct{}
+	mu      sync.RWMutex // guards closed and events against Close.
+	closed  bool
+	dropped atomic.Int64
+	errMu   sync.Mutex
+	err     error // last write error, returned by Write.
+}
+
+// asyncEvent is an event buffered by an AsyncSink along with its context.
+type asyncEvent struct {
+	ctx context.Context
+	e   *Event
+}
+
+// NewAsyncSink returns a new AsyncSink writing to s, and starts its worker.
+func NewAsyncSink(s Sink, cfg AsyncConfig) *AsyncSink {
+	if cfg.Buffer <= 0 {
+		cfg.Buffer = 1024
+	}
+	a := &AsyncSink{sink: s, cfg: cfg, events: make(chan asyncEvent, cfg.Buffer), done: make(chan struct{})}
+	go a.loop()
+	return a
+}
+
+// Write buffers the event, applying the backpressure policy if the buffer
+// is full. It returns the error of the last failed write of the underlying
+// sink, if any, so that it gets logged.
+func (a *AsyncSink) Write(ctx context.Context, e *Event) error {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.closed {
+		a.dropped.Add(1)
+		return nil
+	}
+	ev := asyncEvent{ctx: context.WithoutCancel(ctx), e: e}
+	switch a.cfg.Policy {
+	case DropNewest:
+		select {
+		case a.events <- ev:
+		default:
+			a.dropped.Add(1)
+		}
+	case DropOldest:
+		for sent := false; !sent; {
+			select {
+			case a.events <- ev:
+				sent = true
+			default:
+				select {
+				case <-a.events:
+					a.dropped.Add(1)
+				default:
+				}
+			}
+		}
+	default:
+		a.events <- ev
+	}
+	a.errMu.Lock()
+	defer a.errMu.Unlock()
+	err := a.err
+	a.err = nil
+	return err
+}
+
+// Dropped returns the number of events dropped by the backpressure policy.
+func (a *AsyncSink) Dropped() int64 {
+	return a.dropped.Load()
+}
+
+// Close writes the buffered events and closes the underlying sink.
+func (a *AsyncSink) Close() error {
+	a.mu.Lock()
+	if !a.closed {
+		a.closed = true
+		close(a.events)
+	}
+	a.mu.Unlock()
+	<-a.done
+	return a.sink.Close()
+}
+
+// loop writes the buffered events to the underlying sink until the sink is closed.
+func (a *AsyncSink) loop() {
+	defer close(a.done)
+	for ev := range a.events {
+		if err := a.sink.Write(ev.ctx, ev.e); err != nil {
+			a.errMu.Lock()
+			a.err = err
+			a.errMu.Unlock()
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package driver
+
+import (
+	"os"
+	"path"
+	"runtime/debug"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// WithAttrs returns an option that adds static attributes to all log
+// entries and events of the driver, e.g. the replica or release the
+// driver runs in. Attributes with empty values are skipped, and attributes
+// replace the previously added ones with the same key.
+func WithAttrs(attrs ...Attr) Option {
+	return func(d *DebugDriver) {
+	Attrs:
+		for _, a := range attrs {
+			if a.Value == "" {
+				continue
+			}
+			for i := range d.attrs {
+				if d.attrs[i].Key == a.Key {
+					d.attrs[i] = a
+					continue Attrs
+				}
+			}
+			d.attrs = append(d.attrs, a)
+		}
+	}
+}
+
+// attrFields returns the log fields of the static attributes of the driver.
+func (d *DebugDriver) attrFields() []zap.Field {
+	fields := make([]zap.Field, len(d.attrs))
+	for i, a := range d.attrs {
+		fields[i] = zap.String(a.Key, a.Value)
+	}
+	return fields
+}
+
+// WithHostInfo returns an option that adds the host and Kubernetes
+// attributes returned by HostAttrs to all log entries and events.
+func WithHostInfo() Option {
+	return WithAttrs(HostAttrs()...)
+}
+
+// HostAttrs returns the hostname of the process, and the pod, namespace and
+// node it runs in on Kubernetes, read from the POD_NAME, POD_NAMESPACE and
+// NODE_NAME variables set from the downward API.
+func HostAttrs() []Attr {
+	host, _ := os.Hostname()
+	namespace := os.Getenv("POD_NAMESPACE")
+	if namespace == "" {
+		if b, err := os.ReadFile("/var/run/secrets/kubernetes.io/serviceaccount/namespace"); err == nil {
+			namespace = strings.TrimSpace(string(b))
+		}
+	}
+	return []Attr{
+		{"host", host},
+		{"pod", os.Getenv("POD_NAME")},
+		{"namespace", namespace},
+		{"node", os.Getenv("NODE_NAME")},
+	}
+}
+
+// WithBuildInfo returns an option that adds the attributes returned by
+// BuildAttrs to all log entries and events, to compare the statements
+// of different releases.
+func WithBuildInfo(service, version string) Option {
+	return WithAttrs(BuildAttrs(service, version)...)
+}
+
+// BuildAttrs returns the service, version and commit attributes of the
+// program. The service and version default to the last element of the
+// main module path and to its version, and the commit is the VCS revision
+// stamped in the binary by the go command, if any.
+func BuildAttrs(service, version string) []Attr {
+	var commit string
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		if service == "" && bi.Main.Path != "" {
+			service = path.Base(bi.Main.Path)
+		}
+		if version == "" && bi.Main.Version != "(devel)" {
+			version = bi.Main.Version
+		}
+		for _, s := range bi.Settings {
+			if s.Key == "vcs.revision" {
+				commit = s.Value
+			}
+		}
+	}
+	return []Attr{
+		{"service", service},
+		{"version", version},
+		{"commit", commit},
+	}
+}
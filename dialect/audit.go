@@ -0,0 +1,153 @@
+package driver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"entgo.io/ent/dialect"
+	entsql "entgo.io/ent/dialect/sql"
+	"go.uber.org/zap"
+)
+
+// AuditRecord is a mutation recorded in the audit table of a driver. It is
+// attached to the context of the mutation by the enthook.Audit hook.
+type AuditRecord struct {
+	Time    time.Time
+	Actor   string // acting user or service.
+	Entity  string // entity type, e.g. "User".
+	Op      string // operation, e.g. "UpdateOne".
+	Changes any    // changed fields, stored as JSON.
+	OpID    string // operation id. See WithOpID.
+	TxID    string // transaction id, set by the driver.
+
+	written atomic.Bool
+}
+
+type auditKey struct{}
+
+// WithAuditRecord returns a context carrying the audit record of the
+// mutation executing its statements.
+func WithAuditRecord(ctx context.Context, r *AuditRecord) context.Context {
+	return context.WithValue(ctx, auditKey{}, r)
+}
+
+// WithAudit returns an option that writes the audit records of the write
+// statements to the given table, "entzlog_audit" by default, within their
+// transaction if any, or logs them as lost if they cannot be written.
+func WithAudit(table string) Option {
+	if table == "" {
+		table = "entzlog_audit"
+	}
+	return func(d *DebugDriver) {
+		d.auditTable = table
+	}
+}
+
+// MigrateAudit creates the audit table of WithAudit if it does not exist.
+func MigrateAudit(ctx context.Context, d Driver, table string) error {
+	if table == "" {
+		table = "entzlog_audit"
+	}
+	id, ts := "id INTEGER PRIMARY KEY AUTOINCREMENT", "TIMESTAMP"
+	switch d.Dialect() {
+	case dialect.Postgres:
+		id = "id BIGSERIAL PRIMARY KEY"
+	case dialect.MySQL:
+		id, ts = "id BIGINT AUTO_INCREMENT PRIMARY KEY", "TIMESTAMP(6)"
+	}
+	name := entsql.Dialect(d.Dialect()).String(func(b *entsql.Builder) { b.Ident(table) })
+	query := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	%s,
+	time %s NOT NULL,
+	actor VARCHAR(255),
+	entity VARCHAR(255) NOT NULL,
+	op VARCHAR(32) NOT NULL,
+	changes TEXT,
+	op_id VARCHAR(64),
+	tx_id VARCHAR(64),
+	prev_hash CHAR(64),
+	hash CHAR(64)
+)`, name, id, ts)
+	if err := d.Exec(ctx, query, []any{}, nil); err != nil {
+		return fmt.Errorf("entzlog: creating audit table: %w", err)
+	}
+	return nil
+}
+
+// audit writes the audit record of the context, if any, after a successful
+// write statement. The records of the statements executed outside of
+// transactions that cannot be written are logged instead.
+func (d *DebugDriver) audit(ctx context.Context, ex dialect.ExecQuerier, txID, op, query string) error {
+	if d.auditTable == "" {
+		return nil
+	}
+	r, ok := ctx.Value(auditKey{}).(*AuditRecord)
+	if !ok || r.written.Load() {
+		return nil
+	}
+	if !isWrite(StatementType(query)) {
+		return nil
+	}
+	if !r.written.CompareAndSwap(false, true) {
+		return nil
+	}
+	r.Time, r.TxID = auditTime(r.Time), txID
+	if r.Actor == "" {
+		r.Actor, _ = ActorFromContext(ctx)
+	}
+	err := d.writeAudit(ctx, ex, r)
+	if err == nil || txID != "" {
+		return err
+	}
+	d.log(ctx, d.opMsg(txID, op)+": audit record lost", ctxFields(ctx, []zap.Field{
+		zap.String("entity", r.Entity),
+		zap.String("audit_op", r.Op),
+		zap.String("actor", r.Actor),
+		zap.String("op_id", r.OpID),
+		zap.String("query", query),
+		zap.Error(err),
+	})...)
+	return nil
+}
+
+// writeAudit writes the audit record, or holds it until its transaction
+// commits if the records are chained.
+func (d *DebugDriver) writeAudit(ctx context.Context, ex dialect.ExecQuerier, r *AuditRecord) error {
+	changes, err := json.Marshal(r.Changes)
+	if err != nil {
+		return fmt.Errorf("entzlog: writing audit record: %w", err)
+	}
+	if d.chain == nil {
+		return d.insertAudit(ctx, ex, r, string(changes), nil, nil)
+	}
+	if r.TxID != "" {
+		d.chain.hold(r.TxID, r, string(changes))
+		return nil
+	}
+	d.chain.mu.Lock()
+	hash, anchored, err := d.writeChained(ctx, ex, r, string(changes))
+	d.chain.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if anchored {
+		d.logAnchor(ctx, r, hash)
+	}
+	return nil
+}
+
+// insertAudit inserts the audit record, with its chain hashes if any.
+func (d *DebugDriver) insertAudit(ctx context.Context, ex dialect.ExecQuerier, r *AuditRecord, changes string, prev, hash any) error {
+	insert, args := entsql.Dialect(d.Dialect()).
+		Insert(d.auditTable).
+		Columns("time", "actor", "entity", "op", "changes", "op_id", "tx_id", "prev_hash", "hash").
+		Values(r.Time, r.Actor, r.Entity, r.Op, changes, r.OpID, r.TxID, prev, hash).
+		Query()
+	if err := ex.Exec(ctx, insert, args, nil); err != nil {
+		return fmt.Errorf("entzlog: writing audit record: %w", err)
+	}
+	return nil
+}
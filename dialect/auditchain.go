@@ -0,0 +1,208 @@
+package driver
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"entgo.io/ent/dialect"
+	entsql "entgo.io/ent/dialect/sql"
+	"go.uber.org/zap"
+)
+
+// WithAuditChain returns an option that hash-chains the audit records, to be
+// checked by VerifyAudit, and logs the last hash every anchorEvery records.
+// The records of the transactions are chained when they commit.
+func WithAuditChain(anchorEvery int) Option {
+	return func(d *DebugDriver) {
+		d.chain = &auditChain{anchorEvery: anchorEvery, pending: make(map[string][]pendingRecord)}
+	}
+}
+
+// auditChain is the state of the hash chain of the audit records.
+type auditChain struct {
+	anchorEvery int
+	mu          sync.Mutex // held while records are linked and inserted.
+	loaded      bool       // whether last was loaded from the table.
+	last        string     // hash of the last chained record.
+	count       int        // number of chained records since the last anchor.
+
+	pendingMu sync.Mutex
+	pending   map[string][]pendingRecord // records of the open transactions, by id.
+}
+
+// pendingRecord is a record of an open transaction, chained on commit.
+type pendingRecord struct {
+	r       *AuditRecord
+	changes string
+}
+
+// hold adds a record of an open transaction, to chain on its commit.
+func (c *auditChain) hold(txID string, r *AuditRecord, changes string) {
+	c.pendingMu.Lock()
+	defer c.pendingMu.Unlock()
+	c.pending[txID] = append(c.pending[txID], pendingRecord{r: r, changes: changes})
+}
+
+// take removes and returns the records of a transaction.
+func (c *auditChain) take(txID string) []pendingRecord {
+	c.pendingMu.Lock()
+	defer c.pendingMu.Unlock()
+	records := c.pending[txID]
+	delete(c.pending, txID)
+	return records
+}
+
+// writeChained links the record to the last one, loading its hash from the
+// table if needed, and inserts it. It must be called with c.mu held, and
+// returns the hash of the record and whether an anchor is due after it.
+func (d *DebugDriver) writeChained(ctx context.Context, ex dialect.ExecQuerier, r *AuditRecord, changes string) (string, bool, error) {
+	c := d.chain
+	if !c.loaded {
+		last, err := lastAuditHash(ctx, ex, d.auditTable, d.Dialect())
+		if err != nil {
+			return "", false, err
+		}
+		c.last, c.loaded = last, true
+	}
+	hash := auditHash(c.last, r, changes)
+	if err := d.insertAudit(ctx, ex, r, changes, c.last, hash); err != nil {
+		return "", false, err
+	}
+	c.last = hash
+	c.count++
+	if c.anchorEvery <= 0 || c.count < c.anchorEvery {
+		return hash, false, nil
+	}
+	c.count = 0
+	return hash, true, nil
+}
+
+// commitChained links and inserts the records of the transaction, and
+// commits it under the lock of the chain. The chain is restored if the
+// records cannot be written, in which case the transaction is rolled back,
+// or if the commit fails.
+func (d *DebugDriver) commitChained(ctx context.Context, tx dialect.Tx, txID string) error {
+	records := d.chain.take(txID)
+	if len(records) == 0 {
+		return tx.Commit()
+	}
+	c := d.chain
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	loaded, last, count := c.loaded, c.last, c.count
+	type anchor struct {
+		r    *AuditRecord
+		hash string
+	}
+	var anchors []anchor
+	for _, p := range records {
+		hash, anchored, err := d.writeChained(ctx, tx, p.r, p.changes)
+		if err != nil {
+			c.loaded, c.last, c.count = loaded, last, count
+			tx.Rollback()
+			return err
+		}
+		if anchored {
+			anchors = append(anchors, anchor{r: p.r, hash: hash})
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		c.loaded, c.last, c.count = loaded, last, count
+		return err
+	}
+	for _, a := range anchors {
+		d.logAnchor(ctx, a.r, a.hash)
+	}
+	return nil
+}
+
+// endTx is called at the end of a transaction of the driver.
+func (d *DebugDriver) endTx(txID string) {
+	d.endTask(txID)
+	d.closeTx(txID)
+	d.endHistory(txID)
+	if d.chain != nil {
+		d.chain.take(txID)
+	}
+}
+
+// lastAuditHash returns the hash of the last chained record of the table.
+func lastAuditHash(ctx context.Context, ex dialect.ExecQuerier, table, dialectName string) (string, error) {
+	b := entsql.Dialect(dialectName)
+	query, args := b.Select("hash").From(b.Table(table)).Where(entsql.NotNull("hash")).OrderBy(entsql.Desc("id")).Limit(1).Query()
+	var rows entsql.Rows
+	if err := ex.Query(ctx, query, args, &rows); err != nil {
+		return "", fmt.Errorf("entzlog: loading audit chain: %w", err)
+	}
+	defer rows.Close()
+	var hash string
+	if rows.Next() {
+		if err := rows.Scan(&hash); err != nil {
+			return "", fmt.Errorf("entzlog: loading audit chain: %w", err)
+		}
+	}
+	return hash, rows.Err()
+}
+
+// auditHash returns the hash of the record chained after prev.
+func auditHash(prev string, r *AuditRecord, changes string) string {
+	h := sha256.New()
+	for _, f := range []string{prev, strconv.FormatInt(r.Time.UnixMicro(), 10), r.Actor, r.Entity, r.Op, changes, r.OpID, r.TxID} {
+		h.Write([]byte(f))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// VerifyAudit walks the chained records of the audit table in order, and
+// returns an error describing the first record whose hash does not match
+// its content or the previous record.
+func VerifyAudit(ctx context.Context, d Driver, table string) error {
+	if table == "" {
+		table = "entzlog_audit"
+	}
+	b := entsql.Dialect(d.Dialect())
+	query, args := b.Select("id", "time", "actor", "entity", "op", "changes", "op_id", "tx_id", "prev_hash", "hash").
+		From(b.Table(table)).Where(entsql.NotNull("hash")).OrderBy("id").Query()
+	var rows entsql.Rows
+	if err := d.Query(ctx, query, args, &rows); err != nil {
+		return fmt.Errorf("entzlog: verifying audit chain: %w", err)
+	}
+	defer rows.Close()
+	var last string
+	for first := true; rows.Next(); first = false {
+		var (
+			id                                     int64
+			r                                      AuditRecord
+			actor, changes, opID, txID, prev, hash entsql.NullString
+		)
+		if err := rows.Scan(&id, &r.Time, &actor, &r.Entity, &r.Op, &changes, &opID, &txID, &prev, &hash); err != nil {
+			return fmt.Errorf("entzlog: verifying audit chain: %w", err)
+		}
+		r.Actor, r.OpID, r.TxID = actor.String, opID.String, txID.String
+		switch {
+		case !first && prev.String != last:
+			return fmt.Errorf("entzlog: audit record %d: previous hash does not match record before it", id)
+		case auditHash(prev.String, &r, changes.String) != hash.String:
+			return fmt.Errorf("entzlog: audit record %d: hash does not match its content", id)
+		}
+		last = hash.String
+	}
+	return rows.Err()
+}
+
+// logAnchor logs the hash of the last chained record.
+func (d *DebugDriver) logAnchor(ctx context.Context, r *AuditRecord, hash string) {
+	d.log(ctx, "driver: audit anchor", zap.String("op_id", r.OpID), zap.String("hash", hash), zap.Time("time", r.Time))
+}
+
+// auditTime returns the time of a record, truncated to the precision
+// of the audit table.
+func auditTime(t time.Time) time.Time {
+	return t.UTC().Truncate(time.Microsecond)
+}
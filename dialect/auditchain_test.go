@@ -0,0 +1,161 @@
+package driver
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"entgo.io/ent/dialect"
+	entsql "entgo.io/ent/dialect/sql"
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestAuditChainCommit(t *testing.T) {
+	errCommit := errors.New("commit failed")
+	errInsert := errors.New("insert failed")
+	tests := []struct {
+		name     string
+		expect   func(sqlmock.Sqlmock)
+		rollback bool
+		wantErr  error
+		chained  bool
+	}{
+		{
+			name: "committed",
+			expect: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows([]string{"hash"}).AddRow("h0"))
+				m.ExpectExec("INSERT INTO").WillReturnResult(sqlmock.NewResult(1, 1))
+				m.ExpectCommit()
+			},
+			chained: true,
+		},
+		{
+			name: "commit failed",
+			expect: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows([]string{"hash"}).AddRow("h0"))
+				m.ExpectExec("INSERT INTO").WillReturnResult(sqlmock.NewResult(1, 1))
+				m.ExpectCommit().WillReturnError(errCommit)
+			},
+			wantErr: errCommit,
+		},
+		{
+			name: "insert failed",
+			expect: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows([]string{"hash"}).AddRow("h0"))
+				m.ExpectExec("INSERT INTO").WillReturnError(errInsert)
+				m.ExpectRollback()
+			},
+			wantErr: errInsert,
+		},
+		{
+			name: "rolled back",
+			expect: func(m sqlmock.Sqlmock) {
+				m.ExpectRollback()
+			},
+			rollback: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			mock.ExpectBegin()
+			mock.ExpectExec("UPDATE users").WillReturnResult(sqlmock.NewResult(0, 1))
+			tt.expect(mock)
+			drv := newDebugDriver(entsql.OpenDB(dialect.Postgres, db), nopLog, WithAudit(""), WithAuditChain(0))
+			r := &AuditRecord{Time: time.Now(), Entity: "User", Op: "UpdateOne", OpID: "op"}
+			ctx := WithAuditRecord(context.Background(), r)
+			tx, err := drv.Tx(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := tx.Exec(ctx, "UPDATE users SET name = $1", []any{"a8m"}, nil); err != nil {
+				t.Fatal(err)
+			}
+			if tt.rollback {
+				err = tx.Rollback()
+			} else {
+				err = tx.Commit()
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			c := drv.chain
+			if want := auditHash("h0", r, "null"); tt.chained != (c.loaded && c.last == want) {
+				t.Errorf("chain loaded = %t, last = %q, want chained %t", c.loaded, c.last, tt.chained)
+			}
+			if !tt.chained && (c.loaded || c.last != "" || c.count != 0) {
+				t.Errorf("chain was not restored: loaded = %t, last = %q, count = %d", c.loaded, c.last, c.count)
+			}
+			if len(c.pending) != 0 {
+				t.Errorf("%d transactions hold records after their end", len(c.pending))
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestVerifyAudit(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	r1 := &AuditRecord{Time: now, Actor: "alice", Entity: "User", Op: "Create", OpID: "op1"}
+	r2 := &AuditRecord{Time: now, Actor: "bob", Entity: "User", Op: "UpdateOne", OpID: "op2"}
+	h1 := auditHash("", r1, `{"name":"a8m"}`)
+	h2 := auditHash(h1, r2, `{"name":"ariel"}`)
+	row := func(id int64, r *AuditRecord, changes, prev, hash string) []driver.Value {
+		return []driver.Value{id, r.Time, r.Actor, r.Entity, r.Op, changes, r.OpID, "", prev, hash}
+	}
+	tests := []struct {
+		name    string
+		rows    [][]driver.Value
+		wantErr string
+	}{
+		{
+			name: "valid",
+			rows: [][]driver.Value{row(1, r1, `{"name":"a8m"}`, "", h1), row(2, r2, `{"name":"ariel"}`, h1, h2)},
+		},
+		{
+			name:    "modified",
+			rows:    [][]driver.Value{row(1, r1, `{"name":"a8m"}`, "", h1), row(2, r2, `{"name":"mallory"}`, h1, h2)},
+			wantErr: "audit record 2: hash does not match its content",
+		},
+		{
+			// Only detected by comparing the anchors with the table.
+			name: "first deleted",
+			rows: [][]driver.Value{row(2, r2, `{"name":"ariel"}`, h1, h2)},
+		},
+		{
+			name:    "relinked",
+			rows:    [][]driver.Value{row(1, r1, `{"name":"a8m"}`, "", h1), row(3, r2, `{"name":"ariel"}`, "other", auditHash("other", r2, `{"name":"ariel"}`))},
+			wantErr: "audit record 3: previous hash does not match",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			rows := sqlmock.NewRows([]string{"id", "time", "actor", "entity", "op", "changes", "op_id", "tx_id", "prev_hash", "hash"})
+			for _, r := range tt.rows {
+				rows.AddRow(r...)
+			}
+			mock.ExpectQuery("SELECT").WillReturnRows(rows)
+			err = VerifyAudit(context.Background(), entsql.OpenDB(dialect.Postgres, db), "")
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package driver
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/baggage"
+	"go.uber.org/zap"
+)
+
+// WithBaggage returns an option that attaches the OpenTelemetry baggage
+// entries with the given keys to the log entries and events of the driver.
+func WithBaggage(keys ...string) Option {
+	return func(d *DebugDriver) {
+		d.baggage = append(d.baggage, keys...)
+	}
+}
+
+// BaggageFromContext returns the OpenTelemetry baggage entries of the
+// context with the given keys, e.g. to tag the spans of a Hook.
+func BaggageFromContext(ctx context.Context, keys ...string) []Attr {
+	if len(keys) == 0 {
+		return nil
+	}
+	b := baggage.FromContext(ctx)
+	if b.Len() == 0 {
+		return nil
+	}
+	var attrs []Attr
+	for _, k := range keys {
+		if v := b.Member(k).Value(); v != "" {
+			attrs = append(attrs, Attr{k, v})
+		}
+	}
+	return attrs
+}
+
+// baggageFields appends the baggage entries of the context to fields.
+func (d *DebugDriver) baggageFields(ctx context.Context, fields []zap.Field) []zap.Field {
+	for _, a := range BaggageFromContext(ctx, d.baggage...) {
+		fields = append(fields, zap.String(a.Key, a.Value))
+	}
+	return fields
+}
@@ -0,0 +1,277 @@
+package driver
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"entgo.io/ent/dialect"
+	"go.uber.org/zap"
+)
+
+// BatchConfig configures a BatchDriver.
+type BatchConfig struct {
+	// Window is the time an insert waits for compatible inserts to be
+	// coalesced with. Defaults to 5ms.
+	Window time.Duration
+	// MaxRows is the maximum number of rows of a coalesced insert. Defaults to 100.
+	MaxRows int
+	// Log is the log function. Optional.
+	Log LogFunc
+	// Metrics receives the coalescing measurements. Optional.
+	Metrics Metrics
+}
+
+// BatchDriver is a driver that coalesces the single-row INSERT statements
+// executed with Exec outside of transactions into multi-row statements,
+// executed after Window or once full, and retried row by row on failure.
+type BatchDriver struct {
+	Driver              // underlying driver.
+	cfg                 BatchConfig
+	mu                  sync.Mutex
+	batches             map[string]*batch
+	inserts, statements atomic.Int64 // coalesced inserts, and executed statements.
+}
+
+// NewBatchDriver returns a new BatchDriver wrapping the given driver.
+func NewBatchDriver(d Driver, cfg BatchConfig) *BatchDriver {
+	if cfg.Window <= 0 {
+		cfg.Window = 5 * time.Millisecond
+	}
+	if cfg.MaxRows <= 0 {
+		cfg.MaxRows = 100
+	}
+	if cfg.Log == nil {
+		cfg.Log = nopLog
+	}
+	if cfg.Metrics == nil {
+		cfg.Metrics = nopMetrics{}
+	}
+	return &BatchDriver{Driver: d, cfg: cfg, batches: make(map[string]*batch)}
+}
+
+// batch is a set of coalesced single-row inserts.
+type batch struct {
+	key    string // prefix and scope of the inserts.
+	prefix string // INSERT INTO ... VALUES
+	rows   []*batchRow
+	done   chan struct{}
+}
+
+// batchRow is a single-row insert of a batch.
+type batchRow struct {
+	ctx   context.Context
+	tuple string
+	args  []any
+	err   error
+}
+
+// Exec coalesces single-row inserts and calls the underlying driver Exec method otherwise.
+func (d *BatchDriver) Exec(ctx context.Context, query string, args, v any) error {
+	prefix, tuple, ok := splitInsert(query)
+	argv, isArgs := args.([]any)
+	if v != nil || !ok || !isArgs {
+		return d.Driver.Exec(ctx, query, args, v)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	row := &batchRow{ctx: ctx, tuple: tuple, args: argv}
+	d.mu.Lock()
+	key := prefix + "\x00" + batchScope(ctx)
+	b, ok := d.batches[key]
+	if !ok {
+		b = &batch{key: key, prefix: prefix, done: make(chan struct{})}
+		d.batches[key] = b
+		time.AfterFunc(d.cfg.Window, func() {
+			if d.detach(b) {
+				d.flush(b)
+			}
+		})
+	}
+	b.rows = append(b.rows, row)
+	full := len(b.rows) >= d.cfg.MaxRows
+	if full {
+		delete(d.batches, key)
+	}
+	d.mu.Unlock()
+	if full {
+		d.flush(b)
+	}
+	select {
+	case <-b.done:
+	case <-ctx.Done():
+		if d.withdraw(b, row) {
+			return ctx.Err()
+		}
+		<-b.done
+	}
+	return row.err
+}
+
+// BeginTx starts a transaction with options if it is supported by the
+// underlying driver. Its statements are not coalesced.
+func (d *BatchDriver) BeginTx(ctx context.Context, opts *sql.TxOptions) (dialect.Tx, error) {
+	return beginTx(ctx, d.Driver, opts)
+}
+
+// detach removes the batch from the pending batches, and reports
+// whether it was still pending.
+func (d *BatchDriver) detach(b *batch) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.batches[b.key] != b {
+		return false
+	}
+	delete(d.batches, b.key)
+	return true
+}
+
+// withdraw removes the row from the batch, and reports whether the batch
+// was still pending.
+func (d *BatchDriver) withdraw(b *batch, row *batchRow) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.batches[b.key] != b {
+		return false
+	}
+	b.rows = slices.DeleteFunc(b.rows, func(r *batchRow) bool { return r == row })
+	return true
+}
+
+// flush executes the batch and releases its waiters. The batch is canceled
+// once the contexts of all its rows are done, and its rows are executed one
+// by one if it fails.
+func (d *BatchDriver) flush(b *batch) {
+	defer close(b.done)
+	if len(b.rows) == 0 {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.WithoutCancel(b.rows[0].ctx))
+	defer cancel()
+	var left atomic.Int64
+	left.Store(int64(len(b.rows)))
+	for _, r := range b.rows {
+		stop := context.AfterFunc(r.ctx, func() {
+			if left.Add(-1) == 0 {
+				cancel()
+			}
+		})
+		defer stop()
+	}
+	var (
+		tuples = make([]string, len(b.rows))
+		args   []any
+	)
+	for i, r := range b.rows {
+		tuples[i] = r.tuple
+		if d.Dialect() == dialect.Postgres {
+			tuples[i] = shiftPlaceholders(r.tuple, len(args))
+		}
+		args = append(args, r.args...)
+	}
+	err := d.Driver.Exec(ctx, b.prefix+" "+strings.Join(tuples, ", "), args, nil)
+	if err != nil && len(b.rows) > 1 {
+		d.cfg.Log(ctx, "batch: coalesced inserts failed, inserting them separately", zap.Strings("tables", queryTables(b.prefix)), zap.Int("rows", len(b.rows)), zap.Error(err))
+		for _, r := range b.rows {
+			r.err = d.Driver.Exec(r.ctx, b.prefix+" "+r.tuple, r.args, nil)
+		}
+		d.statements.Add(int64(len(b.rows)))
+	} else {
+		for _, r := range b.rows {
+			r.err = err
+		}
+	}
+	rows := len(b.rows)
+	inserts, statements := d.inserts.Add(int64(rows)), d.statements.Add(1)
+	d.cfg.Log(ctx, "batch: coalesced inserts", zap.Strings("tables", queryTables(b.prefix)), zap.Int("rows", rows), zap.Float64("ratio", float64(inserts)/float64(statements)), zap.Error(err))
+	d.cfg.Metrics.Count(ctx, "entzlog_batch_statements_total", 1)
+	d.cfg.Metrics.Count(ctx, "entzlog_batch_rows_total", float64(rows))
+}
+
+// batchScope returns the values of the context the statements are logged,
+// audited and routed with, which the coalesced inserts must share.
+func batchScope(ctx context.Context) string {
+	tenant, _ := TenantFromContext(ctx)
+	key, _ := ShardKeyFromContext(ctx)
+	shard, sharded := ShardFromContext(ctx)
+	actor, _ := ActorFromContext(ctx)
+	opID, _ := OpIDFromContext(ctx)
+	record, _ := ctx.Value(auditKey{}).(*AuditRecord)
+	return fmt.Sprintf("%q %q %d %t %q %q %p", tenant, key, shard, sharded, actor, opID, record)
+}
+
+// splitInsert splits a single-row "INSERT INTO t (...) VALUES (...)" statement
+// into its prefix (up to and including VALUES) and its values tuple. It returns
+// false for any other statement, including inserts with an ON CONFLICT or
+// RETURNING clause, which cannot be coalesced.
+func splitInsert(query string) (prefix, tuple string, ok bool) {
+	q := strings.TrimSpace(query)
+	if len(q) < 6 || !strings.EqualFold(q[:6], "INSERT") {
+		return "", "", false
+	}
+	i := strings.Index(strings.ToUpper(q), " VALUES ")
+	if i == -1 {
+		return "", "", false
+	}
+	prefix, tuple = q[:i+len(" VALUES")], strings.TrimSpace(q[i+len(" VALUES "):])
+	if !strings.HasPrefix(tuple, "(") || !strings.HasSuffix(tuple, ")") {
+		return "", "", false
+	}
+	// The tuple must be a single parenthesized group.
+	depth, quoted := 0, false
+	for j, c := range tuple {
+		switch {
+		case c == '\'':
+			quoted = !quoted
+		case quoted:
+		case c == '(':
+			depth++
+		case c == ')':
+			if depth--; depth == 0 && j != len(tuple)-1 {
+				return "", "", false
+			}
+		}
+	}
+	return prefix, tuple, depth == 0 && !quoted
+}
+
+// shiftPlaceholders shifts the numbered placeholders ($1, $2, ...)
+// of the tuple by n.
+func shiftPlaceholders(tuple string, n int) string {
+	if n == 0 {
+		return tuple
+	}
+	var (
+		b      strings.Builder
+		quoted bool
+	)
+	for i := 0; i < len(tuple); i++ {
+		c := tuple[i]
+		if c == '\'' {
+			quoted = !quoted
+		}
+		if c != '$' || quoted {
+			b.WriteByte(c)
+			continue
+		}
+		j := i + 1
+		for j < len(tuple) && '0' <= tuple[j] && tuple[j] <= '9' {
+			j++
+		}
+		p, err := strconv.Atoi(tuple[i+1 : j])
+		if err != nil {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteString("$" + strconv.Itoa(p+n))
+		i = j - 1
+	}
+	return b.String()
+}
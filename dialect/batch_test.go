@@ -0,0 +1,119 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"entgo.io/ent/dialect"
+	entsql "entgo.io/ent/dialect/sql"
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestBatchDriver(t *testing.T) {
+	const insert = "INSERT INTO users (id, name) VALUES ($1, $2)"
+	errDup := errors.New("duplicate key")
+	tests := []struct {
+		name    string
+		ctxs    []context.Context
+		expect  func(sqlmock.Sqlmock)
+		wantErr []error
+	}{
+		{
+			name: "coalesced",
+			ctxs: []context.Context{context.Background(), context.Background()},
+			expect: func(m sqlmock.Sqlmock) {
+				m.ExpectExec("INSERT INTO users (id, name) VALUES ($1, $2), ($3, $4)").
+					WithArgs(0, "a", 1, "b").
+					WillReturnResult(sqlmock.NewResult(0, 2))
+			},
+			wantErr: []error{nil, nil},
+		},
+		{
+			name: "tenants",
+			ctxs: []context.Context{WithTenant(context.Background(), "t1"), WithTenant(context.Background(), "t2")},
+			expect: func(m sqlmock.Sqlmock) {
+				m.ExpectExec(insert).WithArgs(0, "a").WillReturnResult(sqlmock.NewResult(0, 1))
+				m.ExpectExec(insert).WithArgs(1, "b").WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			wantErr: []error{nil, nil},
+		},
+		{
+			name: "actors",
+			ctxs: []context.Context{WithActor(context.Background(), "alice"), WithActor(context.Background(), "bob")},
+			expect: func(m sqlmock.Sqlmock) {
+				m.ExpectExec(insert).WithArgs(0, "a").WillReturnResult(sqlmock.NewResult(0, 1))
+				m.ExpectExec(insert).WithArgs(1, "b").WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			wantErr: []error{nil, nil},
+		},
+		{
+			name: "separated on failure",
+			ctxs: []context.Context{context.Background(), context.Background()},
+			expect: func(m sqlmock.Sqlmock) {
+				m.ExpectExec("INSERT INTO users (id, name) VALUES ($1, $2), ($3, $4)").
+					WithArgs(0, "a", 1, "b").
+					WillReturnError(errDup)
+				m.ExpectExec(insert).WithArgs(0, "a").WillReturnResult(sqlmock.NewResult(0, 1))
+				m.ExpectExec(insert).WithArgs(1, "b").WillReturnError(errDup)
+			},
+			wantErr: []error{nil, errDup},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			mock.MatchExpectationsInOrder(false)
+			tt.expect(mock)
+			drv := NewBatchDriver(entsql.OpenDB(dialect.Postgres, db), BatchConfig{Window: 50 * time.Millisecond})
+			var (
+				wg   sync.WaitGroup
+				errs = make([]error, len(tt.ctxs))
+			)
+			for i, ctx := range tt.ctxs {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					errs[i] = drv.Exec(ctx, insert, []any{i, string(rune('a' + i))}, nil)
+				}()
+				// Keep the order of the rows in the batch.
+				time.Sleep(5 * time.Millisecond)
+			}
+			wg.Wait()
+			for i, want := range tt.wantErr {
+				if !errors.Is(errs[i], want) {
+					t.Errorf("insert %d: err = %v, want %v", i, errs[i], want)
+				}
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestSplitInsert(t *testing.T) {
+	tests := []struct {
+		query         string
+		prefix, tuple string
+		ok            bool
+	}{
+		{"INSERT INTO users (id) VALUES ($1)", "INSERT INTO users (id) VALUES", "($1)", true},
+		{"INSERT INTO users (name) VALUES ('a), (b')", "INSERT INTO users (name) VALUES", "('a), (b')", true},
+		{"INSERT INTO users (id) VALUES ($1), ($2)", "", "", false},
+		{"INSERT INTO users (id) VALUES ($1) RETURNING id", "", "", false},
+		{"UPDATE users SET id = $1", "", "", false},
+	}
+	for _, tt := range tests {
+		prefix, tuple, ok := splitInsert(tt.query)
+		if prefix != tt.prefix || tuple != tt.tuple || ok != tt.ok {
+			t.Errorf("splitInsert(%q) = %q, %q, %t, want %q, %q, %t", tt.query, prefix, tuple, ok, tt.prefix, tt.tuple, tt.ok)
+		}
+	}
+}
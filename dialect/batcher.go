@@ -0,0 +1,149 @@
+package driver
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// batcher queues events and sends them in batches from a background
+// goroutine, at most one batch per interval. It is used by the sinks
+// posting events to remote endpoints.
+type batcher struct {
+	interval time.Duration
+	size     int // maximum batch size.
+	max      int // maximum number of pending events.
+	send     func([]*Event) error
+	mu       sync.Mutex
+	pending  []*Event
+	err      error // last send error, returned by add.
+	dropped  atomic.Int64
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// newBatcher returns a new batcher and starts its sender.
+func newBatcher(interval time.Duration, size, max int, send func([]*Event) error) *batcher {
+	b := &batcher{interval: interval, size: size, max: max, send: send, stop: make(chan struct{}), done: make(chan struct{})}
+	go b.loop()
+	return b
+}
+
+// add queues the event, or drops it if too many events are pending. It
+// returns the error of the last failed send, if any, so that it gets logged.
+func (b *batcher) add(e *Event) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.pending) >= b.max {
+		b.dropped.Add(1)
+	} else {
+		b.pending = append(b.pending, e)
+	}
+	err := b.err
+	b.err = nil
+	return err
+}
+
+// close stops the sender and sends the pending events.
+func (b *batcher) close() error {
+	close(b.stop)
+	<-b.done
+	for {
+		batch := b.next()
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := b.send(batch); err != nil {
+			return err
+		}
+	}
+}
+
+// loop sends a batch of pending events every interval until the batcher is closed.
+func (b *batcher) loop() {
+	defer close(b.done)
+	t := time.NewTicker(b.interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-b.stop:
+			return
+		case <-t.C:
+			if batch := b.next(); len(batch) > 0 {
+				if err := b.send(batch); err != nil {
+					b.mu.Lock()
+					b.err = err
+					b.mu.Unlock()
+				}
+			}
+		}
+	}
+}
+
+// next removes and returns the next batch of pending events.
+func (b *batcher) next() []*Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	n := min(len(b.pending), b.size)
+	batch := b.pending[:n:n]
+	b.pending = b.pending[n:]
+	return batch
+}
+
+// Backoffs of the reconnections of a redialer.
+const (
+	minRedialBackoff = time.Second
+	maxRedialBackoff = time.Minute
+)
+
+// redialer holds the connection of a sink to its endpoint, established on
+// first use and re-established after failures. After a failed connection,
+// the next one is not attempted before a backoff, doubled after each failure
+// up to a minute, and the batches sent in the meantime fail right away.
+type redialer struct {
+	network, addr string
+	timeout       time.Duration
+	conn          net.Conn
+	err           error         // last connection error.
+	backoff       time.Duration // backoff of the next connection failure.
+	retry         time.Time     // time before which no connection is attempted.
+}
+
+// get returns the connection, establishing it if needed.
+func (r *redialer) get() (net.Conn, error) {
+	if r.conn != nil {
+		return r.conn, nil
+	}
+	if now := time.Now(); now.Before(r.retry) {
+		return nil, fmt.Errorf("%w (retrying in %s)", r.err, r.retry.Sub(now).Round(time.Millisecond))
+	}
+	conn, err := net.DialTimeout(r.network, r.addr, r.timeout)
+	if err != nil {
+		r.backoff = min(max(2*r.backoff, minRedialBackoff), maxRedialBackoff)
+		r.err, r.retry = err, time.Now().Add(r.backoff)
+		return nil, err
+	}
+	r.conn, r.err, r.backoff = conn, nil, 0
+	return conn, nil
+}
+
+// reset closes the connection after a failed write, to re-establish it
+// on next use.
+func (r *redialer) reset() {
+	if r.conn != nil {
+		r.conn.Close()
+		r.conn = nil
+	}
+}
+
+// close closes the connection, if any.
+func (r *redialer) close() error {
+	if r.conn == nil {
+		return nil
+	}
+	err := r.conn.Close()
+	r.conn = nil
+	return err
+}
@@ -0,0 +1,434 @@
+package driver
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"entgo.io/ent/dialect"
+	entsql "entgo.io/ent/dialect/sql"
+	"go.uber.org/zap"
+)
+
+// CacheConfig configures a CacheDriver.
+type CacheConfig struct {
+	// TTL of the cached results. Defaults to one minute.
+	TTL time.Duration
+	// MaxEntries is the maximum number of cached results. Defaults to 1000.
+	MaxEntries int
+	// MaxRows is the maximum number of rows a result may have to be cached. Defaults to 1000.
+	MaxRows int
+	// Log is the log function. Optional.
+	Log LogFunc
+	// Metrics receives the cache measurements. Optional.
+	Metrics Metrics
+	// Invalidator is called before and after each write executed through the
+	// driver, and after the commit of the transactions that wrote. Statements
+	// writing neither data nor schema, e.g. SET, do not invalidate results.
+	// Defaults to InvalidateTables.
+	Invalidator Invalidator
+}
+
+// Invalidator decides which cached results are invalidated when a write
+// to the given tables is executed. tables is empty if the written tables
+// cannot be determined from the statement.
+type Invalidator interface {
+	Invalidate(ctx context.Context, d *CacheDriver, tables []string)
+}
+
+// The InvalidatorFunc type is an adapter to allow the use of ordinary
+// functions as Invalidator.
+type InvalidatorFunc func(context.Context, *CacheDriver, []string)
+
+// Invalidate calls f(ctx, d, tables).
+func (f InvalidatorFunc) Invalidate(ctx context.Context, d *CacheDriver, tables []string) {
+	f(ctx, d, tables)
+}
+
+var (
+	// InvalidateTables removes the cached results that read from any of the
+	// written tables, or all results if the written tables are unknown.
+	InvalidateTables Invalidator = InvalidatorFunc(func(ctx context.Context, d *CacheDriver, tables []string) {
+		if len(tables) == 0 {
+			d.Purge()
+			d.cfg.Log(ctx, "cache: purged")
+			return
+		}
+		n := d.Evict(tables...)
+		d.cfg.Log(ctx, "cache: invalidated", zap.Strings("tables", tables), zap.Int("entries", n))
+		d.cfg.Metrics.Count(ctx, "entzlog_cache_invalidations_total", float64(n))
+	})
+	// InvalidateAll removes all cached results on every write.
+	InvalidateAll Invalidator = InvalidatorFunc(func(ctx context.Context, d *CacheDriver, tables []string) {
+		d.Purge()
+		d.cfg.Log(ctx, "cache: purged", zap.Strings("tables", tables))
+	})
+	// LogInvalidations only logs the cached results that InvalidateTables
+	// would remove, without removing them. Useful to evaluate the cache.
+	LogInvalidations Invalidator = InvalidatorFunc(func(ctx context.Context, d *CacheDriver, tables []string) {
+		d.cfg.Log(ctx, "cache: would invalidate", zap.Strings("tables", tables), zap.Int("entries", d.count(tables)))
+	})
+)
+
+// CacheStats holds the counters of a CacheDriver.
+type CacheStats struct {
+	Hits, Misses, Bypasses int64
+}
+
+// HitRatio returns the ratio of hits to cacheable lookups.
+func (s CacheStats) HitRatio() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// CacheDriver is a driver that caches the results of read-only queries
+// executed outside of transactions, invalidated by the writes of the driver.
+type CacheDriver struct {
+	Driver                 // underlying driver.
+	cfg                    CacheConfig
+	mu                     sync.Mutex
+	entries                map[string]*cacheEntry
+	gen                    uint64 // incremented on every removal of results.
+	hits, misses, bypasses atomic.Int64
+}
+
+// cacheEntry is a cached result.
+type cacheEntry struct {
+	res     *result
+	tables  []string // tables read by the query.
+	expires time.Time
+}
+
+// NewCacheDriver returns a new CacheDriver wrapping the given driver.
+func NewCacheDriver(d Driver, cfg CacheConfig) *CacheDriver {
+	if cfg.TTL <= 0 {
+		cfg.TTL = time.Minute
+	}
+	if cfg.MaxEntries <= 0 {
+		cfg.MaxEntries = 1000
+	}
+	if cfg.MaxRows <= 0 {
+		cfg.MaxRows = 1000
+	}
+	if cfg.Log == nil {
+		cfg.Log = nopLog
+	}
+	if cfg.Metrics == nil {
+		cfg.Metrics = nopMetrics{}
+	}
+	if cfg.Invalidator == nil {
+		cfg.Invalidator = InvalidateTables
+	}
+	return &CacheDriver{Driver: d, cfg: cfg, entries: make(map[string]*cacheEntry)}
+}
+
+type noCacheKey struct{}
+
+// WithoutCache returns a context that bypasses the CacheDriver.
+func WithoutCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noCacheKey{}, true)
+}
+
+// Stats returns the cache counters.
+func (d *CacheDriver) Stats() CacheStats {
+	return CacheStats{Hits: d.hits.Load(), Misses: d.misses.Load(), Bypasses: d.bypasses.Load()}
+}
+
+// Exec calls the underlying driver Exec method and invalidates the results
+// of the written tables before and after it.
+func (d *CacheDriver) Exec(ctx context.Context, query string, args, v any) error {
+	d.invalidate(ctx, query)
+	defer d.invalidate(ctx, query)
+	return d.Driver.Exec(ctx, query, args, v)
+}
+
+// ExecContext calls the underlying driver ExecContext method and
+// invalidates the results of the written tables before and after it.
+func (d *CacheDriver) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	d.invalidate(ctx, query)
+	defer d.invalidate(ctx, query)
+	return execContext(ctx, d.Driver, query, args)
+}
+
+// Query serves read-only queries from the cache and calls the underlying driver Query method otherwise.
+func (d *CacheDriver) Query(ctx context.Context, query string, args, v any) error {
+	vr, ok := v.(*entsql.Rows)
+	if !ok || !d.cacheable(ctx, query) {
+		d.record(ctx, "cache.Query", "bypass", query)
+		d.invalidate(ctx, query)
+		defer d.invalidate(ctx, query)
+		return d.Driver.Query(ctx, query, args, v)
+	}
+	rows, err := d.lookup(ctx, "cache.Query", query, args, func() (entsql.ColumnScanner, error) {
+		var rows entsql.Rows
+		if err := d.Driver.Query(ctx, query, args, &rows); err != nil {
+			return nil, err
+		}
+		return rows.ColumnScanner, nil
+	})
+	if err != nil {
+		return err
+	}
+	*vr = entsql.Rows{ColumnScanner: rows}
+	return nil
+}
+
+// QueryContext serves read-only queries from the cache and calls the underlying driver QueryContext method otherwise.
+func (d *CacheDriver) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	if !d.cacheable(ctx, query) {
+		d.record(ctx, "cache.QueryContext", "bypass", query)
+		d.invalidate(ctx, query)
+		defer d.invalidate(ctx, query)
+		return queryContext(ctx, d.Driver, query, args)
+	}
+	return d.lookup(ctx, "cache.QueryContext", query, args, func() (entsql.ColumnScanner, error) {
+		return queryContext(ctx, d.Driver, query, args)
+	})
+}
+
+// Tx starts a transaction that invalidates the results of the tables it wrote to once committed.
+func (d *CacheDriver) Tx(ctx context.Context) (dialect.Tx, error) {
+	tx, err := d.Driver.Tx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &cacheTx{Tx: tx, drv: d, ctx: ctx}, nil
+}
+
+// BeginTx starts a transaction with options if it is supported by the underlying driver.
+// The transaction invalidates the results of the tables it wrote to once committed.
+func (d *CacheDriver) BeginTx(ctx context.Context, opts *sql.TxOptions) (dialect.Tx, error) {
+	tx, err := beginTx(ctx, d.Driver, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &cacheTx{Tx: tx, drv: d, ctx: ctx}, nil
+}
+
+// Purge removes all cached results.
+func (d *CacheDriver) Purge() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.entries = make(map[string]*cacheEntry)
+	d.gen++
+}
+
+// Evict removes the cached results that read from any of the given tables,
+// or whose tables are unknown, and returns the number of removed results.
+func (d *CacheDriver) Evict(tables ...string) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.gen++
+	n := 0
+	for k, e := range d.entries {
+		if e.reads(tables) {
+			delete(d.entries, k)
+			n++
+		}
+	}
+	return n
+}
+
+// count returns the number of cached results Evict would remove.
+func (d *CacheDriver) count(tables []string) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	n := 0
+	for _, e := range d.entries {
+		if len(tables) == 0 || e.reads(tables) {
+			n++
+		}
+	}
+	return n
+}
+
+// invalidate calls the configured Invalidator if the query is a write.
+func (d *CacheDriver) invalidate(ctx context.Context, query string) {
+	if !invalidates(query) {
+		return
+	}
+	d.cfg.Invalidator.Invalidate(ctx, d, writeTables(query))
+}
+
+// invalidates reports whether the query may change cached results: a data
+// modification, including in the common table expressions of a SELECT, or
+// a DDL statement.
+func invalidates(query string) bool {
+	switch typ := StatementType(query); typ {
+	case StmtSelect:
+		return !isPlainRead(query)
+	case StmtDDL:
+		return true
+	default:
+		return isWrite(typ)
+	}
+}
+
+// reads reports whether the entry reads from any of the given tables.
+func (e *cacheEntry) reads(tables []string) bool {
+	if len(e.tables) == 0 {
+		return true
+	}
+	for _, t := range tables {
+		for _, et := range e.tables {
+			if strings.EqualFold(t, et) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// cacheTx is a transaction that records the tables it writes to, and
+// invalidates their cached results on commit.
+type cacheTx struct {
+	dialect.Tx
+	drv    *CacheDriver
+	ctx    context.Context
+	mu     sync.Mutex
+	writes []string // queries of the executed writes.
+}
+
+// Exec records the write and calls the underlying transaction Exec method.
+func (tx *cacheTx) Exec(ctx context.Context, query string, args, v any) error {
+	tx.record(query)
+	return tx.Tx.Exec(ctx, query, args, v)
+}
+
+// Query records the write, if the query is one, and calls the underlying transaction Query method.
+func (tx *cacheTx) Query(ctx context.Context, query string, args, v any) error {
+	tx.record(query)
+	return tx.Tx.Query(ctx, query, args, v)
+}
+
+// ExecContext records the write and calls the underlying transaction ExecContext method.
+func (tx *cacheTx) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	tx.record(query)
+	return execContext(ctx, tx.Tx, query, args)
+}
+
+// QueryContext records the write, if the query is one, and calls the underlying
+// transaction QueryContext method.
+func (tx *cacheTx) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	tx.record(query)
+	return queryContext(ctx, tx.Tx, query, args)
+}
+
+// Commit calls the underlying transaction Commit method and invalidates
+// the results of the tables written by the transaction if it succeeded.
+func (tx *cacheTx) Commit() error {
+	err := tx.Tx.Commit()
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if err == nil {
+		for _, query := range tx.writes {
+			tx.drv.invalidate(tx.ctx, query)
+		}
+	}
+	tx.writes = nil
+	return err
+}
+
+// record records the query if it is a write.
+func (tx *cacheTx) record(query string) {
+	if invalidates(query) {
+		tx.mu.Lock()
+		tx.writes = append(tx.writes, query)
+		tx.mu.Unlock()
+	}
+}
+
+// cacheable reports whether the query result can be cached.
+func (d *CacheDriver) cacheable(ctx context.Context, query string) bool {
+	bypass, _ := ctx.Value(noCacheKey{}).(bool)
+	return !bypass && isReadOnly(query)
+}
+
+// lookup returns the cached result of the query, or executes it with exec
+// and caches its result, unless results were removed in the meantime.
+func (d *CacheDriver) lookup(ctx context.Context, op, query string, args any, exec func() (entsql.ColumnScanner, error)) (*sql.Rows, error) {
+	key := cacheKey(query, args)
+	now := time.Now()
+	d.mu.Lock()
+	e, ok := d.entries[key]
+	if ok && now.After(e.expires) {
+		delete(d.entries, key)
+		ok = false
+	}
+	gen := d.gen
+	d.mu.Unlock()
+	if ok {
+		d.record(ctx, op, "hit", query)
+		return e.res.rows(ctx)
+	}
+	d.record(ctx, op, "miss", query)
+	rs, err := exec()
+	if err != nil {
+		return nil, err
+	}
+	res, err := materialize(rs)
+	if err != nil {
+		return nil, err
+	}
+	if len(res.values) <= d.cfg.MaxRows {
+		d.store(key, gen, &cacheEntry{res: res, tables: queryTables(query), expires: now.Add(d.cfg.TTL)})
+	}
+	return res.rows(ctx)
+}
+
+// store adds the entry to the cache, evicting entries if it is full. The
+// entry is dropped if results were removed since the generation gen.
+func (d *CacheDriver) store(key string, gen uint64, e *cacheEntry) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.gen != gen {
+		return
+	}
+	if len(d.entries) >= d.cfg.MaxEntries {
+		now := time.Now()
+		for k, e := range d.entries {
+			if now.After(e.expires) {
+				delete(d.entries, k)
+			}
+		}
+	}
+	for k := range d.entries {
+		if len(d.entries) < d.cfg.MaxEntries {
+			break
+		}
+		delete(d.entries, k)
+	}
+	d.entries[key] = e
+}
+
+// record logs and counts the outcome of a cache lookup.
+func (d *CacheDriver) record(ctx context.Context, op, outcome, query string) {
+	switch outcome {
+	case "hit":
+		d.hits.Add(1)
+	case "miss":
+		d.misses.Add(1)
+	default:
+		d.bypasses.Add(1)
+	}
+	d.cfg.Log(ctx, op, zap.String("cache", outcome), zap.String("query", query))
+	d.cfg.Metrics.Count(ctx, "entzlog_cache_lookups_total", 1, Label{"result", outcome})
+	d.cfg.Metrics.Gauge(ctx, "entzlog_cache_hit_ratio", d.Stats().HitRatio())
+}
+
+// cacheKey returns the cache key of the query and its arguments.
+func cacheKey(query string, args any) string {
+	var b strings.Builder
+	b.WriteString(normalizeQuery(query))
+	argv, _ := args.([]any)
+	for _, arg := range argv {
+		fmt.Fprintf(&b, "\x00%T:%v", arg, arg)
+	}
+	return b.String()
+}
@@ -0,0 +1,133 @@
+package driver
+
+import (
+	"context"
+	"testing"
+
+	"entgo.io/ent/dialect"
+	entsql "entgo.io/ent/dialect/sql"
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestCacheDriverInvalidate(t *testing.T) {
+	const query = "SELECT id FROM users"
+	read := func(ctx context.Context, d *CacheDriver) error {
+		_, err := d.QueryContext(ctx, query)
+		return err
+	}
+	write := func(query string) func(context.Context, *CacheDriver) error {
+		return func(ctx context.Context, d *CacheDriver) error {
+			_, err := d.ExecContext(ctx, query)
+			return err
+		}
+	}
+	tx := func(query string, commit bool) func(context.Context, *CacheDriver) error {
+		return func(ctx context.Context, d *CacheDriver) error {
+			tx, err := d.Tx(ctx)
+			if err != nil {
+				return err
+			}
+			if err := tx.Exec(ctx, query, []any{}, nil); err != nil {
+				return err
+			}
+			if commit {
+				return tx.Commit()
+			}
+			return tx.Rollback()
+		}
+	}
+	tests := []struct {
+		name    string
+		steps   []func(context.Context, *CacheDriver) error
+		expect  func(sqlmock.Sqlmock)
+		queries int
+	}{
+		{
+			name:    "cached",
+			steps:   []func(context.Context, *CacheDriver) error{read, read},
+			queries: 1,
+		},
+		{
+			name:  "write",
+			steps: []func(context.Context, *CacheDriver) error{read, write("UPDATE users SET name = 'a8m'"), read},
+			expect: func(m sqlmock.Sqlmock) {
+				m.ExpectExec("UPDATE users SET name = 'a8m'").WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			queries: 2,
+		},
+		{
+			name:  "write to other table",
+			steps: []func(context.Context, *CacheDriver) error{read, write("UPDATE pets SET name = 'pedro'"), read},
+			expect: func(m sqlmock.Sqlmock) {
+				m.ExpectExec("UPDATE pets SET name = 'pedro'").WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			queries: 1,
+		},
+		{
+			name:  "committed tx",
+			steps: []func(context.Context, *CacheDriver) error{read, tx("DELETE FROM users", true), read},
+			expect: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin()
+				m.ExpectExec("DELETE FROM users").WillReturnResult(sqlmock.NewResult(0, 1))
+				m.ExpectCommit()
+			},
+			queries: 2,
+		},
+		{
+			name:  "rolled back tx",
+			steps: []func(context.Context, *CacheDriver) error{read, tx("DELETE FROM users", false), read},
+			expect: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin()
+				m.ExpectExec("DELETE FROM users").WillReturnResult(sqlmock.NewResult(0, 1))
+				m.ExpectRollback()
+			},
+			queries: 1,
+		},
+		{
+			name: "write during read",
+			steps: []func(context.Context, *CacheDriver) error{
+				func(ctx context.Context, d *CacheDriver) error {
+					_, err := d.lookup(ctx, "cache.QueryContext", query, []any{}, func() (entsql.ColumnScanner, error) {
+						rows, err := queryContext(ctx, d.Driver, query, []any{})
+						if err != nil {
+							return nil, err
+						}
+						return rows, write("DELETE FROM users")(ctx, d)
+					})
+					return err
+				},
+				read,
+			},
+			expect: func(m sqlmock.Sqlmock) {
+				m.ExpectExec("DELETE FROM users").WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			queries: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			mock.MatchExpectationsInOrder(false)
+			for range tt.queries {
+				mock.ExpectQuery(query).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+			}
+			if tt.expect != nil {
+				tt.expect(mock)
+			}
+			drv := NewCacheDriver(entsql.OpenDB(dialect.Postgres, db), CacheConfig{})
+			ctx := context.Background()
+			for _, step := range tt.steps {
+				if err := step(ctx, drv); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package driver
+
+import (
+	"context"
+	"runtime"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// callerSkip holds the prefixes of the functions that are never reported as
+// callers: ent, this module and the database/sql package.
+var callerSkip = []string{"entgo.io/ent/", "github.com/floatyun/entzlog/", "database/sql.", "runtime."}
+
+// WithCaller returns an option that logs the first application frame of the
+// statements as their caller, skipping ent, this module, database/sql and the
+// functions with the given prefixes.
+func WithCaller(skip ...string) Option {
+	return func(d *DebugDriver) {
+		d.callerSkip = append(append([]string{}, callerSkip...), skip...)
+	}
+}
+
+// caller returns the first frame of the call stack that is not skipped, or
+// an empty string if the caller is not configured or could not be found.
+func (d *DebugDriver) caller() string {
+	if d.callerSkip == nil {
+		return ""
+	}
+	var pcs [64]uintptr
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs[:])])
+	for {
+		f, more := frames.Next()
+		if f.Function != "" && !d.skipped(f.Function) {
+			return zapcore.EntryCaller{Defined: true, File: f.File, Line: f.Line}.TrimmedPath()
+		}
+		if !more {
+			return ""
+		}
+	}
+}
+
+// skipped reports whether the function is skipped by the caller lookup.
+func (d *DebugDriver) skipped(fn string) bool {
+	return hasPrefix(fn, d.callerSkip)
+}
+
+// hasPrefix reports whether the function starts with one of the prefixes.
+func hasPrefix(fn string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(fn, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// logStmt logs a statement executed by the driver, its transactions or
+// prepared statements, along with its type, its table, the fields carried
+// by the context and the fields configured by the options.
+func (d *DebugDriver) logStmt(ctx context.Context, msg, query string, args any, fields ...zap.Field) {
+	e := newLogEntry(d.reuseFields)
+	typ := StatementType(query)
+	e.fields = append(append(e.fields, fields...), zap.String("stmt_type", typ), e.tableField(query))
+	e.fields = ctxFields(ctx, e.fields)
+	e.fields = d.baggageFields(ctx, e.fields)
+	if actor, ok := ActorFromContext(ctx); ok && isWrite(typ) {
+		e.fields = append(e.fields, zap.String("actor", actor))
+	}
+	if c := d.caller(); c != "" {
+		e.fields = append(e.fields, zap.String("caller", c))
+	}
+	if d.goroutineID {
+		e.fields = append(e.fields, zap.Int64("goroutine_id", goroutineID()))
+	}
+	e.fields = d.deadlineFields(ctx, e.fields)
+	e.fields = append(e.fields, e.argsField(args))
+	e.write(ctx, d.log, d.reuseFields, msg)
+}
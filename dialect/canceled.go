@@ -0,0 +1,38 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+
+	"go.uber.org/zap"
+)
+
+// WithCanceledShortCircuit returns an option that fails the statements
+// issued with a context that is already canceled or expired with the error
+// and the cause of the context, without calling the underlying driver. These failures are
+// not counted as database failures by the driver.
+func WithCanceledShortCircuit() Option {
+	return func(d *DebugDriver) {
+		d.shortCircuit = true
+	}
+}
+
+// canceled logs the statements issued with a context that is already done,
+// with its cause. Like failures, these entries are always logged. It returns
+// the error of the context, wrapping its cause, if the statement must not be
+// executed.
+func (d *DebugDriver) canceled(ctx context.Context, txID, op, query string) error {
+	if ctx.Err() == nil {
+		return nil
+	}
+	cause := context.Cause(ctx)
+	d.log(ctx, d.opMsg(txID, op)+": context done before execution",
+		zap.NamedError("cause", cause), zap.Bool("skipped", d.shortCircuit), zap.String("query", query))
+	if d.shortCircuit {
+		if err := ctx.Err(); cause != err {
+			return fmt.Errorf("%w: %w", err, cause)
+		}
+		return cause
+	}
+	return nil
+}
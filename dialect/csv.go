@@ -0,0 +1,144 @@
+package driver
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CSV columns supported by CSVSink.
+const (
+	ColumnTimestamp   = "timestamp"
+	ColumnOp          = "op"
+	ColumnFingerprint = "fingerprint"
+	ColumnQuery       = "query"
+	ColumnDurationMS  = "duration_ms"
+	ColumnRows        = "rows"
+	ColumnStatus      = "status"
+	ColumnErrorClass  = "error_class"
+	ColumnTable       = "table"
+	ColumnTxID        = "tx_id"
+	ColumnTenant      = "tenant"
+	ColumnCaller      = "caller"
+)
+
+// CSVConfig configures a CSVSink.
+type CSVConfig struct {
+	// Path is the path of the written file. Required unless Writer is set.
+	Path string
+	// Rotate configures the rotation of the file. Each file starts with a header.
+	Rotate RotateConfig
+	// Writer overrides Path. The header is written once, when the sink is created.
+	Writer io.Writer
+	// Columns are the written columns. Defaults to timestamp, fingerprint,
+	// duration_ms, rows, status and table.
+	Columns []string
+	// Filter selects the written events. Defaults to the statements,
+	// i.e. transaction events are skipped.
+	Filter func(*Event) bool
+}
+
+// CSVSink is a Sink that writes the events as CSV records. Timestamps are
+// written in RFC 3339 format, unknown row counts as empty values, and the
+// tables of a statement are separated by spaces.
+type CSVSink struct {
+	cfg CSVConfig
+	mu  sync.Mutex
+	w   io.Writer
+}
+
+// NewCSVSink returns a new CSVSink, opening its file if needed.
+func NewCSVSink(cfg CSVConfig) (*CSVSink, error) {
+	if cfg.Columns == nil {
+		cfg.Columns = []string{ColumnTimestamp, ColumnFingerprint, ColumnDurationMS, ColumnRows, ColumnStatus, ColumnTable}
+	}
+	for _, c := range cfg.Columns {
+		if csvColumns[c] == nil {
+			return nil, fmt.Errorf("entzlog: unknown CSV column %q", c)
+		}
+	}
+	if cfg.Filter == nil {
+		cfg.Filter = func(e *Event) bool { return e.Query != "" }
+	}
+	header, err := csvLine(cfg.Columns)
+	if err != nil {
+		return nil, err
+	}
+	s := &CSVSink{cfg: cfg, w: cfg.Writer}
+	if s.w != nil {
+		_, err = s.w.Write(header)
+	} else {
+		s.w, err = openRotating(cfg.Path, cfg.Rotate, header)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// csvColumns holds the value functions of the supported columns.
+var csvColumns = map[string]func(*Event) string{
+	ColumnTimestamp:   func(e *Event) string { return e.Time.Format(time.RFC3339Nano) },
+	ColumnOp:          func(e *Event) string { return e.Op },
+	ColumnFingerprint: func(e *Event) string { return e.Fingerprint() },
+	ColumnQuery:       func(e *Event) string { return e.Query },
+	ColumnDurationMS: func(e *Event) string {
+		return strconv.FormatFloat(float64(e.Duration)/float64(time.Millisecond), 'f', 3, 64)
+	},
+	ColumnRows: func(e *Event) string {
+		if e.Rows < 0 {
+			return ""
+		}
+		return strconv.FormatInt(e.Rows, 10)
+	},
+	ColumnStatus:     func(e *Event) string { return e.Status() },
+	ColumnErrorClass: func(e *Event) string { return string(e.ErrorClass) },
+	ColumnTable:      func(e *Event) string { return strings.Join(queryTables(e.Query), " ") },
+	ColumnTxID:       func(e *Event) string { return e.TxID },
+	ColumnTenant:     func(e *Event) string { return e.Tenant },
+	ColumnCaller:     func(e *Event) string { return e.Caller },
+}
+
+// Write writes the event as a record if it is selected by the filter.
+func (s *CSVSink) Write(_ context.Context, e *Event) error {
+	if !s.cfg.Filter(e) {
+		return nil
+	}
+	record := make([]string, len(s.cfg.Columns))
+	for i, c := range s.cfg.Columns {
+		record[i] = csvColumns[c](e)
+	}
+	line, err := csvLine(record)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.w.Write(line)
+	return err
+}
+
+// Close closes the file of the sink. A configured Writer is not closed.
+func (s *CSVSink) Close() error {
+	if f, ok := s.w.(*rotatingFile); ok {
+		return f.Close()
+	}
+	return nil
+}
+
+// csvLine encodes the record as a CSV line.
+func csvLine(record []string) ([]byte, error) {
+	var b bytes.Buffer
+	w := csv.NewWriter(&b)
+	if err := w.Write(record); err != nil {
+		return nil, err
+	}
+	w.Flush()
+	return b.Bytes(), w.Error()
+}
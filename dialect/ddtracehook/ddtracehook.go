@@ -0,0 +1,130 @@
+// Package ddtracehook provides a driver.Hook recording the statements as
+// Datadog APM spans.
+package ddtracehook
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"entgo.io/ent/dialect"
+	"github.com/floatyun/entzlog/dialect"
+	"go.uber.org/zap"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+)
+
+// Config configures the Datadog hook.
+type Config struct {
+	// Dialect is the dialect of the hooked driver, e.g. dialect.Postgres.
+	// It names the spans ("postgres.query") and sets their db.system tag.
+	// Defaults to "sql".
+	Dialect string
+	// Service is the service name of the spans. Defaults to "<dialect>.db",
+	// e.g. "postgres.db".
+	Service string
+	// Baggage holds the keys of the OpenTelemetry baggage entries of the
+	// context added as tags to the spans. See driver.WithBaggage.
+	Baggage []string
+	// Tags, if set, is called before the span of each operation is finished,
+	// with the context, query and error of the operation, to add or modify
+	// the tags of the span, e.g. to tag it with a feature flag of the context.
+	Tags func(ctx context.Context, span ddtrace.Span, op, query string, err error)
+}
+
+// Hook records the operations of the driver as Datadog spans, following the
+// conventions of the dd-trace-go database integrations.
+type Hook struct {
+	cfg Config
+}
+
+// New returns a new Datadog hook. Use it with driver.WithHooks.
+func New(cfg Config) *Hook {
+	if cfg.Dialect == "" {
+		cfg.Dialect = "sql"
+	}
+	if cfg.Service == "" {
+		cfg.Service = cfg.Dialect + ".db"
+	}
+	return &Hook{cfg: cfg}
+}
+
+// spanKey is the context key of the span of an operation.
+type spanKey struct{}
+
+// Before starts the span of the operation.
+func (h *Hook) Before(ctx context.Context, op, query string, _ []any) context.Context {
+	opts := []ddtrace.StartSpanOption{
+		tracer.ServiceName(h.cfg.Service),
+		tracer.SpanType(ext.SpanTypeSQL),
+		tracer.Measured(),
+		tracer.Tag(ext.Component, "entzlog"),
+		tracer.Tag(ext.SpanKind, ext.SpanKindClient),
+		tracer.Tag(ext.DBSystem, dbSystem(h.cfg.Dialect)),
+		tracer.Tag("sql.query_type", op),
+	}
+	if query == "" {
+		opts = append(opts, tracer.ResourceName(op))
+	} else {
+		opts = append(opts, tracer.ResourceName(driver.SanitizeQuery(query)), tracer.Tag("db.fingerprint", driver.Fingerprint(query)))
+	}
+	if id, ok := driver.TxIDFromContext(ctx); ok {
+		opts = append(opts, tracer.Tag("db.tx_id", id))
+	}
+	if id, ok := driver.ShardFromContext(ctx); ok {
+		opts = append(opts, tracer.Tag("db.shard", id))
+	}
+	for _, a := range driver.BaggageFromContext(ctx, h.cfg.Baggage...) {
+		opts = append(opts, tracer.Tag(a.Key, a.Value))
+	}
+	span, ctx := tracer.StartSpanFromContext(ctx, h.cfg.Dialect+".query", opts...)
+	if _, ok := driver.TraceIDFromContext(ctx); !ok {
+		ctx = driver.WithTraceID(ctx, strconv.FormatUint(span.Context().TraceID(), 10))
+	}
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+// After finishes the span of the operation, with its error, if any.
+func (h *Hook) After(ctx context.Context, op, query string, _ []any, err error, _ time.Duration) {
+	span, ok := ctx.Value(spanKey{}).(ddtrace.Span)
+	if !ok {
+		return
+	}
+	if err != nil {
+		span.SetTag("error.type", string(driver.ClassifyError(err)))
+	}
+	if h.cfg.Tags != nil {
+		h.cfg.Tags(ctx, span, op, query, err)
+	}
+	span.Finish(tracer.WithError(err))
+}
+
+// dbSystem returns the db.system tag of the dialect.
+func dbSystem(name string) string {
+	switch name {
+	case dialect.Postgres:
+		return ext.DBSystemPostgreSQL
+	case dialect.MySQL:
+		return ext.DBSystemMySQL
+	case dialect.SQLite:
+		return "sqlite"
+	}
+	return name
+}
+
+// LogFunc returns a LogFunc adding the dd.trace_id and dd.span_id fields of
+// the span of the context, if any, to the entries logged by log, so the log
+// lines of the driver are correlated with the traces in Datadog.
+func LogFunc(log driver.LogFunc) driver.LogFunc {
+	return func(ctx context.Context, msg string, fields ...zap.Field) {
+		if span, ok := tracer.SpanFromContext(ctx); ok {
+			sc := span.Context()
+			fields = append(fields,
+				zap.String("dd.trace_id", strconv.FormatUint(sc.TraceID(), 10)),
+				zap.String("dd.span_id", strconv.FormatUint(sc.SpanID(), 10)),
+			)
+		}
+		log(ctx, msg, fields...)
+	}
+}
@@ -0,0 +1,31 @@
+package driver
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// WithDeadlineMargin returns an option that logs the time remaining before
+// the deadline of the statements, and flags those issued less than margin
+// before it.
+func WithDeadlineMargin(margin time.Duration) Option {
+	return func(d *DebugDriver) {
+		d.deadlineMargin = margin
+	}
+}
+
+// deadlineFields appends the deadline fields of the context to fields.
+func (d *DebugDriver) deadlineFields(ctx context.Context, fields []zap.Field) []zap.Field {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return fields
+	}
+	remaining := time.Until(deadline)
+	fields = append(fields, zap.Duration("deadline_remaining", remaining))
+	if remaining < d.deadlineMargin {
+		fields = append(fields, zap.Bool("deadline_short", true))
+	}
+	return fields
+}
@@ -0,0 +1,139 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+
+	"entgo.io/ent/dialect"
+	entsql "entgo.io/ent/dialect/sql"
+	"go.uber.org/zap"
+)
+
+// maxTxHistory is the number of statements of a transaction kept
+// to be logged with its deadlocks.
+const maxTxHistory = 50
+
+// WithDeadlockDetail returns an option that logs the statements failing with
+// a deadlock with its detail, from the error on Postgres or from SHOW ENGINE
+// INNODB STATUS on MySQL, and the previous statements of their transaction.
+func WithDeadlockDetail() Option {
+	return func(d *DebugDriver) {
+		d.deadlocks = true
+	}
+}
+
+// txHistory is the statements executed by a transaction.
+type txHistory struct {
+	mu    sync.Mutex
+	stmts []string
+}
+
+// record adds the statement of the transaction to its history.
+func (d *DebugDriver) record(txID, query string) {
+	if !d.deadlocks || txID == "" || query == "" {
+		return
+	}
+	h, _ := d.histories.LoadOrStore(txID, &txHistory{})
+	th := h.(*txHistory)
+	th.mu.Lock()
+	defer th.mu.Unlock()
+	if len(th.stmts) == maxTxHistory {
+		th.stmts = append(th.stmts[:0], th.stmts[1:]...)
+	}
+	th.stmts = append(th.stmts, query)
+}
+
+// history returns the statements executed by the transaction.
+func (d *DebugDriver) history(txID string) []string {
+	h, ok := d.histories.Load(txID)
+	if !ok {
+		return nil
+	}
+	th := h.(*txHistory)
+	th.mu.Lock()
+	defer th.mu.Unlock()
+	return append([]string(nil), th.stmts...)
+}
+
+// endHistory drops the history of the transaction.
+func (d *DebugDriver) endHistory(txID string) {
+	d.histories.Delete(txID)
+}
+
+// logDeadlock logs the statement that failed with a deadlock with the detail
+// of the deadlock and the history of its transaction.
+func (d *DebugDriver) logDeadlock(ctx context.Context, txID, op, query string, err error) {
+	fields := []zap.Field{zap.String("query", query), zap.String("tx_id", txID), zap.Error(err)}
+	switch detail, derr := d.deadlockDetail(ctx, err); {
+	case derr != nil:
+		fields = append(fields, zap.NamedError("deadlock_detail_error", derr))
+	case detail != "":
+		fields = append(fields, zap.String("deadlock_detail", detail))
+	}
+	if txID != "" {
+		fields = append(fields, zap.Strings("tx_statements", d.history(txID)))
+	}
+	d.log(ctx, d.opMsg(txID, op)+": deadlock", ctxFields(ctx, fields)...)
+}
+
+// deadlockDetail returns the detail of the deadlock reported by the database.
+func (d *DebugDriver) deadlockDetail(ctx context.Context, err error) (string, error) {
+	switch d.Dialect() {
+	case dialect.Postgres:
+		return errorDetail(err), nil
+	case dialect.MySQL:
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), lockDiagnosticsTimeout)
+		defer cancel()
+		return innodbDeadlock(ctx, d.Driver)
+	}
+	return "", nil
+}
+
+// errorDetail returns the Detail field of the Postgres error in the chain of
+// err, as reported by both pq and pgx, or an empty string.
+func errorDetail(err error) string {
+	for ; err != nil; err = errors.Unwrap(err) {
+		v := reflect.Indirect(reflect.ValueOf(err))
+		if v.Kind() != reflect.Struct {
+			continue
+		}
+		if f := v.FieldByName("Detail"); f.IsValid() && f.Kind() == reflect.String {
+			return f.String()
+		}
+	}
+	return ""
+}
+
+// innodbDeadlock returns the LATEST DETECTED DEADLOCK section of the InnoDB
+// status, or an empty string if no deadlock was detected since the start of
+// the server.
+func innodbDeadlock(ctx context.Context, ex dialect.ExecQuerier) (string, error) {
+	var rows entsql.Rows
+	if err := ex.Query(ctx, "SHOW ENGINE INNODB STATUS", []any{}, &rows); err != nil {
+		return "", err
+	}
+	defer rows.Close()
+	var typ, name, status string
+	if rows.Next() {
+		if err := rows.Scan(&typ, &name, &status); err != nil {
+			return "", err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+	const header = "LATEST DETECTED DEADLOCK"
+	i := strings.Index(status, header)
+	if i == -1 {
+		return "", nil
+	}
+	section := status[i+len(header):]
+	// The section is followed by the TRANSACTIONS one, preceded by a line of dashes.
+	if j := strings.Index(section, "\nTRANSACTIONS\n"); j != -1 {
+		section = section[:j]
+	}
+	return strings.Trim(section, "-\n "), nil
+}
@@ -0,0 +1,52 @@
+package driver
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// WithMetrics sets the Metrics receiving the measurements of the driver.
+func WithMetrics(m Metrics) Option {
+	return func(d *DebugDriver) {
+		d.metrics = m
+	}
+}
+
+// WithDegradation returns an option that reports the database as degraded
+// after threshold consecutive failures, and calls fn if not nil, until an
+// operation succeeds.
+func WithDegradation(threshold int, fn func(ctx context.Context, failures int64, err error)) Option {
+	return func(d *DebugDriver) {
+		d.degradeAfter = int64(threshold)
+		d.onDegraded = fn
+	}
+}
+
+// track counts the consecutive failures of the driver, and reports the
+// transitions from and to the degraded state.
+func (d *DebugDriver) track(ctx context.Context, err error) {
+	if d.degradeAfter <= 0 {
+		return
+	}
+	switch classifyContextError(ctx, err) {
+	case "":
+		if n := d.failures.Swap(0); n >= d.degradeAfter {
+			d.log(ctx, "driver: database recovered", zap.Int64("failures", n))
+			d.metrics.Gauge(ctx, "entzlog_degraded", 0, d.metricLabels()...)
+			d.emit(ctx, &Event{Time: time.Now(), Dialect: d.Dialect(), Op: OpRecovered, Rows: -1, Failures: n})
+		}
+	case ErrorUnique, ErrorForeignKey, ErrorConstraint, ErrorCanceled:
+	default:
+		if n := d.failures.Add(1); n == d.degradeAfter {
+			d.log(ctx, "driver: database degraded", zap.Int64("failures", n), zap.Error(err))
+			d.metrics.Gauge(ctx, "entzlog_degraded", 1, d.metricLabels()...)
+			d.metrics.Count(ctx, "entzlog_degraded_total", 1, d.metricLabels()...)
+			d.emit(ctx, &Event{Time: time.Now(), Dialect: d.Dialect(), Op: OpDegraded, Rows: -1, Err: err, ErrorClass: classifyContextError(ctx, err), Failures: n})
+			if d.onDegraded != nil {
+				d.onDegraded(ctx, n, err)
+			}
+		}
+	}
+}
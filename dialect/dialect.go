@@ -3,7 +3,11 @@ package driver
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"entgo.io/ent/dialect"
 	"github.com/google/uuid"
@@ -11,134 +15,477 @@ import (
 )
 
 type Driver = dialect.Driver
+
+// LogFunc is the logging function used by the drivers in this package.
+// It may retain the fields it receives, unless the DebugDriver is configured
+// with WithFieldReuse.
+type LogFunc func(ctx context.Context, msg string, fields ...zap.Field)
+
+// nopLog is the LogFunc used when none is configured.
+func nopLog(context.Context, string, ...zap.Field) {}
+
 type DebugDriver struct {
-	Driver // underlying driver.
-	log    func(ctx context.Context, msg string, fields ...zap.Field)
+	Driver                   // underlying driver.
+	log        LogFunc       // log function.
+	started    time.Time     // creation time.
+	statements atomic.Int64  // number of executed statements.
+	txs        atomic.Int64  // number of started transactions.
+	hooks      []Hook        // hooks called around operations.
+	metrics    Metrics       // metrics receiver.
+	sinks      []Sink        // event sinks.
+	slow       time.Duration // slow operation threshold.
+
+	logFilter      func(ctx context.Context) bool      // operations logging filter.
+	callerSkip     []string                            // prefixes of the functions skipped by the caller lookup.
+	stackSkip      []string                            // prefixes of the functions skipped by the stack traces.
+	goroutineID    bool                                // whether the goroutine ids are logged.
+	attrs          []Attr                              // static attributes of the events.
+	auditTable     string                              // table of the audit records.
+	chain          *auditChain                         // hash chain of the audit records, if enabled.
+	sensitive      map[string]bool                     // lower-cased names of the sensitive tables.
+	accessLog      *zap.Logger                         // logger of the reads of the sensitive tables.
+	sessionVars    []SessionVar                        // session variables set by the transactions.
+	openRows       time.Duration                       // time after which the rows left open are logged.
+	lastInsertID   bool                                // whether the last inserted ids are logged.
+	redactID       func(table string, id int64) string // redaction of the last inserted ids.
+	shortCircuit   bool                                // whether the statements of done contexts are skipped.
+	deadlineMargin time.Duration                       // remaining time before the deadline under which statements are flagged.
+	recoverPanics  bool                                // whether the panics of the statements are recovered.
+	panicToError   bool                                // whether the recovered panics are returned as errors.
+	pprofLabels    bool                                // whether the statements are executed with pprof labels.
+	traced         bool                                // whether the operations are recorded in the execution traces.
+	tasks          sync.Map                            // trace tasks of the transactions, by id.
+	drain          drain                               // statements in flight and open transactions, waited for by Shutdown.
+	baggage        []string                            // keys of the baggage entries attached to the entries and events.
+	serverTimeout  bool                                // whether the deadlines of the contexts are passed to the database.
+	serverTimeouts ServerTimeouts                      // static server-side timeouts of the statements.
+	lockDiag       bool                                // whether the lock waits of the slow statements are logged.
+	deadlocks      bool                                // whether the deadlocks are logged with their detail.
+	histories      sync.Map                            // statements of the transactions, by id, logged with their deadlocks.
+	plans          *planTracker                        // last plans of the fingerprints, if tracked.
+	poolWait       bool                                // whether the connections of the statements are acquired explicitly.
+	poolWarnings   bool                                // whether the pool exhaustions are logged.
+	poolWarn       time.Duration                       // pool wait from which the pool exhaustion is logged.
+	poolWarnEvery  time.Duration                       // minimum interval between the pool exhaustion warnings.
+	poolWarned     atomic.Int64                        // time of the last pool exhaustion warning, in unix nanoseconds.
+	reuseFields    bool                                // whether the fields of the entries are reused once logged.
+
+	degradeAfter int64                                                // consecutive failures before degradation.
+	onDegraded   func(ctx context.Context, failures int64, err error) // degradation callback.
+	failures     atomic.Int64                                         // consecutive failures.
 }
 
 // DebugWithContext gets a driver and a logging function, and returns
 // a new debugged-driver that prints all outgoing operations with context.
-func DebugWithContext(d Driver, logger func(ctx context.Context, msg string, fields ...zap.Field)) Driver {
-	drv := &DebugDriver{d, logger}
+// A nil logger disables the logging, and the driver is returned untouched
+// if no options are given, as are all drivers in builds with the entzlog_noop
+// tag.
+func DebugWithContext(d Driver, logger LogFunc, opts ...Option) Driver {
+	if noop || logger == nil && len(opts) == 0 {
+		return d
+	}
+	drv := newDebugDriver(d, logger, opts...)
+	return drv
+}
+
+// newDebugDriver returns a new DebugDriver. All entries logged by the driver,
+// its transactions and statements carry the dialect of the underlying driver.
+func newDebugDriver(d Driver, logger LogFunc, opts ...Option) *DebugDriver {
+	if noop {
+		logger, opts = nil, nil
+	}
+	drv := &DebugDriver{Driver: d, log: nopLog, started: time.Now(), metrics: nopMetrics{}}
+	if logger != nil {
+		drv.log = withFields(logger, zap.String("dialect", d.Dialect()))
+	} else {
+		drv.logFilter = func(context.Context) bool { return false }
+	}
+	for _, opt := range opts {
+		opt(drv)
+	}
+	if len(drv.attrs) > 0 {
+		drv.log = withFields(drv.log, drv.attrFields()...)
+	}
 	return drv
 }
 
 // Exec logs its params and calls the underlying driver Exec method.
-func (d *DebugDriver) Exec(ctx context.Context, query string, args, v any) error {
-	d.log(ctx, "driver.Exec", zap.String("query", query), zap.Any("args", args))
-	return d.Driver.Exec(ctx, query, args, v)
+func (d *DebugDriver) Exec(ctx context.Context, query string, args, v any) (err error) {
+	seq, err := d.enter("", "Exec", query)
+	if err != nil {
+		return err
+	}
+	defer d.leave(seq)
+	d.statements.Add(1)
+	if err := d.canceled(ctx, "", "Exec", query); err != nil {
+		return err
+	}
+	if d.logs(ctx) {
+		d.logStmt(ctx, "driver.Exec", query, args, zap.String("query", query))
+	}
+	ex, release, err := d.acquire(ctx, "Exec", query)
+	if err != nil {
+		return d.done(ctx, "", "Exec", query, err)
+	}
+	defer release()
+	if !d.hooked(ctx) {
+		return d.logInsertID(ctx, "", "Exec", query, v, d.done(ctx, "", "Exec", query, ex.Exec(ctx, query, args, v)))
+	}
+	return d.logInsertID(ctx, "", "Exec", query, v, d.run(ctx, ex, "", "Exec", query, args, v, func(ctx context.Context) error {
+		return ex.Exec(ctx, query, args, v)
+	}))
 }
 
-// ExecContext logs its params and calls the underlying driver ExecContext method if it is supported.
-func (d *DebugDriver) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
-	drv, ok := d.Driver.(interface {
-		ExecContext(context.Context, string, ...any) (sql.Result, error)
-	})
-	if !ok {
-		return nil, fmt.Errorf("Driver.ExecContext is not supported")
+// ExecContext logs its params and calls the underlying driver ExecContext method.
+// Drivers without an ExecContext method are called through their Exec method.
+func (d *DebugDriver) ExecContext(ctx context.Context, query string, args ...any) (res sql.Result, err error) {
+	seq, err := d.enter("", "ExecContext", query)
+	if err != nil {
+		return nil, err
+	}
+	defer d.leave(seq)
+	d.statements.Add(1)
+	if err := d.canceled(ctx, "", "ExecContext", query); err != nil {
+		return nil, err
+	}
+	if d.logs(ctx) {
+		d.logStmt(ctx, "driver.ExecContext", query, args, zap.String("query", query))
+	}
+	ex, release, err := d.acquire(ctx, "ExecContext", query)
+	if err != nil {
+		return nil, d.done(ctx, "", "ExecContext", query, err)
 	}
-	d.log(ctx, "driver.ExecContext", zap.String("query", query), zap.Any("args", args))
-	return drv.ExecContext(ctx, query, args...)
+	defer release()
+	if !d.hooked(ctx) {
+		res, err := execContext(ctx, ex, query, args)
+		return res, d.logInsertID(ctx, "", "ExecContext", query, res, d.done(ctx, "", "ExecContext", query, err))
+	}
+	err = d.run(ctx, ex, "", "ExecContext", query, args, &res, func(ctx context.Context) (err error) {
+		res, err = execContext(ctx, ex, query, args)
+		return err
+	})
+	return res, d.logInsertID(ctx, "", "ExecContext", query, res, err)
 }
 
 // Query logs its params and calls the underlying driver Query method.
-func (d *DebugDriver) Query(ctx context.Context, query string, args, v any) error {
-	d.log(ctx, "driver.Query", zap.String("query", query), zap.Any("args", args))
-	return d.Driver.Query(ctx, query, args, v)
+func (d *DebugDriver) Query(ctx context.Context, query string, args, v any) (err error) {
+	seq, err := d.enter("", "Query", query)
+	if err != nil {
+		return err
+	}
+	defer d.leave(seq)
+	d.statements.Add(1)
+	if err := d.canceled(ctx, "", "Query", query); err != nil {
+		return err
+	}
+	if d.logs(ctx) {
+		d.logStmt(ctx, "driver.Query", query, args, zap.String("query", query))
+	}
+	ex, release, err := d.acquire(ctx, "Query", query)
+	if err != nil {
+		return d.done(ctx, "", "Query", query, err)
+	}
+	defer func() { release() }()
+	start := time.Now()
+	if !d.hooked(ctx) {
+		return d.countRows(ctx, start, "", "Query", query, v, d.done(ctx, "", "Query", query, releaseRows(v, &release, ex.Query(ctx, d.timeoutQuery(ctx, query), args, v))))
+	}
+	return d.countRows(ctx, start, "", "Query", query, v, d.run(ctx, ex, "", "Query", query, args, v, func(ctx context.Context) error {
+		return d.access(ctx, "", query, v, releaseRows(v, &release, ex.Query(ctx, d.timeoutQuery(ctx, query), args, v)))
+	}))
 }
 
-// QueryContext logs its params and calls the underlying driver QueryContext method if it is supported.
-func (d *DebugDriver) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
-	drv, ok := d.Driver.(interface {
-		QueryContext(context.Context, string, ...any) (*sql.Rows, error)
-	})
-	if !ok {
-		return nil, fmt.Errorf("Driver.QueryContext is not supported")
+// QueryContext logs its params and calls the underlying driver QueryContext method.
+// Drivers without a QueryContext method are called through their Query method.
+func (d *DebugDriver) QueryContext(ctx context.Context, query string, args ...any) (rows *sql.Rows, err error) {
+	seq, err := d.enter("", "QueryContext", query)
+	if err != nil {
+		return nil, err
+	}
+	defer d.leave(seq)
+	d.statements.Add(1)
+	if err := d.canceled(ctx, "", "QueryContext", query); err != nil {
+		return nil, err
 	}
-	d.log(ctx, "driver.QueryContext", zap.String("query", query), zap.Any("args", args))
-	return drv.QueryContext(ctx, query, args...)
+	if d.logs(ctx) {
+		d.logStmt(ctx, "driver.QueryContext", query, args, zap.String("query", query))
+	}
+	if !d.hooked(ctx) {
+		rows, err := queryContext(ctx, d.Driver, d.timeoutQuery(ctx, query), args)
+		return rows, d.done(ctx, "", "QueryContext", query, err)
+	}
+	err = d.run(ctx, d.Driver, "", "QueryContext", query, args, nil, func(ctx context.Context) (err error) {
+		rows, err = queryContext(ctx, d.Driver, d.timeoutQuery(ctx, query), args)
+		return d.access(ctx, "", query, nil, err)
+	})
+	return rows, err
 }
 
 // Tx adds an log-id for the transaction and calls the underlying driver Tx command.
 func (d *DebugDriver) Tx(ctx context.Context) (dialect.Tx, error) {
-	tx, err := d.Driver.Tx(ctx)
+	var tx dialect.Tx
+	seq, err := d.enter("", "Tx", "")
 	if err != nil {
 		return nil, err
 	}
+	defer d.leave(seq)
 	id := uuid.New().String()
-	d.log(ctx, fmt.Sprintf("driver.Tx(%s): started"))
-	return &DebugTx{tx, id, d.log, ctx}, nil
+	err = d.run(ctx, nil, id, "Tx", "", nil, nil, func(ctx context.Context) (err error) {
+		tx, err = d.Driver.Tx(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if d.logs(ctx) {
+		d.logTx(ctx, fmt.Sprintf("driver.Tx(%s): started", id))
+	}
+	if len(d.sessionVars) > 0 {
+		if err := d.setSession(ctx, tx, id); err != nil {
+			return nil, err
+		}
+	}
+	if err := d.setTimeout(ctx, tx, id); err != nil {
+		return nil, err
+	}
+	d.txs.Add(1)
+	d.openTx(id)
+	d.startTask(ctx, id)
+	return &DebugTx{Tx: tx, id: id, log: d.log, ctx: ctx, drv: d}, nil
 }
 
 // BeginTx adds an log-id for the transaction and calls the underlying driver BeginTx command if it is supported.
+// Without options, drivers without a BeginTx method are called through their Tx method.
 func (d *DebugDriver) BeginTx(ctx context.Context, opts *sql.TxOptions) (dialect.Tx, error) {
-	drv, ok := d.Driver.(interface {
-		BeginTx(context.Context, *sql.TxOptions) (dialect.Tx, error)
+	var tx dialect.Tx
+	seq, err := d.enter("", "BeginTx", "")
+	if err != nil {
+		return nil, err
+	}
+	defer d.leave(seq)
+	id := uuid.New().String()
+	err = d.run(ctx, nil, id, "BeginTx", "", nil, nil, func(ctx context.Context) (err error) {
+		tx, err = beginTx(ctx, d.Driver, opts)
+		return err
 	})
+	if err != nil {
+		return nil, err
+	}
+	if d.logs(ctx) {
+		d.logTx(ctx, fmt.Sprintf("driver.BeginTx(%s): started", id))
+	}
+	if len(d.sessionVars) > 0 {
+		if err := d.setSession(ctx, tx, id); err != nil {
+			return nil, err
+		}
+	}
+	if err := d.setTimeout(ctx, tx, id); err != nil {
+		return nil, err
+	}
+	d.txs.Add(1)
+	d.openTx(id)
+	d.startTask(ctx, id)
+	return &DebugTx{Tx: tx, id: id, log: d.log, ctx: ctx, drv: d}, nil
+}
+
+// PrepareContext logs its params and creates a prepared statement on the underlying driver if it is supported.
+// The executions of the returned statement are logged as well.
+func (d *DebugDriver) PrepareContext(ctx context.Context, query string) (*DebugStmt, error) {
+	prepare, ok := preparer(d.Driver)
 	if !ok {
-		return nil, fmt.Errorf("Driver.BeginTx is not supported")
+		return nil, unsupported("Driver.PrepareContext")
 	}
-	tx, err := drv.BeginTx(ctx, opts)
+	stmt, err := prepare(ctx, query)
 	if err != nil {
 		return nil, err
 	}
 	id := uuid.New().String()
-	d.log(ctx, fmt.Sprintf("driver.BeginTx(%s): started", id))
-	return &DebugTx{tx, id, d.log, ctx}, nil
+	if d.logs(ctx) {
+		d.log(ctx, fmt.Sprintf("driver.PrepareContext(%s)", id), zap.String("query", query))
+	}
+	return &DebugStmt{Stmt: stmt, id: id, query: query, log: d.log, drv: d, ex: d.Driver}, nil
+}
+
+// Close logs the number of operations served by the driver and its uptime,
+// and calls the underlying driver Close method and the Close method of its sinks.
+func (d *DebugDriver) Close() error {
+	d.log(context.Background(), "driver.Close",
+		zap.Int64("statements", d.statements.Load()),
+		zap.Int64("transactions", d.txs.Load()),
+		zap.Duration("uptime", time.Since(d.started)),
+	)
+	errs := []error{d.Driver.Close()}
+	for _, s := range d.sinks {
+		errs = append(errs, s.Close())
+	}
+	return errors.Join(errs...)
 }
 
 // DebugTx is a transaction implementation that logs all transaction operations.
 type DebugTx struct {
-	dialect.Tx        // underlying transaction.
-	id         string // transaction logging id.
-	log        func(ctx context.Context, msg string, fields ...zap.Field)
-	ctx        context.Context // underlying transaction context.
+	dialect.Tx                  // underlying transaction.
+	id          string          // transaction logging id.
+	log         LogFunc         // log function.
+	ctx         context.Context // underlying transaction context.
+	drv         *DebugDriver    // driver that started the transaction.
+	timeout     string          // statement_timeout overridden for the last statement, if any. See WithServerTimeouts.
+	baseTimeout string          // statement_timeout of the transaction, restored after the overrides.
 }
 
 // Exec logs its params and calls the underlying transaction Exec method.
-func (d *DebugTx) Exec(ctx context.Context, query string, args, v any) error {
-	d.log(ctx, fmt.Sprintf("Tx(%s).Exec: query=%v", d.id, query), zap.Any("args", args))
-	return d.Tx.Exec(ctx, query, args, v)
+func (d *DebugTx) Exec(ctx context.Context, query string, args, v any) (err error) {
+	seq, err := d.drv.enter(d.id, "Exec", query)
+	if err != nil {
+		return err
+	}
+	defer d.drv.leave(seq)
+	d.drv.statements.Add(1)
+	if err := d.drv.canceled(ctx, d.id, "Exec", query); err != nil {
+		return err
+	}
+	if d.drv.logs(ctx) {
+		d.drv.logStmt(ctx, fmt.Sprintf("Tx(%s).Exec: query=%v", d.id, query), query, args)
+	}
+	if err := d.limitStatement(ctx, query); err != nil {
+		return d.drv.done(ctx, d.id, "Exec", query, err)
+	}
+	if !d.drv.hooked(ctx) {
+		return d.drv.logInsertID(ctx, d.id, "Exec", query, v, d.drv.done(ctx, d.id, "Exec", query, d.Tx.Exec(ctx, query, args, v)))
+	}
+	return d.drv.logInsertID(ctx, d.id, "Exec", query, v, d.drv.run(ctx, d.Tx, d.id, "Exec", query, args, v, func(ctx context.Context) error {
+		return d.Tx.Exec(ctx, query, args, v)
+	}))
 }
 
-// ExecContext logs its params and calls the underlying transaction ExecContext method if it is supported.
-func (d *DebugTx) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
-	drv, ok := d.Tx.(interface {
-		ExecContext(context.Context, string, ...any) (sql.Result, error)
-	})
-	if !ok {
-		return nil, fmt.Errorf("Tx.ExecContext is not supported")
+// ExecContext logs its params and calls the underlying transaction ExecContext method.
+func (d *DebugTx) ExecContext(ctx context.Context, query string, args ...any) (res sql.Result, err error) {
+	seq, err := d.drv.enter(d.id, "ExecContext", query)
+	if err != nil {
+		return nil, err
+	}
+	defer d.drv.leave(seq)
+	d.drv.statements.Add(1)
+	if err := d.drv.canceled(ctx, d.id, "ExecContext", query); err != nil {
+		return nil, err
+	}
+	if d.drv.logs(ctx) {
+		d.drv.logStmt(ctx, fmt.Sprintf("Tx(%s).ExecContext: query=%v", d.id, query), query, args)
+	}
+	if err := d.limitStatement(ctx, query); err != nil {
+		return nil, d.drv.done(ctx, d.id, "ExecContext", query, err)
+	}
+	if !d.drv.hooked(ctx) {
+		res, err := execContext(ctx, d.Tx, query, args)
+		return res, d.drv.logInsertID(ctx, d.id, "ExecContext", query, res, d.drv.done(ctx, d.id, "ExecContext", query, err))
 	}
-	d.log(ctx, fmt.Sprintf("Tx(%s).ExecContext: query=%v", d.id, query), zap.Any("args", args))
-	return drv.ExecContext(ctx, query, args...)
+	err = d.drv.run(ctx, d.Tx, d.id, "ExecContext", query, args, &res, func(ctx context.Context) (err error) {
+		res, err = execContext(ctx, d.Tx, query, args)
+		return err
+	})
+	return res, d.drv.logInsertID(ctx, d.id, "ExecContext", query, res, err)
 }
 
 // Query logs its params and calls the underlying transaction Query method.
-func (d *DebugTx) Query(ctx context.Context, query string, args, v any) error {
-	d.log(ctx, fmt.Sprintf("Tx(%s).Query: query=%v", d.id, query), zap.Any("args", args))
-	return d.Tx.Query(ctx, query, args, v)
+func (d *DebugTx) Query(ctx context.Context, query string, args, v any) (err error) {
+	seq, err := d.drv.enter(d.id, "Query", query)
+	if err != nil {
+		return err
+	}
+	defer d.drv.leave(seq)
+	d.drv.statements.Add(1)
+	if err := d.drv.canceled(ctx, d.id, "Query", query); err != nil {
+		return err
+	}
+	if d.drv.logs(ctx) {
+		d.drv.logStmt(ctx, fmt.Sprintf("Tx(%s).Query: query=%v", d.id, query), query, args)
+	}
+	if err := d.limitStatement(ctx, query); err != nil {
+		return d.drv.done(ctx, d.id, "Query", query, err)
+	}
+	start := time.Now()
+	if !d.drv.hooked(ctx) {
+		return d.drv.countRows(ctx, start, d.id, "Query", query, v, d.drv.done(ctx, d.id, "Query", query, d.Tx.Query(ctx, d.drv.timeoutQuery(ctx, query), args, v)))
+	}
+	return d.drv.countRows(ctx, start, d.id, "Query", query, v, d.drv.run(ctx, d.Tx, d.id, "Query", query, args, v, func(ctx context.Context) error {
+		return d.drv.access(ctx, d.id, query, v, d.Tx.Query(ctx, d.drv.timeoutQuery(ctx, query), args, v))
+	}))
 }
 
-// QueryContext logs its params and calls the underlying transaction QueryContext method if it is supported.
-func (d *DebugTx) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
-	drv, ok := d.Tx.(interface {
-		QueryContext(context.Context, string, ...any) (*sql.Rows, error)
+// QueryContext logs its params and calls the underlying transaction QueryContext method.
+func (d *DebugTx) QueryContext(ctx context.Context, query string, args ...any) (rows *sql.Rows, err error) {
+	seq, err := d.drv.enter(d.id, "QueryContext", query)
+	if err != nil {
+		return nil, err
+	}
+	defer d.drv.leave(seq)
+	d.drv.statements.Add(1)
+	if err := d.drv.canceled(ctx, d.id, "QueryContext", query); err != nil {
+		return nil, err
+	}
+	if d.drv.logs(ctx) {
+		d.drv.logStmt(ctx, fmt.Sprintf("Tx(%s).QueryContext: query=%v", d.id, query), query, args)
+	}
+	if err := d.limitStatement(ctx, query); err != nil {
+		return nil, d.drv.done(ctx, d.id, "QueryContext", query, err)
+	}
+	if !d.drv.hooked(ctx) {
+		rows, err := queryContext(ctx, d.Tx, d.drv.timeoutQuery(ctx, query), args)
+		return rows, d.drv.done(ctx, d.id, "QueryContext", query, err)
+	}
+	err = d.drv.run(ctx, d.Tx, d.id, "QueryContext", query, args, nil, func(ctx context.Context) (err error) {
+		rows, err = queryContext(ctx, d.Tx, d.drv.timeoutQuery(ctx, query), args)
+		return d.drv.access(ctx, d.id, query, nil, err)
 	})
+	return rows, err
+}
+
+// PrepareContext logs its params and creates a prepared statement on the underlying transaction if it is supported.
+// The executions of the returned statement are logged as well.
+func (d *DebugTx) PrepareContext(ctx context.Context, query string) (*DebugStmt, error) {
+	prepare, ok := preparer(d.Tx)
 	if !ok {
-		return nil, fmt.Errorf("Tx.QueryContext is not supported")
+		return nil, unsupported("Tx.PrepareContext")
+	}
+	stmt, err := prepare(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	id := uuid.New().String()
+	if d.drv.logs(ctx) {
+		d.log(ctx, fmt.Sprintf("Tx(%s).PrepareContext(%s): query=%v", d.id, id, query))
 	}
-	d.log(ctx, fmt.Sprintf("Tx(%s).QueryContext: query=%v", d.id, query), zap.Any("args", args))
-	return drv.QueryContext(ctx, query, args...)
+	return &DebugStmt{Stmt: stmt, id: id, query: query, log: d.log, drv: d.drv, txID: d.id, ex: d.Tx}, nil
+}
+
+// Dialect returns the dialect of the driver that started the transaction.
+// It can be used for dialect-specific statements within the transaction.
+func (d *DebugTx) Dialect() string {
+	return d.drv.Dialect()
 }
 
 // Commit logs this step and calls the underlying transaction Commit method.
 func (d *DebugTx) Commit() error {
-	d.log(d.ctx, fmt.Sprintf("Tx(%s): committed", d.id))
-	return d.Tx.Commit()
+	if d.drv.logs(d.ctx) {
+		d.drv.logTx(d.ctx, fmt.Sprintf("Tx(%s): committed", d.id))
+	}
+	err := d.drv.run(d.ctx, nil, d.id, "Commit", "", nil, nil, func(ctx context.Context) error {
+		if d.drv.chain != nil {
+			return d.drv.commitChained(ctx, d.Tx, d.id)
+		}
+		return d.Tx.Commit()
+	})
+	d.drv.endTx(d.id)
+	return err
 }
 
 // Rollback logs this step and calls the underlying transaction Rollback method.
 func (d *DebugTx) Rollback() error {
-	d.log(d.ctx, fmt.Sprintf("Tx(%s): rollbacked", d.id))
-	return d.Tx.Rollback()
+	if d.drv.logs(d.ctx) {
+		d.drv.logTx(d.ctx, fmt.Sprintf("Tx(%s): rollbacked", d.id))
+	}
+	err := d.drv.run(d.ctx, nil, d.id, "Rollback", "", nil, nil, func(context.Context) error {
+		return d.Tx.Rollback()
+	})
+	d.drv.endTx(d.id)
+	return err
 }
@@ -0,0 +1,304 @@
+package driver
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"entgo.io/ent/dialect"
+	entsql "entgo.io/ent/dialect/sql"
+	"go.uber.org/zap"
+)
+
+// DryRunConfig configures a DryRunDriver.
+type DryRunConfig struct {
+	// Log is the log function of the skipped statements. Optional.
+	Log LogFunc
+}
+
+// DryRunDriver is a driver that logs the statements that may write, with
+// their arguments interpolated, without executing them, and executes the
+// plain reads. The skipped statements return synthetic results.
+type DryRunDriver struct {
+	Driver // underlying driver.
+	cfg    DryRunConfig
+	lastID atomic.Int64
+}
+
+// NewDryRunDriver returns a new DryRunDriver wrapping the given driver.
+func NewDryRunDriver(d Driver, cfg DryRunConfig) *DryRunDriver {
+	if cfg.Log == nil {
+		cfg.Log = nopLog
+	}
+	return &DryRunDriver{Driver: d, cfg: cfg}
+}
+
+// Exec skips the write statements and calls the underlying driver Exec method otherwise.
+func (d *DryRunDriver) Exec(ctx context.Context, query string, args, v any) error {
+	return d.exec(ctx, d.Driver, "dryrun.Exec", query, args, v)
+}
+
+// ExecContext skips the write statements and calls the underlying driver ExecContext method otherwise.
+func (d *DryRunDriver) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return d.execContext(ctx, d.Driver, "dryrun.ExecContext", query, args)
+}
+
+// Query skips the write statements and calls the underlying driver Query method otherwise.
+func (d *DryRunDriver) Query(ctx context.Context, query string, args, v any) error {
+	return d.query(ctx, d.Driver, "dryrun.Query", query, args, v)
+}
+
+// QueryContext skips the write statements and calls the underlying driver QueryContext method otherwise.
+func (d *DryRunDriver) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return d.queryContext(ctx, d.Driver, "dryrun.QueryContext", query, args)
+}
+
+// Tx starts a transaction skipping the write statements.
+func (d *DryRunDriver) Tx(ctx context.Context) (dialect.Tx, error) {
+	tx, err := d.Driver.Tx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &dryRunTx{Tx: tx, drv: d}, nil
+}
+
+// BeginTx starts a transaction with options skipping the write statements,
+// if it is supported by the underlying driver.
+func (d *DryRunDriver) BeginTx(ctx context.Context, opts *sql.TxOptions) (dialect.Tx, error) {
+	tx, err := beginTx(ctx, d.Driver, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &dryRunTx{Tx: tx, drv: d}, nil
+}
+
+// dryRunTx is a transaction of a DryRunDriver.
+type dryRunTx struct {
+	dialect.Tx
+	drv *DryRunDriver
+}
+
+// Exec skips the write statements and calls the underlying transaction Exec method otherwise.
+func (tx *dryRunTx) Exec(ctx context.Context, query string, args, v any) error {
+	return tx.drv.exec(ctx, tx.Tx, "dryrun.Tx.Exec", query, args, v)
+}
+
+// ExecContext skips the write statements and calls the underlying transaction ExecContext method otherwise.
+func (tx *dryRunTx) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return tx.drv.execContext(ctx, tx.Tx, "dryrun.Tx.ExecContext", query, args)
+}
+
+// Query skips the write statements and calls the underlying transaction Query method otherwise.
+func (tx *dryRunTx) Query(ctx context.Context, query string, args, v any) error {
+	return tx.drv.query(ctx, tx.Tx, "dryrun.Tx.Query", query, args, v)
+}
+
+// QueryContext skips the write statements and calls the underlying transaction QueryContext method otherwise.
+func (tx *dryRunTx) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return tx.drv.queryContext(ctx, tx.Tx, "dryrun.Tx.QueryContext", query, args)
+}
+
+func (d *DryRunDriver) exec(ctx context.Context, ex dialect.ExecQuerier, op, query string, args, v any) error {
+	res, ok := d.skip(ctx, op, query, args)
+	if !ok {
+		return ex.Exec(ctx, query, args, v)
+	}
+	if vr, ok := v.(*sql.Result); ok {
+		*vr = res
+	}
+	return nil
+}
+
+func (d *DryRunDriver) execContext(ctx context.Context, ex dialect.ExecQuerier, op, query string, args []any) (sql.Result, error) {
+	res, ok := d.skip(ctx, op, query, args)
+	if !ok {
+		return execContext(ctx, ex, query, args)
+	}
+	return res, nil
+}
+
+func (d *DryRunDriver) query(ctx context.Context, ex dialect.ExecQuerier, op, query string, args, v any) error {
+	res, ok := d.skip(ctx, op, query, args)
+	if !ok {
+		return ex.Query(ctx, query, args, v)
+	}
+	vr, ok := v.(*entsql.Rows)
+	if !ok {
+		return fmt.Errorf("entzlog: dry run: unexpected type %T for query result", v)
+	}
+	rows, err := res.returning(query).rows(ctx)
+	if err != nil {
+		return err
+	}
+	*vr = entsql.Rows{ColumnScanner: rows}
+	return nil
+}
+
+func (d *DryRunDriver) queryContext(ctx context.Context, ex dialect.ExecQuerier, op, query string, args []any) (*sql.Rows, error) {
+	res, ok := d.skip(ctx, op, query, args)
+	if !ok {
+		return queryContext(ctx, ex, query, args)
+	}
+	return res.returning(query).rows(ctx)
+}
+
+// skip logs the statements that are not plain reads and returns their
+// synthetic result. It returns false for the plain reads, which are
+// executed. The statements that cannot be classified are skipped.
+func (d *DryRunDriver) skip(ctx context.Context, op, query string, args any) (dryRunResult, bool) {
+	var res dryRunResult
+	if isPlainRead(query) {
+		return res, false
+	}
+	switch StatementType(query) {
+	case StmtInsert:
+		res.rows = insertTuples(query)
+		res.id = d.lastID.Add(res.rows) - res.rows + 1
+	case StmtUpdate, StmtDelete:
+		res.rows = 1
+	}
+	d.cfg.Log(ctx, op+": skipped", zap.String("query", interpolate(query, argList(args))))
+	return res, true
+}
+
+// dryRunResult is the synthetic result of a skipped statement.
+type dryRunResult struct {
+	id, rows int64
+}
+
+func (r dryRunResult) LastInsertId() (int64, error) { return r.id, nil }
+func (r dryRunResult) RowsAffected() (int64, error) { return r.rows, nil }
+
+// returning returns the rows of the RETURNING clause of the query, if any:
+// one row per affected row, holding its id in all the returned columns.
+func (r dryRunResult) returning(query string) *result {
+	var (
+		res   = &result{}
+		toks  = lex(query)
+		depth = 0
+	)
+	for i, t := range toks {
+		switch {
+		case t.text == "(":
+			depth++
+		case t.text == ")":
+			depth--
+		case depth == 0 && t.kind == tokIdent && strings.EqualFold(t.text, "RETURNING"):
+			res.columns = res.columns[:0]
+			for _, c := range toks[i+1:] {
+				if c.kind == tokIdent {
+					res.columns = append(res.columns, c.name())
+				}
+			}
+		}
+	}
+	if len(res.columns) == 0 {
+		return res
+	}
+	for i := int64(0); i < r.rows; i++ {
+		row := make([]any, len(res.columns))
+		for j := range row {
+			row[j] = r.id + i
+		}
+		res.values = append(res.values, row)
+	}
+	return res
+}
+
+// insertTuples returns the number of tuples of the VALUES clause of an
+// insert, or 1 for inserts without a VALUES clause.
+func insertTuples(query string) int64 {
+	var (
+		n      int64
+		depth  = 0
+		values = false
+	)
+	for _, t := range lex(query) {
+		switch {
+		case t.text == "(":
+			if values && depth == 0 {
+				n++
+			}
+			depth++
+		case t.text == ")":
+			depth--
+		case depth == 0 && t.kind == tokIdent:
+			if values && n > 0 {
+				return n
+			}
+			values = strings.EqualFold(t.text, "VALUES")
+		}
+	}
+	return max(n, 1)
+}
+
+// interpolate returns the query with its placeholders (? or $n) replaced
+// by the SQL literals of the arguments, for logging purposes only.
+func interpolate(query string, args []any) string {
+	var (
+		b     strings.Builder
+		next  int
+		quote byte
+	)
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '?' && next < len(args):
+			b.WriteString(sqlLiteral(args[next]))
+			next++
+			continue
+		case c == '$' && i+1 < len(query) && '0' <= query[i+1] && query[i+1] <= '9':
+			j := i + 1
+			for j < len(query) && '0' <= query[j] && query[j] <= '9' {
+				j++
+			}
+			if n, err := strconv.Atoi(query[i+1 : j]); err == nil && n >= 1 && n <= len(args) {
+				b.WriteString(sqlLiteral(args[n-1]))
+				i = j - 1
+				continue
+			}
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// sqlLiteral returns the SQL literal of an argument.
+func sqlLiteral(v any) string {
+	if vr, ok := v.(driver.Valuer); ok {
+		if dv, err := vr.Value(); err == nil {
+			v = dv
+		}
+	}
+	switch v := v.(type) {
+	case nil:
+		return "NULL"
+	case string:
+		return "'" + strings.ReplaceAll(v, "'", "''") + "'"
+	case []byte:
+		return "X'" + hex.EncodeToString(v) + "'"
+	case bool:
+		if v {
+			return "TRUE"
+		}
+		return "FALSE"
+	case time.Time:
+		return "'" + v.Format("2006-01-02 15:04:05.999999Z07:00") + "'"
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return fmt.Sprint(v)
+	default:
+		return sqlLiteral(fmt.Sprint(v))
+	}
+}
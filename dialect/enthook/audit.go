@@ -0,0 +1,59 @@
+package enthook
+
+import (
+	"context"
+	"time"
+
+	"entgo.io/ent"
+	"github.com/floatyun/entzlog/dialect"
+	"github.com/google/uuid"
+)
+
+// AuditConfig configures the hook returned by Audit.
+type AuditConfig struct {
+	// Actor returns the acting user or service of the context, if any.
+	// Defaults to the actor set with driver.WithActor.
+	Actor func(ctx context.Context) string
+	// Redact reports whether the values of a field of an entity type are
+	// replaced by Redacted. Defaults to the fields whose name contains
+	// "password", "secret" or "token".
+	Redact func(typ, field string) bool
+	// OldValues loads the previous values of the fields changed by
+	// UpdateOne mutations, at the cost of an additional query. The values
+	// are loaded once per mutation, and shared with the Mutations hook.
+	OldValues bool
+}
+
+// Audit returns a hook attaching an audit record to the context of the
+// mutations, which is written to the audit table of the DebugDriver executing
+// their statements, within their transaction if any. See driver.WithAudit.
+func Audit(cfg AuditConfig) ent.Hook {
+	if cfg.Redact == nil {
+		cfg.Redact = redactSecrets
+	}
+	if cfg.Actor == nil {
+		cfg.Actor = func(ctx context.Context) string {
+			actor, _ := driver.ActorFromContext(ctx)
+			return actor
+		}
+	}
+	return func(next ent.Mutator) ent.Mutator {
+		return ent.MutateFunc(func(ctx context.Context, m ent.Mutation) (ent.Value, error) {
+			id, ok := driver.OpIDFromContext(ctx)
+			if !ok {
+				id = uuid.New().String()
+				ctx = driver.WithOpID(ctx, id)
+			}
+			ctx, old := oldValues(ctx, m, cfg.OldValues)
+			r := &driver.AuditRecord{
+				Time:    time.Now().UTC(),
+				Entity:  m.Type(),
+				Op:      mutationOp(m),
+				Changes: changes(m, cfg.Redact, old),
+				OpID:    id,
+				Actor:   cfg.Actor(ctx),
+			}
+			return next.Mutate(driver.WithAuditRecord(ctx, r), m)
+		})
+	}
+}
@@ -0,0 +1,48 @@
+// Package enthook provides an ent interceptor and hook recording the ent
+// operations in the context of their statements, so the DebugDriver can
+// attribute the statements to the ent API calls that issued them.
+package enthook
+
+import (
+	"context"
+	"strings"
+
+	"entgo.io/ent"
+	"github.com/floatyun/entzlog/dialect"
+)
+
+// Interceptor returns an interceptor recording the type and operation of
+// the queries, e.g. "User.All" or "Order.Count", in their context. Use it
+// with the Intercept method of the generated client.
+func Interceptor() ent.Interceptor {
+	return ent.InterceptFunc(func(next ent.Querier) ent.Querier {
+		return ent.QuerierFunc(func(ctx context.Context, q ent.Query) (ent.Value, error) {
+			op := "Query"
+			if qc := ent.QueryFromContext(ctx); qc != nil {
+				op = qc.Type + "." + qc.Op
+			}
+			return next.Query(driver.WithEntOp(ctx, op), q)
+		})
+	})
+}
+
+// Hook returns a hook recording the type and operation of the mutations,
+// e.g. "User.Create" or "Order.UpdateOne", in their context. Use it with
+// the Use method of the generated client.
+func Hook() ent.Hook {
+	return func(next ent.Mutator) ent.Mutator {
+		return ent.MutateFunc(func(ctx context.Context, m ent.Mutation) (ent.Value, error) {
+			return next.Mutate(driver.WithEntOp(ctx, entOp(m)), m)
+		})
+	}
+}
+
+// entOp returns the type and operation of the mutation, e.g. "User.Create".
+func entOp(m ent.Mutation) string {
+	return m.Type() + "." + mutationOp(m)
+}
+
+// mutationOp returns the operation of the mutation, e.g. "UpdateOne".
+func mutationOp(m ent.Mutation) string {
+	return strings.TrimPrefix(m.Op().String(), "Op")
+}
@@ -0,0 +1,165 @@
+package enthook
+
+import (
+	"context"
+	"strings"
+
+	"entgo.io/ent"
+	"github.com/floatyun/entzlog/dialect"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Redacted replaces the values of the redacted fields.
+const Redacted = "[REDACTED]"
+
+// MutationConfig configures the hook returned by Mutations.
+type MutationConfig struct {
+	// Log is the logging function of the mutations. Required.
+	Log driver.LogFunc
+	// Redact reports whether the values of a field of an entity type are
+	// replaced by Redacted. Defaults to the fields whose name contains
+	// "password", "secret" or "token".
+	Redact func(typ, field string) bool
+	// OldValues loads the previous values of the fields changed by
+	// UpdateOne mutations, at the cost of an additional query. The values
+	// are loaded once per mutation, and shared with the Audit hook.
+	OldValues bool
+}
+
+// Change is a field changed by a mutation.
+type Change struct {
+	Field   string `json:"field"`
+	Old     any    `json:"old,omitempty"`     // previous value, if loaded. See MutationConfig.OldValues.
+	New     any    `json:"new,omitempty"`     // new value, or the delta of numeric fields if Added.
+	Added   bool   `json:"added,omitempty"`   // whether New was added to the field, e.g. AddAge(1).
+	Cleared bool   `json:"cleared,omitempty"` // whether the field was cleared.
+}
+
+// Mutations returns a hook logging the mutations with the fields they
+// change, after they are executed. Each mutation is given an operation id,
+// carried by its context and logged as op_id by the hook, and by the
+// DebugDriver along with the statements and transaction id of the mutation.
+func Mutations(cfg MutationConfig) ent.Hook {
+	if cfg.Redact == nil {
+		cfg.Redact = redactSecrets
+	}
+	return func(next ent.Mutator) ent.Mutator {
+		return ent.MutateFunc(func(ctx context.Context, m ent.Mutation) (ent.Value, error) {
+			id := uuid.New().String()
+			ctx = driver.WithOpID(ctx, id)
+			ctx, old := oldValues(ctx, m, cfg.OldValues)
+			changes := changes(m, cfg.Redact, old)
+			v, err := next.Mutate(ctx, m)
+			fields := []zap.Field{
+				zap.String("ent_op", entOp(m)),
+				zap.String("op_id", id),
+				zap.Array("changes", changeArray(changes)),
+			}
+			if err != nil {
+				fields = append(fields, zap.Error(err))
+			}
+			cfg.Log(ctx, "ent.Mutation", fields...)
+			return v, err
+		})
+	}
+}
+
+// changes returns the fields changed by the mutation, with their redacted
+// values and their previous values in old, if any.
+func changes(m ent.Mutation, redact func(typ, field string) bool, old map[string]ent.Value) []Change {
+	var changes []Change
+	for _, f := range m.Fields() {
+		c := Change{Field: f, Old: old[f]}
+		c.New, _ = m.Field(f)
+		changes = append(changes, c)
+	}
+	for _, f := range m.AddedFields() {
+		c := Change{Field: f, Added: true}
+		c.New, _ = m.AddedField(f)
+		changes = append(changes, c)
+	}
+	for _, f := range m.ClearedFields() {
+		changes = append(changes, Change{Field: f, Old: old[f], Cleared: true})
+	}
+	for i, c := range changes {
+		if redact(m.Type(), c.Field) {
+			if c.Old != nil {
+				changes[i].Old = Redacted
+			}
+			if c.New != nil {
+				changes[i].New = Redacted
+			}
+		}
+	}
+	return changes
+}
+
+// oldKey is the context key of the previous values of a mutation.
+type oldKey struct{}
+
+// oldEntity holds the previous values of the fields of a mutation.
+type oldEntity struct {
+	m      ent.Mutation
+	values map[string]ent.Value
+}
+
+// oldValues returns the previous values of the fields changed or cleared by
+// an UpdateOne mutation, if load is set, and a context carrying them to the
+// inner hooks. The values are loaded once per mutation, and none is loaded
+// once the loading of one failed.
+func oldValues(ctx context.Context, m ent.Mutation, load bool) (context.Context, map[string]ent.Value) {
+	if !load || !m.Op().Is(ent.OpUpdateOne) {
+		return ctx, nil
+	}
+	if o, ok := ctx.Value(oldKey{}).(*oldEntity); ok && o.m == m {
+		return ctx, o.values
+	}
+	values := make(map[string]ent.Value)
+	for _, f := range append(m.Fields(), m.ClearedFields()...) {
+		v, err := m.OldField(ctx, f)
+		if err != nil {
+			break
+		}
+		values[f] = v
+	}
+	return context.WithValue(ctx, oldKey{}, &oldEntity{m: m, values: values}), values
+}
+
+// redactSecrets is the default MutationConfig.Redact function.
+func redactSecrets(_, field string) bool {
+	field = strings.ToLower(field)
+	return strings.Contains(field, "password") || strings.Contains(field, "secret") || strings.Contains(field, "token")
+}
+
+// changeArray implements zapcore.ArrayMarshaler for the changes of a mutation.
+type changeArray []Change
+
+// MarshalLogArray implements the zapcore.ArrayMarshaler interface.
+func (a changeArray) MarshalLogArray(enc zapcore.ArrayEncoder) error {
+	for _, c := range a {
+		if err := enc.AppendObject(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MarshalLogObject implements the zapcore.ObjectMarshaler interface.
+func (c Change) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("field", c.Field)
+	if c.Old != nil {
+		if err := enc.AddReflected("old", c.Old); err != nil {
+			return err
+		}
+	}
+	if c.Added {
+		enc.AddBool("added", true)
+	}
+	if c.Cleared {
+		enc.AddBool("cleared", true)
+		return nil
+	}
+	return enc.AddReflected("new", c.New)
+}
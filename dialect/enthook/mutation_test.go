@@ -0,0 +1,85 @@
+package enthook
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"entgo.io/ent"
+	"go.uber.org/zap"
+)
+
+// mutation is an UpdateOne mutation of a user, counting the loads of its
+// previous values.
+type mutation struct {
+	ent.Mutation
+	loads int
+	err   error
+}
+
+func (*mutation) Type() string                     { return "User" }
+func (*mutation) Op() ent.Op                       { return ent.OpUpdateOne }
+func (*mutation) Fields() []string                 { return []string{"name", "password"} }
+func (*mutation) Field(f string) (ent.Value, bool) { return "new " + f, true }
+func (*mutation) AddedFields() []string            { return nil }
+func (*mutation) ClearedFields() []string          { return []string{"nickname"} }
+
+func (m *mutation) OldField(_ context.Context, f string) (ent.Value, error) {
+	m.loads++
+	if m.err != nil {
+		return nil, m.err
+	}
+	return "old " + f, nil
+}
+
+func TestOldValues(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		wantLoads int
+		wantOld   map[string]any
+	}{
+		{
+			name:      "loaded",
+			wantLoads: 3,
+			wantOld:   map[string]any{"name": "old name", "password": Redacted, "nickname": "old nickname"},
+		},
+		{
+			name:      "failed",
+			err:       errors.New("not found"),
+			wantLoads: 1,
+			wantOld:   map[string]any{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &mutation{err: tt.err}
+			var changes changeArray
+			log := func(_ context.Context, _ string, fields ...zap.Field) {
+				for _, f := range fields {
+					if f.Key == "changes" {
+						changes = f.Interface.(changeArray)
+					}
+				}
+			}
+			mutator := ent.Mutator(ent.MutateFunc(func(context.Context, ent.Mutation) (ent.Value, error) {
+				return nil, nil
+			}))
+			mutator = Mutations(MutationConfig{Log: log, OldValues: true})(Audit(AuditConfig{OldValues: true})(mutator))
+			if _, err := mutator.Mutate(context.Background(), m); err != nil {
+				t.Fatal(err)
+			}
+			if m.loads != tt.wantLoads {
+				t.Errorf("loaded %d previous values, want %d", m.loads, tt.wantLoads)
+			}
+			if len(changes) != 3 {
+				t.Fatalf("logged %d changes, want 3", len(changes))
+			}
+			for _, c := range changes {
+				if want := tt.wantOld[c.Field]; c.Old != want {
+					t.Errorf("old value of %s = %v, want %v", c.Field, c.Old, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package driver
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
+
+type entOpKey struct{}
+
+// WithEntOp returns a context carrying the ent operation that issues its
+// statements, e.g. "User.Create". It is set by the enthook package.
+func WithEntOp(ctx context.Context, op string) context.Context {
+	return context.WithValue(ctx, entOpKey{}, op)
+}
+
+// EntOpFromContext returns the ent operation stored in the context, if any.
+func EntOpFromContext(ctx context.Context) (string, bool) {
+	op, ok := ctx.Value(entOpKey{}).(string)
+	return op, ok
+}
+
+type opIDKey struct{}
+
+// WithOpID returns a context carrying the id of the application operation
+// that issues its statements, to correlate them with the entries logged at
+// the ent layer. It is set by the mutation hook of the enthook package.
+func WithOpID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, opIDKey{}, id)
+}
+
+// OpIDFromContext returns the operation id stored in the context, if any.
+func OpIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(opIDKey{}).(string)
+	return id, ok
+}
+
+type actorKey struct{}
+
+// WithActor returns a context carrying the identity of the user or service
+// acting on the database, e.g. a user id or a service account name. It is
+// logged with the write statements issued with the context, and stored in
+// their audit records.
+func WithActor(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, actorKey{}, id)
+}
+
+// ActorFromContext returns the actor stored in the context, if any.
+func ActorFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(actorKey{}).(string)
+	return id, ok
+}
+
+// ctxFields appends the fields carried by the context to fields.
+func ctxFields(ctx context.Context, fields []zap.Field) []zap.Field {
+	if op, ok := EntOpFromContext(ctx); ok {
+		fields = append(fields, zap.String("ent_op", op))
+	}
+	if id, ok := OpIDFromContext(ctx); ok {
+		fields = append(fields, zap.String("op_id", id))
+	}
+	return fields
+}
@@ -0,0 +1,219 @@
+package driver
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"reflect"
+	"strings"
+	"syscall"
+
+	"entgo.io/ent/dialect/sql/sqlgraph"
+)
+
+// ErrUnsupported is returned when an operation is not supported by the
+// underlying driver or transaction. It is wrapped with the name of the
+// operation, and can be checked with errors.Is.
+var ErrUnsupported = errors.New("entzlog: operation not supported")
+
+// unsupported returns ErrUnsupported wrapped with the operation name.
+func unsupported(op string) error {
+	return fmt.Errorf("%w: %s", ErrUnsupported, op)
+}
+
+// SanitizeError returns the text of the error with its single-quoted string
+// literals replaced by "?", like SanitizeQuery does, so it can be reported
+// without leaking the values echoed by the database, e.g. the duplicate
+// entries of MySQL.
+func SanitizeError(err error) string {
+	msg := err.Error()
+	if !strings.Contains(msg, "'") {
+		return msg
+	}
+	var b strings.Builder
+	for i := 0; i < len(msg); i++ {
+		if msg[i] != '\'' {
+			b.WriteByte(msg[i])
+			continue
+		}
+		end := strings.IndexByte(msg[i+1:], '\'')
+		if end == -1 {
+			b.WriteString(msg[i:])
+			break
+		}
+		b.WriteByte('?')
+		i += end + 1
+	}
+	return b.String()
+}
+
+// isConnError reports whether err indicates that the connection to the
+// database is broken, as opposed to an error returned by the statement.
+func isConnError(err error) bool {
+	var ne net.Error
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, driver.ErrBadConn), errors.Is(err, sql.ErrConnDone):
+		return true
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return true
+	case errors.Is(err, syscall.ECONNREFUSED), errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.EPIPE):
+		return true
+	case errors.As(err, &ne):
+		return true
+	}
+	return false
+}
+
+// ErrorClass is the class of an error returned by the database. It is logged
+// as the error_class field of failed statements.
+type ErrorClass string
+
+// Error classes returned by ClassifyError.
+const (
+	ErrorUnique        ErrorClass = "unique_violation"
+	ErrorForeignKey    ErrorClass = "foreign_key_violation"
+	ErrorConstraint    ErrorClass = "constraint_violation"
+	ErrorTimeout       ErrorClass = "timeout"        // the client timed out, e.g. its context expired.
+	ErrorCanceled      ErrorClass = "canceled"       // the client gave up, e.g. its context was canceled.
+	ErrorServerTimeout ErrorClass = "server_timeout" // the database killed the statement, e.g. a statement or lock timeout.
+	ErrorDeadlock      ErrorClass = "deadlock"
+	ErrorConnection    ErrorClass = "connection"
+	ErrorOther         ErrorClass = "other"
+)
+
+// ClassifyError returns the class of the given error, or an empty class if
+// it is nil. Errors are classified by the dialect-specific codes of the
+// PostgreSQL (SQLSTATE), MySQL and SQLite drivers when available, and by
+// their message otherwise.
+func ClassifyError(err error) ErrorClass {
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, context.Canceled):
+		return ErrorCanceled
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrorTimeout
+	}
+	if c, ok := classifyCode(err); ok {
+		return c
+	}
+	var ne net.Error
+	switch {
+	case errors.As(err, &ne) && ne.Timeout():
+		return ErrorTimeout
+	case isConnError(err):
+		return ErrorConnection
+	case sqlgraph.IsUniqueConstraintError(err):
+		return ErrorUnique
+	case sqlgraph.IsForeignKeyConstraintError(err):
+		return ErrorForeignKey
+	case sqlgraph.IsConstraintError(err):
+		return ErrorConstraint
+	case strings.Contains(strings.ToLower(err.Error()), "deadlock"):
+		return ErrorDeadlock
+	}
+	return ErrorOther
+}
+
+// classifyContextError classifies the error of a statement by the error of
+// its context, if done, as drivers report cancellations in various ways.
+func classifyContextError(ctx context.Context, err error) ErrorClass {
+	if err != nil {
+		switch ctx.Err() {
+		case context.Canceled:
+			return ErrorCanceled
+		case context.DeadlineExceeded:
+			return ErrorTimeout
+		}
+	}
+	return ClassifyError(err)
+}
+
+// classifyCode classifies the error by its dialect-specific code, if the
+// error chain contains a driver error that carries one.
+func classifyCode(err error) (ErrorClass, bool) {
+	for ; err != nil; err = errors.Unwrap(err) {
+		// PostgreSQL drivers (pq, pgx) expose the SQLSTATE code.
+		if e, ok := err.(interface{ SQLState() string }); ok {
+			if c, ok := sqlState(e.SQLState()); ok {
+				return c, true
+			}
+		}
+		// Neither go-sql-driver/mysql nor mattn/go-sqlite3 have methods
+		// returning their codes. Read the fields to avoid depending on them.
+		v := reflect.Indirect(reflect.ValueOf(err))
+		if v.Kind() != reflect.Struct {
+			continue
+		}
+		if f := v.FieldByName("Number"); f.IsValid() && f.CanUint() {
+			if c, ok := mysqlNumber(f.Uint()); ok {
+				return c, true
+			}
+		}
+		if f := v.FieldByName("ExtendedCode"); f.IsValid() && f.CanInt() {
+			if c, ok := sqliteCode(f.Int()); ok {
+				return c, true
+			}
+		}
+	}
+	return "", false
+}
+
+// sqlState classifies PostgreSQL SQLSTATE codes.
+func sqlState(code string) (ErrorClass, bool) {
+	switch {
+	case code == "23505":
+		return ErrorUnique, true
+	case code == "23503":
+		return ErrorForeignKey, true
+	case strings.HasPrefix(code, "23"):
+		return ErrorConstraint, true
+	case code == "40P01":
+		return ErrorDeadlock, true
+	case code == "57014", code == "55P03":
+		// query_canceled is returned for statement timeouts, and lock_not_available for lock timeouts.
+		return ErrorServerTimeout, true
+	case strings.HasPrefix(code, "08"), code == "57P01":
+		return ErrorConnection, true
+	}
+	return "", false
+}
+
+// mysqlNumber classifies MySQL error numbers.
+func mysqlNumber(n uint64) (ErrorClass, bool) {
+	switch n {
+	case 1062, 1586:
+		return ErrorUnique, true
+	case 1216, 1217, 1451, 1452:
+		return ErrorForeignKey, true
+	case 1048, 3819:
+		return ErrorConstraint, true
+	case 1213:
+		return ErrorDeadlock, true
+	case 1205, 3024:
+		return ErrorServerTimeout, true
+	case 1040, 1053, 2002, 2003, 2006, 2013:
+		return ErrorConnection, true
+	}
+	return "", false
+}
+
+// sqliteCode classifies SQLite extended result codes.
+func sqliteCode(code int64) (ErrorClass, bool) {
+	switch code {
+	case 2067, 1555: // SQLITE_CONSTRAINT_UNIQUE, SQLITE_CONSTRAINT_PRIMARYKEY
+		return ErrorUnique, true
+	case 787: // SQLITE_CONSTRAINT_FOREIGNKEY
+		return ErrorForeignKey, true
+	}
+	if code&0xff == 19 { // SQLITE_CONSTRAINT
+		return ErrorConstraint, true
+	}
+	return "", false
+}
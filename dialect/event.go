@@ -0,0 +1,199 @@
+package driver
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Ops of the events emitted on degradation transitions. See WithDegradation.
+const (
+	OpDegraded  = "Degraded"
+	OpRecovered = "Recovered"
+)
+
+// Event describes an operation executed by a DebugDriver or its transactions,
+// and is passed to the sinks of the driver. The Query and Args are empty for
+// transaction operations.
+type Event struct {
+	Time       time.Time     // start time.
+	Dialect    string        // dialect of the driver.
+	Op         string        // driver method, e.g. "Exec" or "Commit", or OpDegraded/OpRecovered.
+	TxID       string        // id of the transaction, if any.
+	Tenant     string        // tenant of the context, if any. See WithTenant.
+	EntOp      string        // ent operation of the context, if any. See WithEntOp.
+	OpID       string        // operation id of the context, if any. See WithOpID.
+	Actor      string        // actor of the context for write statements, if any. See WithActor.
+	Query      string        // executed query.
+	Args       []any         // query arguments.
+	Duration   time.Duration // execution duration.
+	Rows       int64         // affected or returned rows, or -1 if unknown.
+	Err        error         // returned error, if any.
+	ErrorClass ErrorClass    // class of Err.
+	Slow       bool          // whether Duration exceeded the slow threshold.
+	Failures   int64         // consecutive failures of degradation events.
+	Caller     string        // application frame that issued the statement. See WithCaller.
+	Baggage    []Attr        // baggage entries of the context. See WithBaggage.
+	Attrs      []Attr        // static attributes of the driver. See WithAttrs.
+}
+
+// Attr is a key/value attribute of an event.
+type Attr struct {
+	Key, Value string
+}
+
+// attrs returns the optional attributes of the event, e.g. the type and
+// table of its statement, its caller, the baggage of its context and the
+// static attributes of the driver, which are encoded by the sinks along
+// with its fields.
+func (e *Event) attrs() []Attr {
+	if e.Query == "" && e.Caller == "" && e.EntOp == "" && e.OpID == "" && e.Actor == "" && len(e.Baggage) == 0 {
+		return e.Attrs
+	}
+	attrs := make([]Attr, 0, len(e.Attrs)+len(e.Baggage)+6)
+	if e.Query != "" {
+		attrs = append(attrs, Attr{"stmt_type", StatementType(e.Query)})
+		if table := StatementTable(e.Query); table != "" {
+			attrs = append(attrs, Attr{"table", table})
+		}
+	}
+	if e.EntOp != "" {
+		attrs = append(attrs, Attr{"ent_op", e.EntOp})
+	}
+	if e.OpID != "" {
+		attrs = append(attrs, Attr{"op_id", e.OpID})
+	}
+	if e.Actor != "" {
+		attrs = append(attrs, Attr{"actor", e.Actor})
+	}
+	if e.Caller != "" {
+		attrs = append(attrs, Attr{"caller", e.Caller})
+	}
+	attrs = append(attrs, e.Baggage...)
+	return append(attrs, e.Attrs...)
+}
+
+// appendAttrs appends the attributes to the encoded JSON object b.
+func appendAttrs(b []byte, attrs []Attr) ([]byte, error) {
+	if len(attrs) == 0 || len(b) < 2 {
+		return b, nil
+	}
+	b = b[:len(b)-1]
+	for _, a := range attrs {
+		k, err := json.Marshal(a.Key)
+		if err != nil {
+			return nil, err
+		}
+		v, err := json.Marshal(a.Value)
+		if err != nil {
+			return nil, err
+		}
+		b = append(append(append(append(b, ','), k...), ':'), v...)
+	}
+	return append(b, '}'), nil
+}
+
+// Fingerprint returns the fingerprint of the event query, or an empty
+// string for events without a query. See Fingerprint.
+func (e *Event) Fingerprint() string {
+	if e.Query == "" {
+		return ""
+	}
+	return Fingerprint(e.Query)
+}
+
+// Status returns "canceled", "timeout" or "server_timeout" for the
+// operations that failed with these error classes, "error" for the other
+// failed operations, "slow" for slow operations, and "ok" otherwise.
+func (e *Event) Status() string {
+	switch {
+	case e.Err != nil:
+		switch e.ErrorClass {
+		case ErrorCanceled, ErrorTimeout, ErrorServerTimeout:
+			return string(e.ErrorClass)
+		}
+		return "error"
+	case e.Slow:
+		return "slow"
+	default:
+		return "ok"
+	}
+}
+
+// MarshalJSON implements the json.Marshaler interface. The query
+// arguments are omitted, as they may hold sensitive values.
+func (e *Event) MarshalJSON() ([]byte, error) {
+	v := struct {
+		Time        time.Time `json:"time"`
+		Dialect     string    `json:"dialect"`
+		Op          string    `json:"op"`
+		TxID        string    `json:"tx_id,omitempty"`
+		Tenant      string    `json:"tenant,omitempty"`
+		Query       string    `json:"query,omitempty"`
+		Fingerprint string    `json:"fingerprint,omitempty"`
+		DurationMS  float64   `json:"duration_ms"`
+		Status      string    `json:"status"`
+		Error       string    `json:"error,omitempty"`
+		ErrorClass  string    `json:"error_class,omitempty"`
+		Failures    int64     `json:"failures,omitempty"`
+	}{
+		Time:        e.Time,
+		Dialect:     e.Dialect,
+		Op:          e.Op,
+		TxID:        e.TxID,
+		Tenant:      e.Tenant,
+		Query:       e.Query,
+		Fingerprint: e.Fingerprint(),
+		DurationMS:  float64(e.Duration) / float64(time.Millisecond),
+		Status:      e.Status(),
+		ErrorClass:  string(e.ErrorClass),
+		Failures:    e.Failures,
+	}
+	if e.Err != nil {
+		v.Error = e.Err.Error()
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return appendAttrs(b, e.attrs())
+}
+
+// Sink receives the events of a DebugDriver. Write is called synchronously
+// by the operations of the driver, and must be safe for concurrent use. The
+// events of Query are written once its rows are consumed or closed, with
+// the number of returned rows.
+type Sink interface {
+	// Write receives an event. Errors are logged by the driver.
+	Write(ctx context.Context, e *Event) error
+	// Close flushes the buffered events, if any, and releases the resources
+	// of the sink. It is called when the driver is closed.
+	Close() error
+}
+
+// WithSink adds a sink receiving the events of the driver.
+func WithSink(s Sink) Option {
+	return func(d *DebugDriver) {
+		d.sinks = append(d.sinks, s)
+	}
+}
+
+// WithSlowThreshold sets the duration from which operations are logged
+// as slow, and reported as such to the sinks of the driver.
+func WithSlowThreshold(threshold time.Duration) Option {
+	return func(d *DebugDriver) {
+		d.slow = threshold
+	}
+}
+
+// emit passes the event to the sinks of the driver, with its static attributes.
+func (d *DebugDriver) emit(ctx context.Context, e *Event) {
+	e.Attrs = d.attrs
+	for _, s := range d.sinks {
+		if err := s.Write(ctx, e); err != nil {
+			d.log(ctx, "driver: sink failed", zap.String("op", e.Op), zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package driver
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"entgo.io/ent/dialect"
+	"go.uber.org/zap"
+)
+
+// FailoverConfig configures a FailoverDriver.
+type FailoverConfig struct {
+	// MaxFailures is the number of consecutive connection failures against
+	// the primary after which the driver switches to the standby. Defaults to 3.
+	MaxFailures int
+	// RetryInterval is the interval in which the primary is probed while the
+	// driver is switched to the standby. Defaults to 10 seconds.
+	RetryInterval time.Duration
+	// ProbeQuery is the statement used to probe the primary. Defaults to "SELECT 1".
+	ProbeQuery string
+	// ProbeTimeout bounds the probes of the primary. Defaults to 5 seconds.
+	ProbeTimeout time.Duration
+	// Log is the log function. Optional.
+	Log LogFunc
+	// Metrics receives the failover measurements. Optional.
+	Metrics Metrics
+}
+
+// FailoverDriver is a driver that switches to a standby driver when the
+// primary keeps failing with connection errors, and switches back once the
+// primary is reachable again. The primary is probed in the background, and
+// the operations are routed by the outcome of the last probe.
+type FailoverDriver struct {
+	primary  Driver
+	standby  Driver
+	cfg      FailoverConfig
+	probing  atomic.Bool
+	mu       sync.Mutex
+	failures int
+	failedAt time.Time // zero while the primary is active.
+	probedAt time.Time
+}
+
+// NewFailoverDriver returns a FailoverDriver for the given primary and standby drivers.
+func NewFailoverDriver(primary, standby Driver, cfg FailoverConfig) *FailoverDriver {
+	if cfg.MaxFailures <= 0 {
+		cfg.MaxFailures = 3
+	}
+	if cfg.RetryInterval <= 0 {
+		cfg.RetryInterval = 10 * time.Second
+	}
+	if cfg.ProbeQuery == "" {
+		cfg.ProbeQuery = "SELECT 1"
+	}
+	if cfg.ProbeTimeout <= 0 {
+		cfg.ProbeTimeout = 5 * time.Second
+	}
+	if cfg.Log == nil {
+		cfg.Log = nopLog
+	}
+	if cfg.Metrics == nil {
+		cfg.Metrics = nopMetrics{}
+	}
+	return &FailoverDriver{primary: primary, standby: standby, cfg: cfg}
+}
+
+// Exec calls the Exec method of the active driver.
+func (d *FailoverDriver) Exec(ctx context.Context, query string, args, v any) error {
+	drv, primary := d.active(ctx)
+	return d.observe(ctx, primary, drv.Exec(ctx, query, args, v))
+}
+
+// Query calls the Query method of the active driver.
+func (d *FailoverDriver) Query(ctx context.Context, query string, args, v any) error {
+	drv, primary := d.active(ctx)
+	return d.observe(ctx, primary, drv.Query(ctx, query, args, v))
+}
+
+// ExecContext calls the ExecContext method of the active driver.
+func (d *FailoverDriver) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	drv, primary := d.active(ctx)
+	res, err := execContext(ctx, drv, query, args)
+	return res, d.observe(ctx, primary, err)
+}
+
+// QueryContext calls the QueryContext method of the active driver.
+func (d *FailoverDriver) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	drv, primary := d.active(ctx)
+	rows, err := queryContext(ctx, drv, query, args)
+	return rows, d.observe(ctx, primary, err)
+}
+
+// Tx starts a transaction on the active driver.
+func (d *FailoverDriver) Tx(ctx context.Context) (dialect.Tx, error) {
+	drv, primary := d.active(ctx)
+	tx, err := drv.Tx(ctx)
+	return tx, d.observe(ctx, primary, err)
+}
+
+// BeginTx starts a transaction on the active driver if it is supported.
+func (d *FailoverDriver) BeginTx(ctx context.Context, opts *sql.TxOptions) (dialect.Tx, error) {
+	drv, primary := d.active(ctx)
+	tx, err := beginTx(ctx, drv, opts)
+	return tx, d.observe(ctx, primary, err)
+}
+
+// Dialect returns the dialect of the primary driver.
+func (d *FailoverDriver) Dialect() string {
+	return d.primary.Dialect()
+}
+
+// Close closes both the primary and the standby drivers.
+func (d *FailoverDriver) Close() error {
+	return errors.Join(d.primary.Close(), d.standby.Close())
+}
+
+// FailedOver reports whether the driver is currently switched to the standby.
+func (d *FailoverDriver) FailedOver() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return !d.failedAt.IsZero()
+}
+
+// active returns the driver that should serve the next operation, and
+// whether it is the primary. While switched to the standby, it starts a
+// probe of the primary in the background once every RetryInterval.
+func (d *FailoverDriver) active(ctx context.Context) (Driver, bool) {
+	d.mu.Lock()
+	if d.failedAt.IsZero() {
+		d.mu.Unlock()
+		return d.primary, true
+	}
+	due := time.Since(d.probedAt) >= d.cfg.RetryInterval
+	d.mu.Unlock()
+	if due && d.probing.CompareAndSwap(false, true) {
+		go func() {
+			defer d.probing.Store(false)
+			d.probe(context.WithoutCancel(ctx))
+		}()
+	}
+	return d.standby, false
+}
+
+// probe checks whether the primary is reachable again and switches back to it if so.
+func (d *FailoverDriver) probe(ctx context.Context) {
+	pctx, cancel := context.WithTimeout(ctx, d.cfg.ProbeTimeout)
+	err := d.primary.Exec(pctx, d.cfg.ProbeQuery, []any{}, nil)
+	cancel()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := time.Now()
+	d.probedAt = now
+	if err != nil {
+		d.cfg.Log(ctx, "failover: primary probe failed", zap.Time("failed_at", d.failedAt), zap.Error(err))
+		return
+	}
+	d.cfg.Log(ctx, "failover: recovered, switched back to primary", zap.Time("recovered_at", now), zap.Time("failed_at", d.failedAt), zap.Duration("downtime", now.Sub(d.failedAt)))
+	d.cfg.Metrics.Gauge(ctx, "entzlog_failover_active", 0)
+	d.failedAt, d.failures = time.Time{}, 0
+}
+
+// observe records the outcome of an operation and switches to the
+// standby once the primary failed MaxFailures times in a row.
+func (d *FailoverDriver) observe(ctx context.Context, primary bool, err error) error {
+	if !primary {
+		return err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	switch {
+	case isConnError(err):
+		d.failures++
+		if d.failures < d.cfg.MaxFailures || !d.failedAt.IsZero() {
+			return err
+		}
+		now := time.Now()
+		d.failedAt, d.probedAt = now, now
+		d.cfg.Log(ctx, "failover: switched to standby", zap.Time("failed_at", now), zap.Int("failures", d.failures), zap.Error(err))
+		d.cfg.Metrics.Count(ctx, "entzlog_failover_switches_total", 1)
+		d.cfg.Metrics.Gauge(ctx, "entzlog_failover_active", 1)
+	case ctx.Err() != nil:
+		// The caller gave up; this says nothing about the primary health.
+	default:
+		d.failures = 0
+	}
+	return err
+}
@@ -0,0 +1,63 @@
+package driver
+
+import (
+	"context"
+	"database/sql"
+
+	"entgo.io/ent/dialect"
+	entsql "entgo.io/ent/dialect/sql"
+)
+
+// execContext calls the ExecContext method of ex if it has one, and falls
+// back to its Exec method otherwise, so drivers that only implement the
+// dialect.ExecQuerier interface can be wrapped as well.
+func execContext(ctx context.Context, ex dialect.ExecQuerier, query string, args []any) (sql.Result, error) {
+	if ex, ok := ex.(interface {
+		ExecContext(context.Context, string, ...any) (sql.Result, error)
+	}); ok {
+		return ex.ExecContext(ctx, query, args...)
+	}
+	var res sql.Result
+	if err := ex.Exec(ctx, query, args, &res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// queryContext calls the QueryContext method of ex if it has one, and falls
+// back to its Query method otherwise. Rows that are not backed by a *sql.Rows
+// are read into memory and returned through the replay database.
+func queryContext(ctx context.Context, ex dialect.ExecQuerier, query string, args []any) (*sql.Rows, error) {
+	if ex, ok := ex.(interface {
+		QueryContext(context.Context, string, ...any) (*sql.Rows, error)
+	}); ok {
+		return ex.QueryContext(ctx, query, args...)
+	}
+	var rows entsql.Rows
+	if err := ex.Query(ctx, query, args, &rows); err != nil {
+		return nil, err
+	}
+	if rows, ok := rows.ColumnScanner.(*sql.Rows); ok {
+		return rows, nil
+	}
+	res, err := materialize(rows.ColumnScanner)
+	if err != nil {
+		return nil, err
+	}
+	return res.rows(ctx)
+}
+
+// beginTx calls the BeginTx method of d if it has one. Otherwise, it falls
+// back to its Tx method when no options are requested, and fails with
+// ErrUnsupported if they are, as they cannot be honored.
+func beginTx(ctx context.Context, d Driver, opts *sql.TxOptions) (dialect.Tx, error) {
+	if d, ok := d.(interface {
+		BeginTx(context.Context, *sql.TxOptions) (dialect.Tx, error)
+	}); ok {
+		return d.BeginTx(ctx, opts)
+	}
+	if opts == nil || *opts == (sql.TxOptions{}) {
+		return d.Tx(ctx)
+	}
+	return nil, unsupported("Driver.BeginTx")
+}
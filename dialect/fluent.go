@@ -0,0 +1,224 @@
+package driver
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"net"
+	"sync"
+	"time"
+)
+
+// FluentConfig configures a FluentSink.
+type FluentConfig struct {
+	// Addr is the address of the Fluentd or Fluent Bit forward input,
+	// e.g. "localhost:24224". Required.
+	Addr string
+	// Network is the network of Addr, "tcp" or "unix". Defaults to "tcp".
+	Network string
+	// Tag is the tag of the records. Defaults to "entzlog".
+	Tag string
+	// Filter selects the forwarded events. Defaults to all events.
+	Filter func(*Event) bool
+	// Interval is the time between two forwarded batches. Defaults to 1s.
+	Interval time.Duration
+	// BatchSize is the maximum number of events of a batch. Defaults to 500.
+	BatchSize int
+	// MaxPending is the maximum number of events waiting to be forwarded.
+	// Events exceeding it are dropped. Defaults to 10000.
+	MaxPending int
+	// Timeout is the connection and write timeout. Defaults to 5s.
+	Timeout time.Duration
+}
+
+// FluentSink is a Sink that forwards the events to Fluentd or Fluent Bit
+// using the Forward protocol, in batches of records with nanosecond
+// timestamps. The connection is re-established after failures.
+type FluentSink struct {
+	cfg  FluentConfig
+	b    *batcher
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// NewFluentSink returns a new FluentSink and starts its sender.
+func NewFluentSink(cfg FluentConfig) *FluentSink {
+	if cfg.Network == "" {
+		cfg.Network = "tcp"
+	}
+	if cfg.Tag == "" {
+		cfg.Tag = "entzlog"
+	}
+	if cfg.Filter == nil {
+		cfg.Filter = func(*Event) bool { return true }
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Second
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 500
+	}
+	if cfg.MaxPending <= 0 {
+		cfg.MaxPending = 10000
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+	s := &FluentSink{cfg: cfg}
+	s.b = newBatcher(cfg.Interval, cfg.BatchSize, cfg.MaxPending, s.send)
+	return s
+}
+
+// Write queues the event if it is selected by the filter.
+func (s *FluentSink) Write(_ context.Context, e *Event) error {
+	if !s.cfg.Filter(e) {
+		return nil
+	}
+	return s.b.add(e)
+}
+
+// Dropped returns the number of events dropped because too many were pending.
+func (s *FluentSink) Dropped() int64 {
+	return s.b.dropped.Load()
+}
+
+// Close forwards the pending events and closes the connection.
+func (s *FluentSink) Close() error {
+	err := s.b.close()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+	return err
+}
+
+// send forwards the events in a single Forward mode message:
+// [tag, [[time, record], ...]].
+func (s *FluentSink) send(events []*Event) error {
+	var m msgpack
+	m.array(2)
+	m.str(s.cfg.Tag)
+	m.array(len(events))
+	for _, e := range events {
+		m.array(2)
+		m.eventTime(e.Time)
+		m.record(eventRecord(e))
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		conn, err := net.DialTimeout(s.cfg.Network, s.cfg.Addr, s.cfg.Timeout)
+		if err != nil {
+			return fmt.Errorf("entzlog: forwarding %d events: %w", len(events), err)
+		}
+		s.conn = conn
+	}
+	s.conn.SetWriteDeadline(time.Now().Add(s.cfg.Timeout))
+	if _, err := s.conn.Write(m); err != nil {
+		s.conn.Close()
+		s.conn = nil
+		return fmt.Errorf("entzlog: forwarding %d events: %w", len(events), err)
+	}
+	return nil
+}
+
+// eventRecord returns the fields of the event as a flat record
+// of strings and numbers. The query arguments are omitted.
+func eventRecord(e *Event) [][2]any {
+	r := [][2]any{
+		{"dialect", e.Dialect},
+		{"op", e.Op},
+		{"status", e.Status()},
+		{"duration_ms", float64(e.Duration) / float64(time.Millisecond)},
+	}
+	for _, f := range [][2]string{
+		{"tx_id", e.TxID},
+		{"tenant", e.Tenant},
+		{"query", e.Query},
+		{"fingerprint", e.Fingerprint()},
+		{"error_class", string(e.ErrorClass)},
+	} {
+		if f[1] != "" {
+			r = append(r, [2]any{f[0], f[1]})
+		}
+	}
+	if e.Err != nil {
+		r = append(r, [2]any{"error", e.Err.Error()})
+	}
+	if e.Failures > 0 {
+		r = append(r, [2]any{"failures", e.Failures})
+	}
+	for _, a := range e.attrs() {
+		r = append(r, [2]any{a.Key, a.Value})
+	}
+	return r
+}
+
+// msgpack is a minimal MessagePack encoder, covering the types
+// used by the Forward protocol messages of the sink.
+type msgpack []byte
+
+func (m *msgpack) array(n int) {
+	switch {
+	case n < 16:
+		*m = append(*m, 0x90|byte(n))
+	case n <= math.MaxUint16:
+		*m = binary.BigEndian.AppendUint16(append(*m, 0xdc), uint16(n))
+	default:
+		*m = binary.BigEndian.AppendUint32(append(*m, 0xdd), uint32(n))
+	}
+}
+
+func (m *msgpack) str(s string) {
+	switch n := len(s); {
+	case n < 32:
+		*m = append(*m, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		*m = append(*m, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		*m = binary.BigEndian.AppendUint16(append(*m, 0xda), uint16(n))
+	default:
+		*m = binary.BigEndian.AppendUint32(append(*m, 0xdb), uint32(n))
+	}
+	*m = append(*m, s...)
+}
+
+func (m *msgpack) int(v int64) {
+	*m = binary.BigEndian.AppendUint64(append(*m, 0xd3), uint64(v))
+}
+
+func (m *msgpack) float(v float64) {
+	*m = binary.BigEndian.AppendUint64(append(*m, 0xcb), math.Float64bits(v))
+}
+
+// eventTime encodes t as the EventTime extension type of the Forward protocol.
+func (m *msgpack) eventTime(t time.Time) {
+	*m = append(*m, 0xd7, 0x00)
+	*m = binary.BigEndian.AppendUint32(*m, uint32(t.Unix()))
+	*m = binary.BigEndian.AppendUint32(*m, uint32(t.Nanosecond()))
+}
+
+// record encodes the key/value pairs as a map.
+func (m *msgpack) record(r [][2]any) {
+	if len(r) < 16 {
+		*m = append(*m, 0x80|byte(len(r)))
+	} else {
+		*m = binary.BigEndian.AppendUint16(append(*m, 0xde), uint16(len(r)))
+	}
+	for _, kv := range r {
+		m.str(kv[0].(string))
+		switch v := kv[1].(type) {
+		case string:
+			m.str(v)
+		case int64:
+			m.int(v)
+		case float64:
+			m.float(v)
+		default:
+			m.str(fmt.Sprint(v))
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package driver
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// GELFConfig configures a GELFSink.
+type GELFConfig struct {
+	// Network is the network of Addr: "udp" or "tcp". Defaults to "udp".
+	Network string
+	// Addr is the address of the Graylog GELF input. Defaults to "localhost:12201".
+	Addr string
+	// Host is the host field of the messages. Defaults to os.Hostname.
+	Host string
+	// Filter selects the sent events. Defaults to all events.
+	Filter func(*Event) bool
+	// ChunkSize is the maximum size of the UDP datagrams, which must exceed
+	// the 12 bytes of the chunk headers. Larger messages are chunked.
+	// Defaults to 1420.
+	ChunkSize int
+	// Interval is the time between two sent batches. Defaults to 1s.
+	Interval time.Duration
+	// BatchSize is the maximum number of events of a batch. Defaults to 500.
+	BatchSize int
+	// MaxPending is the maximum number of events waiting to be sent.
+	// Events exceeding it are dropped. Defaults to 10000.
+	MaxPending int
+	// Timeout is the connection and write timeout. Defaults to 5s.
+	Timeout time.Duration
+}
+
+// gelfChunkHeader is the size of the header of the GELF chunks: magic
+// bytes, message id, sequence number and count.
+const gelfChunkHeader = 12
+
+// GELFSink is a Sink that sends the events to Graylog as GELF messages.
+// UDP messages are compressed, and chunked if they exceed the chunk size.
+// TCP messages are null-byte delimited. The events are sent in batches from
+// a background goroutine.
+type GELFSink struct {
+	cfg  GELFConfig
+	b    *batcher
+	mu   sync.Mutex
+	conn redialer
+}
+
+// NewGELFSink returns a new GELFSink and starts its sender. The connection
+// is established by the first batch, and re-established after failures
+// with a backoff. It fails if the chunk size does not exceed the chunk
+// headers.
+func NewGELFSink(cfg GELFConfig) (*GELFSink, error) {
+	if cfg.Network == "" {
+		cfg.Network = "udp"
+	}
+	if cfg.Addr == "" {
+		cfg.Addr = "localhost:12201"
+	}
+	if cfg.Host == "" {
+		cfg.Host, _ = os.Hostname()
+	}
+	if cfg.Filter == nil {
+		cfg.Filter = func(*Event) bool { return true }
+	}
+	if cfg.ChunkSize == 0 {
+		cfg.ChunkSize = 1420
+	}
+	if cfg.ChunkSize <= gelfChunkHeader {
+		return nil, fmt.Errorf("entzlog: GELF chunk size %d does not exceed the %d bytes of the chunk headers", cfg.ChunkSize, gelfChunkHeader)
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Second
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 500
+	}
+	if cfg.MaxPending <= 0 {
+		cfg.MaxPending = 10000
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+	s := &GELFSink{cfg: cfg, conn: redialer{network: cfg.Network, addr: cfg.Addr, timeout: cfg.Timeout}}
+	s.b = newBatcher(cfg.Interval, cfg.BatchSize, cfg.MaxPending, s.send)
+	return s, nil
+}
+
+// Write queues the event if it is selected by the filter.
+func (s *GELFSink) Write(_ context.Context, e *Event) error {
+	if !s.cfg.Filter(e) {
+		return nil
+	}
+	return s.b.add(e)
+}
+
+// Dropped returns the number of events dropped because too many were pending.
+func (s *GELFSink) Dropped() int64 {
+	return s.b.dropped.Load()
+}
+
+// Close sends the pending events and closes the connection.
+func (s *GELFSink) Close() error {
+	err := s.b.close()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.conn.close()
+	return err
+}
+
+// send sends the events, one message each. The events that cannot be
+// encoded are skipped.
+func (s *GELFSink) send(events []*Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	conn, err := s.conn.get()
+	if err != nil {
+		return fmt.Errorf("entzlog: connecting to graylog: %w", err)
+	}
+	var errs []error
+	for _, e := range events {
+		msg, err := s.message(e)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		var packets [][]byte
+		if s.cfg.Network == "udp" {
+			if packets, err = s.chunks(msg); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+		} else {
+			packets = [][]byte{append(msg, 0)}
+		}
+		conn.SetWriteDeadline(time.Now().Add(s.cfg.Timeout))
+		for _, p := range packets {
+			if _, err := conn.Write(p); err != nil {
+				s.conn.reset()
+				return fmt.Errorf("entzlog: writing to graylog: %w", err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// message returns the event as a GELF 1.1 message.
+func (s *GELFSink) message(e *Event) ([]byte, error) {
+	level := 6 // informational
+	switch {
+	case e.Err != nil || e.Op == OpDegraded:
+		level = 3
+	case e.Slow:
+		level = 4
+	}
+	short := e.Op
+	if e.Query != "" {
+		short = SanitizeQuery(e.Query)
+	}
+	m := map[string]any{
+		"version":       "1.1",
+		"host":          s.cfg.Host,
+		"short_message": short,
+		"timestamp":     float64(e.Time.UnixNano()) / float64(time.Second),
+		"level":         level,
+		"_dialect":      e.Dialect,
+		"_op":           e.Op,
+		"_status":       e.Status(),
+		"_duration_ms":  float64(e.Duration) / float64(time.Millisecond),
+	}
+	if e.Query != "" {
+		m["full_message"] = e.Query
+	}
+	for k, v := range map[string]string{
+		"_tx_id":       e.TxID,
+		"_tenant":      e.Tenant,
+		"_fingerprint": e.Fingerprint(),
+		"_error_class": string(e.ErrorClass),
+	} {
+		if v != "" {
+			m[k] = v
+		}
+	}
+	if e.Err != nil {
+		m["_error"] = e.Err.Error()
+	}
+	if e.Rows >= 0 {
+		m["_rows"] = e.Rows
+	}
+	for _, a := range e.attrs() {
+		m["_"+a.Key] = a.Value
+	}
+	return json.Marshal(m)
+}
+
+// gelfMaxChunks is the maximum number of chunks of a GELF message.
+const gelfMaxChunks = 128
+
+// chunks compresses the message, and splits it into chunks if it does
+// not fit in a single datagram.
+func (s *GELFSink) chunks(msg []byte) ([][]byte, error) {
+	var b bytes.Buffer
+	zw := gzip.NewWriter(&b)
+	if _, err := zw.Write(msg); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	data := b.Bytes()
+	if len(data) <= s.cfg.ChunkSize {
+		return [][]byte{data}, nil
+	}
+	size := s.cfg.ChunkSize - gelfChunkHeader
+	n := (len(data) + size - 1) / size
+	if n > gelfMaxChunks {
+		return nil, fmt.Errorf("entzlog: GELF message too large: %d chunks", n)
+	}
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	chunks := make([][]byte, 0, n)
+	for i := 0; i < n; i++ {
+		chunk := append([]byte{0x1e, 0x0f}, id...)
+		chunk = append(chunk, byte(i), byte(n))
+		chunks = append(chunks, append(chunk, data[i*size:min((i+1)*size, len(data))]...))
+	}
+	return chunks, nil
+}
@@ -0,0 +1,41 @@
+package driver
+
+import (
+	"bytes"
+	"context"
+	"runtime"
+	"strconv"
+
+	"go.uber.org/zap"
+)
+
+// WithGoroutineID returns an option that attaches the id of the calling
+// goroutine as the goroutine_id field of the statement and transaction log
+// entries, to correlate the entries of concurrent workers sharing a context.
+// Getting the id requires formatting the header of the goroutine stack.
+func WithGoroutineID() Option {
+	return func(d *DebugDriver) {
+		d.goroutineID = true
+	}
+}
+
+// goroutineID returns the id of the calling goroutine, parsed from the
+// "goroutine 42 [running]:" header of its stack trace.
+func goroutineID() int64 {
+	var buf [64]byte
+	b := bytes.TrimPrefix(buf[:runtime.Stack(buf[:], false)], []byte("goroutine "))
+	if i := bytes.IndexByte(b, ' '); i != -1 {
+		b = b[:i]
+	}
+	id, _ := strconv.ParseInt(string(b), 10, 64)
+	return id
+}
+
+// logTx logs a transaction step, along with the fields configured by the options.
+func (d *DebugDriver) logTx(ctx context.Context, msg string) {
+	if d.goroutineID {
+		d.log(ctx, msg, zap.Int64("goroutine_id", goroutineID()))
+		return
+	}
+	d.log(ctx, msg)
+}
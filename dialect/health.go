@@ -0,0 +1,149 @@
+package driver
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	entsql "entgo.io/ent/dialect/sql"
+	"go.uber.org/zap"
+)
+
+// HealthQuery is the statement executed by HealthCheck.
+const HealthQuery = "SELECT 1"
+
+// ErrNotReady is the error returned by WaitHealthy when the database
+// is not healthy before the deadline.
+var ErrNotReady = errors.New("entzlog: database not ready")
+
+// Health is the outcome of a HealthCheck.
+type Health struct {
+	Time       time.Time     // start time.
+	Healthy    bool          // whether the health query succeeded.
+	Latency    time.Duration // duration of the health query.
+	Err        error         // error of the health query, if any.
+	ErrorClass ErrorClass    // class of Err.
+	Pool       *sql.DBStats  // stats of the pool of the driver, if it exposes its *sql.DB.
+}
+
+// MarshalJSON implements the json.Marshaler interface, e.g. to write
+// the health to the response of a readiness probe.
+func (h *Health) MarshalJSON() ([]byte, error) {
+	v := struct {
+		Time            time.Time `json:"time"`
+		Status          string    `json:"status"`
+		LatencyMS       float64   `json:"latency_ms"`
+		Error           string    `json:"error,omitempty"`
+		ErrorClass      string    `json:"error_class,omitempty"`
+		OpenConnections *int      `json:"open_connections,omitempty"`
+		InUse           *int      `json:"in_use,omitempty"`
+		Idle            *int      `json:"idle,omitempty"`
+	}{
+		Time:       h.Time,
+		Status:     "ok",
+		LatencyMS:  float64(h.Latency) / float64(time.Millisecond),
+		ErrorClass: string(h.ErrorClass),
+	}
+	if !h.Healthy {
+		v.Status = "error"
+	}
+	if h.Err != nil {
+		v.Error = h.Err.Error()
+	}
+	if h.Pool != nil {
+		v.OpenConnections, v.InUse, v.Idle = &h.Pool.OpenConnections, &h.Pool.InUse, &h.Pool.Idle
+	}
+	return json.Marshal(v)
+}
+
+// HealthCheck executes HealthQuery through the driver, and logs and returns
+// its outcome, e.g. to implement a readiness probe.
+func (d *DebugDriver) HealthCheck(ctx context.Context) *Health {
+	h := d.check(ctx)
+	if h.Err != nil || d.logs(ctx) {
+		d.log(ctx, "driver.HealthCheck", ctxFields(ctx, []zap.Field{
+			zap.Bool("healthy", h.Healthy),
+			zap.Duration("latency", h.Latency),
+			zap.Error(h.Err),
+		})...)
+	}
+	return h
+}
+
+// check executes HealthQuery and returns its outcome.
+func (d *DebugDriver) check(ctx context.Context) *Health {
+	h := &Health{Time: time.Now()}
+	var rows entsql.Rows
+	err := d.Query(ctx, HealthQuery, []any{}, &rows)
+	if err == nil {
+		err = rows.Close()
+	}
+	h.Latency = time.Since(h.Time)
+	h.Healthy = err == nil
+	h.Err = err
+	h.ErrorClass = classifyContextError(ctx, err)
+	if db := d.DB(); db != nil {
+		stats := db.Stats()
+		h.Pool = &stats
+	}
+	return h
+}
+
+// WaitConfig configures WaitHealthy.
+type WaitConfig struct {
+	// Timeout is the time after which WaitHealthy gives up, unless the
+	// context expires first. Defaults to 30 seconds.
+	Timeout time.Duration
+	// InitialBackoff is the delay before the second attempt, doubled after
+	// each failed attempt. Defaults to 100 milliseconds.
+	InitialBackoff time.Duration
+	// MaxBackoff is the maximum delay between two attempts. Defaults to 5 seconds.
+	MaxBackoff time.Duration
+}
+
+// WaitHealthy executes HealthQuery until it succeeds, with an exponential
+// backoff, and returns an error wrapping ErrNotReady and the last error if
+// the timeout or ctx expires first.
+func (d *DebugDriver) WaitHealthy(ctx context.Context, cfg WaitConfig) (*Health, error) {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 30 * time.Second
+	}
+	if cfg.InitialBackoff <= 0 {
+		cfg.InitialBackoff = 100 * time.Millisecond
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = 5 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+	defer cancel()
+	backoff := cfg.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		h := d.check(ctx)
+		fields := []zap.Field{
+			zap.Int("attempt", attempt),
+			zap.Bool("healthy", h.Healthy),
+			zap.Duration("latency", h.Latency),
+		}
+		if h.Healthy {
+			d.log(ctx, "driver.WaitHealthy", ctxFields(ctx, fields)...)
+			return h, nil
+		}
+		if ctx.Err() != nil {
+			d.log(ctx, "driver.WaitHealthy: not ready", ctxFields(ctx, append(fields, zap.Error(h.Err)))...)
+			return h, fmt.Errorf("%w: %v", ErrNotReady, h.Err)
+		}
+		d.log(ctx, "driver.WaitHealthy: retrying", ctxFields(ctx, append(fields, zap.Duration("retry_in", backoff), zap.Error(h.Err)))...)
+		t := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			d.log(ctx, "driver.WaitHealthy: not ready", ctxFields(ctx, append(fields, zap.Error(h.Err)))...)
+			return h, fmt.Errorf("%w: %v", ErrNotReady, h.Err)
+		case <-t.C:
+		}
+		backoff = min(2*backoff, cfg.MaxBackoff)
+	}
+}
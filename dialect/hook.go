@@ -0,0 +1,212 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"entgo.io/ent/dialect"
+	entsql "entgo.io/ent/dialect/sql"
+	"go.uber.org/zap"
+)
+
+// Hook is called around the operations of a DebugDriver and its transactions.
+// The op is the name of the method, e.g. "Exec" or "Commit", and the query and
+// args are empty for transaction operations.
+type Hook interface {
+	// Before is called before the operation is executed. The returned
+	// context is passed to the operation and to After.
+	Before(ctx context.Context, op, query string, args []any) context.Context
+	// After is called after the operation returned, with its error and duration.
+	After(ctx context.Context, op, query string, args []any, err error, d time.Duration)
+}
+
+// Option configures a DebugDriver.
+type Option func(*DebugDriver)
+
+// WithHooks adds hooks to the driver. The Before methods are called in
+// the order the hooks were added, and the After methods in reverse order.
+func WithHooks(hooks ...Hook) Option {
+	return func(d *DebugDriver) {
+		d.hooks = append(d.hooks, hooks...)
+	}
+}
+
+// OnError returns an option that calls fn for every failed statement
+// executed by the driver or its transactions.
+func OnError(fn func(ctx context.Context, op, query string, args []any, err error)) Option {
+	return WithHooks(errorHook(fn))
+}
+
+// errorHook is the Hook installed by OnError.
+type errorHook func(ctx context.Context, op, query string, args []any, err error)
+
+// Before implements the Hook interface.
+func (errorHook) Before(ctx context.Context, _, _ string, _ []any) context.Context { return ctx }
+
+// After calls the hook function if a statement failed.
+func (h errorHook) After(ctx context.Context, op, query string, args []any, err error, _ time.Duration) {
+	if err != nil && query != "" {
+		h(ctx, op, query, args, err)
+	}
+}
+
+// txIDKey is the context key of the transaction id.
+type txIDKey struct{}
+
+// TxIDFromContext returns the id of the DebugTx a hooked operation belongs to.
+func TxIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(txIDKey{}).(string)
+	return id, ok
+}
+
+// run executes fn and calls the hooks of the driver around it. ex executes
+// the audit record of the statement, if any, and v is its destination, used
+// to fill the rows of its event.
+func (d *DebugDriver) run(ctx context.Context, ex dialect.ExecQuerier, txID, op, query string, args, v any, fn func(context.Context) error) error {
+	if !d.hooked(ctx) {
+		return d.done(ctx, txID, op, query, fn(ctx))
+	}
+	if txID != "" {
+		ctx = context.WithValue(ctx, txIDKey{}, txID)
+	}
+	argv := argList(args)
+	for _, h := range d.hooks {
+		ctx = h.Before(ctx, op, query, argv)
+	}
+	region := d.startRegion(ctx, txID, op, query)
+	diag := d.diagnoseLocks(ctx, txID, op, query)
+	call := fn
+	var panicked any
+	if d.recoverPanics && query != "" {
+		call = func(ctx context.Context) (err error) {
+			defer d.recoverPanic(ctx, txID, op, query, &panicked, &err)
+			return fn(ctx)
+		}
+	}
+	start := time.Now()
+	err := d.labeled(ctx, op, query, call)
+	took := time.Since(start)
+	if diag != nil {
+		diag.Stop()
+	}
+	if region != nil {
+		region.End()
+	}
+	if err == nil && ex != nil {
+		err = d.audit(ctx, ex, txID, op, query)
+	}
+	if err == nil && query != "" {
+		d.checkPlan(ctx, txID, op, query, args)
+	}
+	for i := len(d.hooks) - 1; i >= 0; i-- {
+		d.hooks[i].After(ctx, op, query, argv, err, took)
+	}
+	slow := d.slow > 0 && took >= d.slow
+	caller := d.caller()
+	if slow {
+		fields := []zap.Field{zap.String("query", query), zap.Duration("duration", took)}
+		if caller != "" {
+			fields = append(fields, zap.String("caller", caller))
+		}
+		d.log(ctx, d.opMsg(txID, op)+": slow", d.withStack(fields)...)
+	}
+	tenant, _ := TenantFromContext(ctx)
+	entOp, _ := EntOpFromContext(ctx)
+	opID, _ := OpIDFromContext(ctx)
+	var actor string
+	if isWrite(StatementType(query)) {
+		actor, _ = ActorFromContext(ctx)
+	}
+	class := classifyContextError(ctx, err)
+	if stats, ok := RequestStatsFromContext(ctx); ok && query != "" {
+		stats.record(query, took, class)
+	}
+	e := &Event{
+		Time:       start,
+		Dialect:    d.Dialect(),
+		Op:         op,
+		TxID:       txID,
+		Tenant:     tenant,
+		EntOp:      entOp,
+		OpID:       opID,
+		Actor:      actor,
+		Query:      query,
+		Args:       argv,
+		Duration:   took,
+		Rows:       -1,
+		Err:        err,
+		ErrorClass: class,
+		Slow:       slow,
+		Caller:     caller,
+		Baggage:    BaggageFromContext(ctx, d.baggage...),
+	}
+	if rows, ok := v.(*entsql.Rows); ok && err == nil && rows.ColumnScanner != nil && len(d.sinks) > 0 {
+		rows.ColumnScanner = &pendingRows{ColumnScanner: rows.ColumnScanner, emit: func(n int64) {
+			e.Rows = n
+			d.emit(ctx, e)
+		}}
+	} else {
+		if err == nil {
+			e.Rows = affectedRows(v)
+		}
+		d.emit(ctx, e)
+	}
+	err = d.done(ctx, txID, op, query, err)
+	if panicked != nil && !d.panicToError {
+		panic(panicked)
+	}
+	return err
+}
+
+// done tracks the outcome of an operation, logs its error along with
+// its class if it failed, and returns it.
+func (d *DebugDriver) done(ctx context.Context, txID, op, query string, err error) error {
+	d.track(ctx, err)
+	d.record(txID, query)
+	if err == nil {
+		return nil
+	}
+	class := LazyString("error_class", func() string { return string(classifyContextError(ctx, err)) })
+	switch classifyContextError(ctx, err) {
+	case ErrorServerTimeout:
+		d.logServerTimeout(ctx, txID, op, query)
+	case ErrorDeadlock:
+		if d.deadlocks {
+			d.logDeadlock(ctx, txID, op, query, err)
+		}
+	}
+	switch {
+	case d.stackSkip != nil:
+		fields := []zap.Field{zap.Error(err), class}
+		if query != "" {
+			fields = append(fields, zap.String("query", query))
+		}
+		d.log(ctx, d.opMsg(txID, op)+": failed", d.withStack(fields)...)
+	case query == "":
+		logFields(ctx, d.log, d.reuseFields, d.opMsg(txID, op)+": failed", zap.Error(err), class)
+	default:
+		logFields(ctx, d.log, d.reuseFields, d.opMsg(txID, op)+": failed", zap.Error(err), class, zap.String("query", query))
+	}
+	return err
+}
+
+// opMsg returns the log message prefix of the operation.
+func (d *DebugDriver) opMsg(txID, op string) string {
+	if txID != "" && op != "Tx" && op != "BeginTx" {
+		return fmt.Sprintf("Tx(%s).%s", txID, op)
+	}
+	return "driver." + op
+}
+
+// argList returns the args parameter of Exec and Query as a list.
+func argList(args any) []any {
+	switch args := args.(type) {
+	case nil:
+		return nil
+	case []any:
+		return args
+	default:
+		return []any{args}
+	}
+}
@@ -0,0 +1,6 @@
+//go:build !entzlog_noop
+
+package driver
+
+// noop reports whether the instrumentation is compiled out. See instrument_noop.go.
+const noop = false
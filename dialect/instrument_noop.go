@@ -0,0 +1,9 @@
+//go:build entzlog_noop
+
+package driver
+
+// noop reports whether the instrumentation is compiled out. Building with
+// the entzlog_noop tag makes DebugWithContext and the Debug middleware return
+// the underlying drivers untouched, and the drivers returned by Open, OpenDB
+// and OpenConnector neither log their operations nor apply their options.
+const noop = true
@@ -0,0 +1,54 @@
+package driver
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// JSONLConfig configures a JSONLSink.
+type JSONLConfig struct {
+	// Path is the path of the written file. Required.
+	Path string
+	// Rotate configures the rotation of the file.
+	Rotate RotateConfig
+	// Filter selects the written events. Defaults to all events.
+	Filter func(*Event) bool
+}
+
+// JSONLSink is a Sink that writes the events to a file as JSON Lines, one
+// object per event in the format of Event.MarshalJSON. The file is rotated
+// by size and age.
+type JSONLSink struct {
+	cfg JSONLConfig
+	f   *rotatingFile
+}
+
+// NewJSONLSink opens the file of a new JSONLSink.
+func NewJSONLSink(cfg JSONLConfig) (*JSONLSink, error) {
+	if cfg.Filter == nil {
+		cfg.Filter = func(*Event) bool { return true }
+	}
+	f, err := openRotating(cfg.Path, cfg.Rotate, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &JSONLSink{cfg: cfg, f: f}, nil
+}
+
+// Write writes the event to the file if it is selected by the filter.
+func (s *JSONLSink) Write(_ context.Context, e *Event) error {
+	if !s.cfg.Filter(e) {
+		return nil
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = s.f.Write(append(b, '\n'))
+	return err
+}
+
+// Close closes the file.
+func (s *JSONLSink) Close() error {
+	return s.f.Close()
+}
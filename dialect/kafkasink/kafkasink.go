@@ -0,0 +1,79 @@
+// Package kafkasink provides a driver.Sink publishing the events to Kafka.
+package kafkasink
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/floatyun/entzlog/dialect"
+	"github.com/segmentio/kafka-go"
+)
+
+// Config configures the Kafka sink.
+type Config struct {
+	// Brokers are the addresses of the Kafka brokers. Required unless Writer is set.
+	Brokers []string
+	// Topic is the topic the events are published to. Required unless Writer is set.
+	Topic string
+	// BatchSize is the maximum number of events of a batch. Defaults to 100.
+	BatchSize int
+	// BatchTimeout is the maximum time an event waits for its batch
+	// to be sent. Defaults to 1s.
+	BatchTimeout time.Duration
+	// Filter selects the published events. Defaults to the statements,
+	// i.e. transaction events are skipped.
+	Filter func(*driver.Event) bool
+	// Writer overrides the writer built from the above options. It should
+	// be asynchronous, as events are written by the driver operations.
+	Writer *kafka.Writer
+}
+
+// Sink publishes the events as JSON messages keyed by the query
+// fingerprint. Messages are batched and sent in the background.
+type Sink struct {
+	w      *kafka.Writer
+	filter func(*driver.Event) bool
+}
+
+// New returns a new Kafka sink. Use it with driver.WithSink.
+func New(cfg Config) *Sink {
+	w := cfg.Writer
+	if w == nil {
+		if cfg.BatchSize <= 0 {
+			cfg.BatchSize = 100
+		}
+		if cfg.BatchTimeout <= 0 {
+			cfg.BatchTimeout = time.Second
+		}
+		w = &kafka.Writer{
+			Addr:         kafka.TCP(cfg.Brokers...),
+			Topic:        cfg.Topic,
+			Balancer:     &kafka.Hash{},
+			BatchSize:    cfg.BatchSize,
+			BatchTimeout: cfg.BatchTimeout,
+			Async:        true,
+		}
+	}
+	if cfg.Filter == nil {
+		cfg.Filter = func(e *driver.Event) bool { return e.Query != "" }
+	}
+	return &Sink{w: w, filter: cfg.Filter}
+}
+
+// Write queues the event for publishing.
+func (s *Sink) Write(ctx context.Context, e *driver.Event) error {
+	if !s.filter(e) {
+		return nil
+	}
+	v, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return s.w.WriteMessages(ctx, kafka.Message{Key: []byte(e.Fingerprint()), Value: v, Time: e.Time})
+}
+
+// Close flushes the pending messages and closes the writer.
+func (s *Sink) Close() error {
+	return s.w.Close()
+}
@@ -0,0 +1,51 @@
+package driver
+
+import (
+	"context"
+	"database/sql"
+
+	"go.uber.org/zap"
+)
+
+// WithLastInsertID returns an option that logs the last_insert_id of the
+// INSERT statements on the dialects returning it, passed through redact if
+// not nil.
+func WithLastInsertID(redact func(table string, id int64) string) Option {
+	return func(d *DebugDriver) {
+		d.lastInsertID = true
+		d.redactID = redact
+	}
+}
+
+// logInsertID logs the last inserted id of the result held by v, a
+// sql.Result or a *sql.Result, if the statement succeeded, and returns err.
+func (d *DebugDriver) logInsertID(ctx context.Context, txID, op, query string, v any, err error) error {
+	if err != nil || !d.lastInsertID || !d.logs(ctx) {
+		return err
+	}
+	var res sql.Result
+	switch v := v.(type) {
+	case *sql.Result:
+		res = *v
+	case sql.Result:
+		res = v
+	}
+	if res == nil || StatementType(query) != StmtInsert {
+		return nil
+	}
+	id, ierr := res.LastInsertId()
+	if ierr != nil {
+		return nil
+	}
+	table := StatementTable(query)
+	field := zap.Int64("last_insert_id", id)
+	if d.redactID != nil {
+		s := d.redactID(table, id)
+		if s == "" {
+			return nil
+		}
+		field = zap.String("last_insert_id", s)
+	}
+	d.log(ctx, d.opMsg(txID, op)+": inserted", ctxFields(ctx, []zap.Field{zap.String("table", table), field})...)
+	return nil
+}
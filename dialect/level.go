@@ -0,0 +1,60 @@
+package driver
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// WithLogFilter returns an option that logs the operations only if fn returns
+// true for their context. Failures and slow operations are always logged.
+func WithLogFilter(fn func(ctx context.Context) bool) Option {
+	return func(d *DebugDriver) {
+		if prev := d.logFilter; prev != nil {
+			d.logFilter = func(ctx context.Context) bool { return prev(ctx) && fn(ctx) }
+			return
+		}
+		d.logFilter = fn
+	}
+}
+
+// WithLevel returns an option that logs the operations of the driver only
+// if the level is enabled by enab, e.g. the zap.AtomicLevel or the core of
+// the logger behind the LogFunc of the driver. See WithLogFilter.
+func WithLevel(enab zapcore.LevelEnabler, level zapcore.Level) Option {
+	return WithLogFilter(func(context.Context) bool {
+		return enab.Enabled(level)
+	})
+}
+
+// Logger returns a LogFunc writing to the logger at the given level, and the
+// option skipping the operations when the level is disabled:
+//
+//	log, opt := driver.Logger(logger, zap.DebugLevel)
+//	drv := driver.DebugWithContext(d, log, opt)
+func Logger(l *zap.Logger, level zapcore.Level) (LogFunc, Option) {
+	log := func(_ context.Context, msg string, fields ...zap.Field) {
+		if ce := l.Check(level, msg); ce != nil {
+			ce.Write(fields...)
+		}
+	}
+	return log, WithLevel(l.Core(), level)
+}
+
+// logs reports whether the operations executed with the context are logged.
+func (d *DebugDriver) logs(ctx context.Context) bool {
+	return d.logFilter == nil || d.logFilter(ctx)
+}
+
+// hooked reports whether the operations of the driver must be timed and
+// passed to its hooks and sinks, audited, access-logged, labeled, traced or
+// aggregated in the request stats of the context. If not, they are executed
+// directly, to avoid the allocations of the closures passed to run.
+func (d *DebugDriver) hooked(ctx context.Context) bool {
+	if len(d.hooks) > 0 || len(d.sinks) > 0 || d.slow > 0 || d.auditTable != "" || len(d.sensitive) > 0 || d.pprofLabels || d.plans != nil || d.recoverPanics || d.tracing() {
+		return true
+	}
+	_, ok := RequestStatsFromContext(ctx)
+	return ok
+}
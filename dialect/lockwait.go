@@ -0,0 +1,112 @@
+package driver
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"entgo.io/ent/dialect"
+	entsql "entgo.io/ent/dialect/sql"
+	"go.uber.org/zap"
+)
+
+// Queries listing the sessions blocking a statement, by dialect. MySQL lists
+// all the lock waits of the server, as its statements cannot be matched.
+const (
+	pgLockWaits = `WITH waiting AS (
+	SELECT pid, wait_event_type, wait_event
+	FROM pg_stat_activity
+	WHERE pid <> pg_backend_pid() AND wait_event_type = 'Lock' AND query <> '' AND starts_with($1, query)
+	ORDER BY abs(extract(epoch FROM now() - query_start) - $2)
+	LIMIT 1
+)
+SELECT waiting.pid AS waiting_pid, waiting.wait_event_type AS wait_event_type, waiting.wait_event AS wait_event,
+	blocking.pid AS blocking_pid, blocking.state AS blocking_state, blocking.query AS blocking_query, blocking.xact_start AS blocking_xact_start
+FROM waiting
+CROSS JOIN LATERAL unnest(pg_blocking_pids(waiting.pid)) AS b(pid)
+JOIN pg_stat_activity blocking ON blocking.pid = b.pid
+LIMIT 10`
+	mysqlLockWaits = `SELECT waiting_pid, waiting_query, locked_table, locked_index, locked_type, wait_age,
+	blocking_pid, blocking_query, blocking_trx_started
+FROM sys.innodb_lock_waits
+LIMIT 10`
+)
+
+// lockDiagnosticsTimeout bounds the diagnostic queries of WithLockDiagnostics.
+const lockDiagnosticsTimeout = 5 * time.Second
+
+// WithLockDiagnostics returns an option that logs the sessions blocking the
+// statements running for longer than the slow threshold on Postgres, or all
+// the lock waits of the server on MySQL.
+func WithLockDiagnostics() Option {
+	return func(d *DebugDriver) {
+		d.lockDiag = true
+	}
+}
+
+// diagnoseLocks returns a timer logging the lock waits of the statement once
+// it runs for longer than the slow threshold, to stop once it returns, or nil
+// if they are not diagnosed.
+func (d *DebugDriver) diagnoseLocks(ctx context.Context, txID, op, query string) *time.Timer {
+	if !d.lockDiag || d.slow <= 0 || query == "" {
+		return nil
+	}
+	dialectName := d.Dialect()
+	if dialectName != dialect.Postgres && dialectName != dialect.MySQL {
+		return nil
+	}
+	start := time.Now()
+	return time.AfterFunc(d.slow, func() {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), lockDiagnosticsTimeout)
+		defer cancel()
+		diag, args, msg := pgLockWaits, []any{query, time.Since(start).Seconds()}, ": waiting on locks"
+		if dialectName == dialect.MySQL {
+			diag, args, msg = mysqlLockWaits, []any{}, ": server lock waits"
+		}
+		waits, err := lockWaits(ctx, d.Driver, diag, args)
+		if err != nil {
+			d.log(ctx, d.opMsg(txID, op)+": lock diagnostics failed", zap.String("query", query), zap.Error(err))
+			return
+		}
+		for _, w := range waits {
+			d.log(ctx, d.opMsg(txID, op)+msg, append([]zap.Field{zap.String("query", query), zap.String("tx_id", txID)}, w...)...)
+		}
+	})
+}
+
+// lockWaits executes the diagnostic query, and returns its rows as fields
+// named after their columns.
+func lockWaits(ctx context.Context, ex dialect.ExecQuerier, query string, args []any) ([][]zap.Field, error) {
+	var rows entsql.Rows
+	if err := ex.Query(ctx, query, args, &rows); err != nil {
+		return nil, fmt.Errorf("entzlog: diagnosing locks: %w", err)
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, fmt.Errorf("entzlog: diagnosing locks: %w", err)
+	}
+	var waits [][]zap.Field
+	for rows.Next() {
+		values := make([]sql.NullString, len(columns))
+		dest := make([]any, len(columns))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("entzlog: diagnosing locks: %w", err)
+		}
+		fields := make([]zap.Field, 0, len(columns))
+		for i, c := range columns {
+			if values[i].Valid {
+				fields = append(fields, zap.String(c, values[i].String))
+			}
+		}
+		waits = append(waits, fields)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("entzlog: diagnosing locks: %w", err)
+	}
+	return waits, nil
+}
@@ -0,0 +1,147 @@
+package driver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// LokiConfig configures a LokiSink.
+type LokiConfig struct {
+	// URL is the push endpoint, e.g. "http://loki:3100/loki/api/v1/push". Required.
+	URL string
+	// Client is the HTTP client used to push the events. Defaults to a
+	// client with a 10s timeout.
+	Client *http.Client
+	// Header holds additional request headers, e.g. X-Scope-OrgID.
+	Header http.Header
+	// Labels are static labels added to all streams, e.g. {"app": "api"}.
+	// They must be of low cardinality.
+	Labels map[string]string
+	// Filter selects the pushed events. Defaults to all events.
+	Filter func(*Event) bool
+	// Interval is the time between two pushes. Defaults to 1s.
+	Interval time.Duration
+	// BatchSize is the maximum number of events of a push. Defaults to 500.
+	BatchSize int
+	// MaxPending is the maximum number of events waiting to be pushed.
+	// Events exceeding it are dropped. Defaults to 10000.
+	MaxPending int
+}
+
+// LokiSink is a Sink that pushes the events to Grafana Loki, in streams
+// labeled by the op, dialect and status of the events.
+type LokiSink struct {
+	cfg LokiConfig
+	b   *batcher
+}
+
+// NewLokiSink returns a new LokiSink and starts its sender.
+func NewLokiSink(cfg LokiConfig) *LokiSink {
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	if cfg.Filter == nil {
+		cfg.Filter = func(*Event) bool { return true }
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Second
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 500
+	}
+	if cfg.MaxPending <= 0 {
+		cfg.MaxPending = 10000
+	}
+	s := &LokiSink{cfg: cfg}
+	s.b = newBatcher(cfg.Interval, cfg.BatchSize, cfg.MaxPending, s.send)
+	return s
+}
+
+// Write queues the event if it is selected by the filter.
+func (s *LokiSink) Write(_ context.Context, e *Event) error {
+	if !s.cfg.Filter(e) {
+		return nil
+	}
+	return s.b.add(e)
+}
+
+// Dropped returns the number of events dropped because too many were pending.
+func (s *LokiSink) Dropped() int64 {
+	return s.b.dropped.Load()
+}
+
+// Close stops the sender and pushes the pending events.
+func (s *LokiSink) Close() error {
+	return s.b.close()
+}
+
+// lokiStream is a stream of the Loki push API.
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+// send pushes the events, grouped by stream.
+func (s *LokiSink) send(events []*Event) error {
+	var (
+		streams []*lokiStream
+		byKey   = make(map[[3]string]*lokiStream)
+	)
+	for _, e := range events {
+		key := [3]string{e.Op, e.Dialect, e.Status()}
+		st, ok := byKey[key]
+		if !ok {
+			labels := map[string]string{"op": key[0], "dialect": key[1], "status": key[2]}
+			for k, v := range s.cfg.Labels {
+				labels[k] = v
+			}
+			st = &lokiStream{Stream: labels}
+			byKey[key] = st
+			streams = append(streams, st)
+		}
+		line, err := lokiLine(e)
+		if err != nil {
+			return err
+		}
+		st.Values = append(st.Values, [2]string{strconv.FormatInt(e.Time.UnixNano(), 10), line})
+	}
+	body, err := json.Marshal(map[string]any{"streams": streams})
+	if err != nil {
+		return err
+	}
+	return post(s.cfg.Client, s.cfg.URL, s.cfg.Header, body, len(events))
+}
+
+// lokiLine encodes the high-cardinality content of the event as a JSON line.
+func lokiLine(e *Event) (string, error) {
+	v := struct {
+		Query       string  `json:"query,omitempty"`
+		Fingerprint string  `json:"fingerprint,omitempty"`
+		TxID        string  `json:"tx_id,omitempty"`
+		Tenant      string  `json:"tenant,omitempty"`
+		DurationMS  float64 `json:"duration_ms"`
+		Error       string  `json:"error,omitempty"`
+		ErrorClass  string  `json:"error_class,omitempty"`
+		Failures    int64   `json:"failures,omitempty"`
+	}{
+		Query:       e.Query,
+		Fingerprint: e.Fingerprint(),
+		TxID:        e.TxID,
+		Tenant:      e.Tenant,
+		DurationMS:  float64(e.Duration) / float64(time.Millisecond),
+		ErrorClass:  string(e.ErrorClass),
+		Failures:    e.Failures,
+	}
+	if e.Err != nil {
+		v.Error = e.Err.Error()
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	b, err = appendAttrs(b, e.attrs())
+	return string(b), err
+}
@@ -0,0 +1,187 @@
+package driver
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+
+	"entgo.io/ent/dialect"
+	"go.uber.org/zap"
+)
+
+// ErrMaintenance is returned for the statements rejected by a
+// MaintenanceDriver in maintenance mode. It is wrapped with the type of
+// the statement, and can be checked with errors.Is.
+var ErrMaintenance = errors.New("entzlog: writes are disabled for maintenance")
+
+// MaintenanceConfig configures a MaintenanceDriver.
+type MaintenanceConfig struct {
+	// Enabled starts the driver in maintenance mode.
+	Enabled bool
+	// Log is the log function. Optional.
+	Log LogFunc
+	// Metrics receives the number of rejected statements. Optional.
+	Metrics Metrics
+}
+
+// MaintenanceDriver is a driver that rejects the statements that may write
+// with ErrMaintenance while in maintenance mode. It implements http.Handler
+// to be switched with POST ?enabled=true, e.g. from an admin endpoint.
+type MaintenanceDriver struct {
+	Driver  // underlying driver.
+	cfg     MaintenanceConfig
+	enabled atomic.Bool
+}
+
+// NewMaintenanceDriver returns a new MaintenanceDriver wrapping the given driver.
+func NewMaintenanceDriver(d Driver, cfg MaintenanceConfig) *MaintenanceDriver {
+	if cfg.Log == nil {
+		cfg.Log = nopLog
+	}
+	if cfg.Metrics == nil {
+		cfg.Metrics = nopMetrics{}
+	}
+	m := &MaintenanceDriver{Driver: d, cfg: cfg}
+	m.enabled.Store(cfg.Enabled)
+	return m
+}
+
+// Enabled reports whether the driver is in maintenance mode.
+func (d *MaintenanceDriver) Enabled() bool {
+	return d.enabled.Load()
+}
+
+// SetEnabled switches the maintenance mode on or off.
+func (d *MaintenanceDriver) SetEnabled(ctx context.Context, enabled bool) {
+	if d.enabled.Swap(enabled) != enabled {
+		d.cfg.Log(ctx, "maintenance: switched", zap.Bool("enabled", enabled))
+	}
+}
+
+// ServeHTTP returns the maintenance mode on GET, and sets it on POST.
+func (d *MaintenanceDriver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		enabled, err := strconv.ParseBool(r.FormValue("enabled"))
+		if err != nil {
+			http.Error(w, "invalid enabled value", http.StatusBadRequest)
+			return
+		}
+		d.SetEnabled(r.Context(), enabled)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]bool{"enabled": d.Enabled()})
+}
+
+// Exec rejects the write statements in maintenance mode and calls the underlying driver Exec method otherwise.
+func (d *MaintenanceDriver) Exec(ctx context.Context, query string, args, v any) error {
+	if err := d.check(ctx, "maintenance.Exec", query); err != nil {
+		return err
+	}
+	return d.Driver.Exec(ctx, query, args, v)
+}
+
+// ExecContext rejects the write statements in maintenance mode and calls the underlying driver ExecContext method otherwise.
+func (d *MaintenanceDriver) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	if err := d.check(ctx, "maintenance.ExecContext", query); err != nil {
+		return nil, err
+	}
+	return execContext(ctx, d.Driver, query, args)
+}
+
+// Query rejects the write statements in maintenance mode and calls the underlying driver Query method otherwise.
+func (d *MaintenanceDriver) Query(ctx context.Context, query string, args, v any) error {
+	if err := d.check(ctx, "maintenance.Query", query); err != nil {
+		return err
+	}
+	return d.Driver.Query(ctx, query, args, v)
+}
+
+// QueryContext rejects the write statements in maintenance mode and calls the underlying driver QueryContext method otherwise.
+func (d *MaintenanceDriver) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	if err := d.check(ctx, "maintenance.QueryContext", query); err != nil {
+		return nil, err
+	}
+	return queryContext(ctx, d.Driver, query, args)
+}
+
+// Tx starts a transaction rejecting the write statements in maintenance mode.
+func (d *MaintenanceDriver) Tx(ctx context.Context) (dialect.Tx, error) {
+	tx, err := d.Driver.Tx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &checkedTx{Tx: tx, name: "maintenance", check: d.check}, nil
+}
+
+// BeginTx starts a transaction with options rejecting the write statements
+// in maintenance mode, if it is supported by the underlying driver.
+func (d *MaintenanceDriver) BeginTx(ctx context.Context, opts *sql.TxOptions) (dialect.Tx, error) {
+	tx, err := beginTx(ctx, d.Driver, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &checkedTx{Tx: tx, name: "maintenance", check: d.check}, nil
+}
+
+// check returns ErrMaintenance for the statements other than plain reads
+// in maintenance mode.
+func (d *MaintenanceDriver) check(ctx context.Context, op, query string) error {
+	if !d.enabled.Load() || isPlainRead(query) {
+		return nil
+	}
+	typ := StatementType(query)
+	d.cfg.Log(ctx, op+": rejected", zap.String("query", query))
+	d.cfg.Metrics.Count(ctx, "entzlog_maintenance_rejected_total", 1, Label{"stmt_type", typ})
+	return fmt.Errorf("%w: %s", ErrMaintenance, typ)
+}
+
+// checkedTx is a transaction checking its statements before executing
+// them, e.g. the transactions of the MaintenanceDriver.
+type checkedTx struct {
+	dialect.Tx
+	name  string // name of the driver, prefixing the operations.
+	check func(ctx context.Context, op, query string) error
+}
+
+// Exec checks the statement and calls the underlying transaction Exec method.
+func (tx *checkedTx) Exec(ctx context.Context, query string, args, v any) error {
+	if err := tx.check(ctx, tx.name+".Tx.Exec", query); err != nil {
+		return err
+	}
+	return tx.Tx.Exec(ctx, query, args, v)
+}
+
+// ExecContext checks the statement and calls the underlying transaction ExecContext method.
+func (tx *checkedTx) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	if err := tx.check(ctx, tx.name+".Tx.ExecContext", query); err != nil {
+		return nil, err
+	}
+	return execContext(ctx, tx.Tx, query, args)
+}
+
+// Query checks the statement and calls the underlying transaction Query method.
+func (tx *checkedTx) Query(ctx context.Context, query string, args, v any) error {
+	if err := tx.check(ctx, tx.name+".Tx.Query", query); err != nil {
+		return err
+	}
+	return tx.Tx.Query(ctx, query, args, v)
+}
+
+// QueryContext checks the statement and calls the underlying transaction QueryContext method.
+func (tx *checkedTx) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	if err := tx.check(ctx, tx.name+".Tx.QueryContext", query); err != nil {
+		return nil, err
+	}
+	return queryContext(ctx, tx.Tx, query, args)
+}
@@ -0,0 +1,212 @@
+package driver
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"sync/atomic"
+
+	"entgo.io/ent/dialect"
+	entsql "entgo.io/ent/dialect/sql"
+	"go.uber.org/zap"
+)
+
+// memo holds the memoized results of a single request.
+type memo struct {
+	mu      sync.Mutex
+	results map[string]*result
+	gen     uint64 // incremented on every clear of the results.
+}
+
+type memoKey struct{}
+
+// WithMemo returns a context that enables memoization of read-only queries
+// executed through a MemoDriver. It is typically called once per request.
+func WithMemo(ctx context.Context) context.Context {
+	return context.WithValue(ctx, memoKey{}, &memo{results: make(map[string]*result)})
+}
+
+// MemoConfig configures a MemoDriver.
+type MemoConfig struct {
+	// Log is the log function. Optional.
+	Log LogFunc
+	// Metrics receives the memoization measurements. Optional.
+	Metrics Metrics
+}
+
+// MemoDriver is a driver that memoizes the results of read-only queries
+// executed outside of transactions within a context created by WithMemo,
+// cleared by the writes executed with that context.
+type MemoDriver struct {
+	Driver // underlying driver.
+	cfg    MemoConfig
+}
+
+// NewMemoDriver returns a new MemoDriver wrapping the given driver.
+func NewMemoDriver(d Driver, cfg MemoConfig) *MemoDriver {
+	if cfg.Log == nil {
+		cfg.Log = nopLog
+	}
+	if cfg.Metrics == nil {
+		cfg.Metrics = nopMetrics{}
+	}
+	return &MemoDriver{Driver: d, cfg: cfg}
+}
+
+// Exec clears the request memo before and after calling the underlying driver Exec method.
+func (d *MemoDriver) Exec(ctx context.Context, query string, args, v any) error {
+	d.forget(ctx)
+	defer d.forget(ctx)
+	return d.Driver.Exec(ctx, query, args, v)
+}
+
+// ExecContext clears the request memo before and after calling the underlying driver ExecContext method.
+func (d *MemoDriver) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	d.forget(ctx)
+	defer d.forget(ctx)
+	return execContext(ctx, d.Driver, query, args)
+}
+
+// Query serves memoized read-only queries and calls the underlying driver Query method otherwise.
+func (d *MemoDriver) Query(ctx context.Context, query string, args, v any) error {
+	m, _ := ctx.Value(memoKey{}).(*memo)
+	vr, ok := v.(*entsql.Rows)
+	if m == nil || !ok || !isReadOnly(query) {
+		d.forget(ctx)
+		defer d.forget(ctx)
+		return d.Driver.Query(ctx, query, args, v)
+	}
+	rows, err := d.lookup(ctx, m, "memo.Query", query, args, func() (entsql.ColumnScanner, error) {
+		var rows entsql.Rows
+		if err := d.Driver.Query(ctx, query, args, &rows); err != nil {
+			return nil, err
+		}
+		return rows.ColumnScanner, nil
+	})
+	if err != nil {
+		return err
+	}
+	*vr = entsql.Rows{ColumnScanner: rows}
+	return nil
+}
+
+// QueryContext serves memoized read-only queries and calls the underlying driver QueryContext method otherwise.
+func (d *MemoDriver) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	m, _ := ctx.Value(memoKey{}).(*memo)
+	if m == nil || !isReadOnly(query) {
+		d.forget(ctx)
+		defer d.forget(ctx)
+		return queryContext(ctx, d.Driver, query, args)
+	}
+	return d.lookup(ctx, m, "memo.QueryContext", query, args, func() (entsql.ColumnScanner, error) {
+		return queryContext(ctx, d.Driver, query, args)
+	})
+}
+
+// Tx starts a transaction that clears the request memo once committed, if it wrote.
+func (d *MemoDriver) Tx(ctx context.Context) (dialect.Tx, error) {
+	tx, err := d.Driver.Tx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &memoTx{Tx: tx, drv: d, ctx: ctx}, nil
+}
+
+// BeginTx starts a transaction with options if it is supported by the underlying driver.
+// The transaction clears the request memo once committed, if it wrote.
+func (d *MemoDriver) BeginTx(ctx context.Context, opts *sql.TxOptions) (dialect.Tx, error) {
+	tx, err := beginTx(ctx, d.Driver, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &memoTx{Tx: tx, drv: d, ctx: ctx}, nil
+}
+
+// lookup returns the memoized result of the query, or executes it with exec
+// and memoizes its result.
+func (d *MemoDriver) lookup(ctx context.Context, m *memo, op, query string, args any, exec func() (entsql.ColumnScanner, error)) (*sql.Rows, error) {
+	key := cacheKey(query, args)
+	m.mu.Lock()
+	res, ok := m.results[key]
+	gen := m.gen
+	m.mu.Unlock()
+	if ok {
+		d.cfg.Log(ctx, op, zap.String("query", query), zap.Bool("memoized", true))
+		d.cfg.Metrics.Count(ctx, "entzlog_memo_hits_total", 1)
+		return res.rows(ctx)
+	}
+	rs, err := exec()
+	if err != nil {
+		return nil, err
+	}
+	if res, err = materialize(rs); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	if m.gen == gen {
+		m.results[key] = res
+	}
+	m.mu.Unlock()
+	return res.rows(ctx)
+}
+
+// forget clears the memoized results of the request, if any.
+func (d *MemoDriver) forget(ctx context.Context) {
+	if m, ok := ctx.Value(memoKey{}).(*memo); ok {
+		m.mu.Lock()
+		clear(m.results)
+		m.gen++
+		m.mu.Unlock()
+	}
+}
+
+// memoTx is a transaction that clears the memo of the request it was
+// started with once committed, if it wrote.
+type memoTx struct {
+	dialect.Tx
+	drv   *MemoDriver
+	ctx   context.Context
+	wrote atomic.Bool
+}
+
+// Exec records the write and calls the underlying transaction Exec method.
+func (tx *memoTx) Exec(ctx context.Context, query string, args, v any) error {
+	tx.record(query)
+	return tx.Tx.Exec(ctx, query, args, v)
+}
+
+// Query records the write, if the query is one, and calls the underlying transaction Query method.
+func (tx *memoTx) Query(ctx context.Context, query string, args, v any) error {
+	tx.record(query)
+	return tx.Tx.Query(ctx, query, args, v)
+}
+
+// ExecContext records the write and calls the underlying transaction ExecContext method.
+func (tx *memoTx) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	tx.record(query)
+	return execContext(ctx, tx.Tx, query, args)
+}
+
+// QueryContext records the write, if the query is one, and calls the underlying
+// transaction QueryContext method.
+func (tx *memoTx) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	tx.record(query)
+	return queryContext(ctx, tx.Tx, query, args)
+}
+
+// Commit calls the underlying transaction Commit method and clears the
+// request memo if the transaction wrote.
+func (tx *memoTx) Commit() error {
+	err := tx.Tx.Commit()
+	if err == nil && tx.wrote.Load() {
+		tx.drv.forget(tx.ctx)
+	}
+	return err
+}
+
+// record records whether the query is a write.
+func (tx *memoTx) record(query string) {
+	if !isReadOnly(query) {
+		tx.wrote.Store(true)
+	}
+}
@@ -0,0 +1,87 @@
+package driver
+
+import (
+	"context"
+	"testing"
+
+	"entgo.io/ent/dialect"
+	entsql "entgo.io/ent/dialect/sql"
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestMemoDriver(t *testing.T) {
+	const (
+		query  = "SELECT id FROM users"
+		update = "UPDATE users SET name = 'a8m'"
+	)
+	read := func(ctx context.Context, d *MemoDriver) error {
+		_, err := d.QueryContext(ctx, query)
+		return err
+	}
+	write := func(ctx context.Context, d *MemoDriver) error {
+		_, err := d.ExecContext(ctx, update)
+		return err
+	}
+	tests := []struct {
+		name    string
+		steps   []func(context.Context, *MemoDriver) error
+		queries int
+		writes  int
+	}{
+		{
+			name:    "memoized",
+			steps:   []func(context.Context, *MemoDriver) error{read, read},
+			queries: 1,
+		},
+		{
+			name:    "write",
+			steps:   []func(context.Context, *MemoDriver) error{read, write, read},
+			queries: 2,
+			writes:  1,
+		},
+		{
+			name: "write during read",
+			steps: []func(context.Context, *MemoDriver) error{
+				func(ctx context.Context, d *MemoDriver) error {
+					_, err := d.lookup(ctx, ctx.Value(memoKey{}).(*memo), "memo.QueryContext", query, []any{}, func() (entsql.ColumnScanner, error) {
+						rows, err := queryContext(ctx, d.Driver, query, []any{})
+						if err != nil {
+							return nil, err
+						}
+						return rows, write(ctx, d)
+					})
+					return err
+				},
+				read,
+			},
+			queries: 2,
+			writes:  1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			mock.MatchExpectationsInOrder(false)
+			for range tt.queries {
+				mock.ExpectQuery(query).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+			}
+			for range tt.writes {
+				mock.ExpectExec(update).WillReturnResult(sqlmock.NewResult(0, 1))
+			}
+			drv := NewMemoDriver(entsql.OpenDB(dialect.Postgres, db), MemoConfig{})
+			ctx := WithMemo(context.Background())
+			for _, step := range tt.steps {
+				if err := step(ctx, drv); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,210 @@
+package driver
+
+import (
+	"container/heap"
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// Label is a name/value pair attached to a reported metric.
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Metrics is the interface used by the drivers in this package to report
+// measurements. Implementations must be safe for concurrent use.
+type Metrics interface {
+	// Count adds delta to the counter identified by name and labels.
+	Count(ctx context.Context, name string, delta float64, labels ...Label)
+	// Gauge sets the gauge identified by name and labels to v.
+	Gauge(ctx context.Context, name string, v float64, labels ...Label)
+	// Observe records d in the histogram identified by name and labels.
+	Observe(ctx context.Context, name string, d time.Duration, labels ...Label)
+}
+
+type traceIDKey struct{}
+
+// WithTraceID returns a context carrying the id of the trace the operations
+// executed with it belong to. Metrics implementations may attach it to their
+// measurements as exemplars, e.g. prommetrics to its histogram buckets.
+func WithTraceID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, traceIDKey{}, id)
+}
+
+// TraceIDFromContext returns the trace id stored in the context, if any,
+// or else the trace id of the OpenTelemetry span of the context, if any.
+func TraceIDFromContext(ctx context.Context) (string, bool) {
+	if id, ok := ctx.Value(traceIDKey{}).(string); ok && id != "" {
+		return id, true
+	}
+	if sc := oteltrace.SpanContextFromContext(ctx); sc.HasTraceID() {
+		return sc.TraceID().String(), true
+	}
+	return "", false
+}
+
+// LimitCardinality returns a Metrics passing the measurements to m with at
+// most max distinct values for each label name, e.g. to bound the number of
+// series of the table or fingerprint labels. The values seen once max is
+// reached are replaced by "other". The empty values are not counted.
+func LimitCardinality(m Metrics, max int) Metrics {
+	return &limitedMetrics{m: m, max: max, seen: make(map[string]map[string]bool)}
+}
+
+// limitedMetrics is the Metrics returned by LimitCardinality.
+type limitedMetrics struct {
+	m    Metrics
+	max  int
+	mu   sync.Mutex
+	seen map[string]map[string]bool // seen values, by label name.
+}
+
+func (l *limitedMetrics) Count(ctx context.Context, name string, delta float64, labels ...Label) {
+	l.m.Count(ctx, name, delta, l.limit(labels)...)
+}
+
+func (l *limitedMetrics) Gauge(ctx context.Context, name string, v float64, labels ...Label) {
+	l.m.Gauge(ctx, name, v, l.limit(labels)...)
+}
+
+func (l *limitedMetrics) Observe(ctx context.Context, name string, d time.Duration, labels ...Label) {
+	l.m.Observe(ctx, name, d, l.limit(labels)...)
+}
+
+// limit returns a copy of the labels with the values exceeding
+// the limit of their name replaced by "other".
+func (l *limitedMetrics) limit(labels []Label) []Label {
+	limited := make([]Label, len(labels))
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i, lb := range labels {
+		limited[i] = lb
+		if lb.Value == "" {
+			continue
+		}
+		seen, ok := l.seen[lb.Name]
+		if !ok {
+			seen = make(map[string]bool)
+			l.seen[lb.Name] = seen
+		}
+		switch {
+		case seen[lb.Value]:
+		case len(seen) < l.max:
+			seen[lb.Value] = true
+		default:
+			limited[i].Value = "other"
+		}
+	}
+	return limited
+}
+
+// nopMetrics is the Metrics used when none is configured.
+type nopMetrics struct{}
+
+func (nopMetrics) Count(context.Context, string, float64, ...Label)         {}
+func (nopMetrics) Gauge(context.Context, string, float64, ...Label)         {}
+func (nopMetrics) Observe(context.Context, string, time.Duration, ...Label) {}
+
+// topK bounds the values of a label to k of its most frequent values,
+// counted with the space-saving algorithm and promoted every 16k occurrences.
+type topK struct {
+	k       int
+	mu      sync.Mutex
+	n       int                     // occurrences since the last promotion.
+	counts  map[string]*topKCounter // counters of the candidate values.
+	heap    topKHeap                // counters of the candidates, least frequent first.
+	emitted map[string]bool
+}
+
+// topKCounter is the approximate count of a candidate value.
+type topKCounter struct {
+	v string
+	n int64
+	i int // index in the heap.
+}
+
+// newTopK returns a new topK emitting k values.
+func newTopK(k int) *topK {
+	return &topK{k: k, counts: make(map[string]*topKCounter, 4*k), emitted: make(map[string]bool, k)}
+}
+
+// label counts an occurrence of the value, and returns it if it is emitted,
+// or "other".
+func (t *topK) label(v string) string {
+	if v == "" {
+		return v
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	switch {
+	case t.emitted[v]:
+		return v
+	case len(t.emitted) >= t.k:
+		return "other"
+	}
+	switch c, ok := t.counts[v]; {
+	case ok:
+		c.n++
+		heap.Fix(&t.heap, c.i)
+	case len(t.heap) < 4*t.k:
+		c = &topKCounter{v: v, n: 1}
+		t.counts[v] = c
+		heap.Push(&t.heap, c)
+	default:
+		// Replace the least frequent value, inheriting its count.
+		c = t.heap[0]
+		delete(t.counts, c.v)
+		c.v = v
+		c.n++
+		t.counts[v] = c
+		heap.Fix(&t.heap, 0)
+	}
+	if t.n++; t.n >= 16*t.k {
+		t.n = 0
+		t.promote()
+	}
+	if t.emitted[v] {
+		return v
+	}
+	return "other"
+}
+
+// promote emits the most frequent candidates, until k values are emitted.
+func (t *topK) promote() {
+	top := append(topKHeap(nil), t.heap...)
+	sort.Slice(top, func(i, j int) bool { return top[i].n > top[j].n })
+	for _, c := range top[:min(len(top), t.k-len(t.emitted))] {
+		t.emitted[c.v] = true
+		delete(t.counts, c.v)
+		heap.Remove(&t.heap, c.i)
+	}
+}
+
+// topKHeap is a min-heap of counters, implementing heap.Interface.
+type topKHeap []*topKCounter
+
+func (h topKHeap) Len() int           { return len(h) }
+func (h topKHeap) Less(i, j int) bool { return h[i].n < h[j].n }
+
+func (h topKHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].i, h[j].i = i, j
+}
+
+func (h *topKHeap) Push(x any) {
+	c := x.(*topKCounter)
+	c.i = len(*h)
+	*h = append(*h, c)
+}
+
+func (h *topKHeap) Pop() any {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
@@ -0,0 +1,86 @@
+package driver
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestTopK(t *testing.T) {
+	repeat := func(n int, vs ...string) []string {
+		var s []string
+		for i := 0; i < n; i++ {
+			s = append(s, vs...)
+		}
+		return s
+	}
+	tests := []struct {
+		name    string
+		k       int
+		values  []string
+		emitted []string
+		other   []string
+	}{
+		{
+			name:    "frequent after rare",
+			k:       2,
+			values:  append([]string{"a", "b"}, repeat(50, "c", "d")...),
+			emitted: []string{"c", "d"},
+			other:   []string{"a", "b"},
+		},
+		{
+			name:    "ranked by frequency",
+			k:       1,
+			values:  append(repeat(5, "a", "b"), repeat(20, "c")...),
+			emitted: []string{"c"},
+			other:   []string{"a", "b"},
+		},
+		{
+			name:    "evicted rare values",
+			k:       1,
+			values:  append(repeat(1, "a", "b", "c", "d", "e", "f"), repeat(20, "g")...),
+			emitted: []string{"g"},
+			other:   []string{"a", "b", "c", "d", "e", "f"},
+		},
+		{
+			name:    "fewer values than k",
+			k:       3,
+			values:  repeat(30, "a", "b"),
+			emitted: []string{"a", "b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			top := newTopK(tt.k)
+			for _, v := range tt.values {
+				top.label(v)
+			}
+			for _, v := range tt.emitted {
+				if got := top.label(v); got != v {
+					t.Errorf("label(%q) = %q, want %q", v, got, v)
+				}
+			}
+			for _, v := range tt.other {
+				if got := top.label(v); got != "other" {
+					t.Errorf("label(%q) = %q, want other", v, got)
+				}
+			}
+		})
+	}
+}
+
+func TestTopKBounded(t *testing.T) {
+	top := newTopK(3)
+	seen := make(map[string]bool)
+	for i := 0; i < 10000; i++ {
+		seen[top.label(fmt.Sprint(i%500))] = true
+	}
+	if len(seen) > 4 {
+		t.Errorf("emitted %d values, want at most 3 and other", len(seen))
+	}
+	if len(top.heap) > 12 || len(top.counts) != len(top.heap) {
+		t.Errorf("tracked %d counters and %d values, want at most 12", len(top.heap), len(top.counts))
+	}
+	if top.label("") != "" {
+		t.Error("empty value was not kept")
+	}
+}
@@ -0,0 +1,77 @@
+package driver
+
+// Middleware wraps a driver with additional behavior.
+type Middleware func(Driver) Driver
+
+// Chain wraps d with the given middlewares. The first middleware is the
+// outermost one, i.e. it sees each operation first and its result last.
+//
+//	drv := driver.Chain(db,
+//		driver.Debug(logger),
+//		driver.Cache(driver.CacheConfig{TTL: time.Minute}),
+//		driver.StmtCache(driver.StmtCacheConfig{}),
+//	)
+func Chain(d Driver, mws ...Middleware) Driver {
+	for i := len(mws) - 1; i >= 0; i-- {
+		d = mws[i](d)
+	}
+	return d
+}
+
+// Debug returns a middleware that wraps drivers with DebugWithContext.
+func Debug(logger LogFunc, opts ...Option) Middleware {
+	return func(d Driver) Driver {
+		return DebugWithContext(d, logger, opts...)
+	}
+}
+
+// Cache returns a middleware that wraps drivers with NewCacheDriver.
+func Cache(cfg CacheConfig) Middleware {
+	return func(d Driver) Driver {
+		return NewCacheDriver(d, cfg)
+	}
+}
+
+// Singleflight returns a middleware that wraps drivers with NewSingleflightDriver.
+func Singleflight(cfg SingleflightConfig) Middleware {
+	return func(d Driver) Driver {
+		return NewSingleflightDriver(d, cfg)
+	}
+}
+
+// Memo returns a middleware that wraps drivers with NewMemoDriver.
+func Memo(cfg MemoConfig) Middleware {
+	return func(d Driver) Driver {
+		return NewMemoDriver(d, cfg)
+	}
+}
+
+// Batch returns a middleware that wraps drivers with NewBatchDriver.
+func Batch(cfg BatchConfig) Middleware {
+	return func(d Driver) Driver {
+		return NewBatchDriver(d, cfg)
+	}
+}
+
+// StmtCache returns a middleware that wraps drivers with NewStmtCacheDriver.
+func StmtCache(cfg StmtCacheConfig) Middleware {
+	return func(d Driver) Driver {
+		return NewStmtCacheDriver(d, cfg)
+	}
+}
+
+// ReadReplicas returns a middleware that uses the wrapped driver as the
+// primary of a ReplicaDriver with the given replicas.
+func ReadReplicas(replicas []Replica, cfg ReplicaConfig) Middleware {
+	return func(d Driver) Driver {
+		return NewReplicaDriver(d, replicas, cfg)
+	}
+}
+
+// Failover returns a middleware that uses the wrapped driver as the
+// primary of a FailoverDriver with the given standby.
+func Failover(standby Driver, cfg FailoverConfig) Middleware {
+	return func(d Driver) Driver {
+		return NewFailoverDriver(d, standby, cfg)
+	}
+}
@@ -0,0 +1,58 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"entgo.io/ent/dialect"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// Migration returns an ExecQuerier logging and hooking the statements of a
+// schema migration executed on conn as "Migrate" operations.
+func (d *DebugDriver) Migration(conn dialect.ExecQuerier) dialect.ExecQuerier {
+	m := &migration{ExecQuerier: conn, drv: d, id: uuid.New().String()}
+	if tx, ok := conn.(*DebugTx); ok {
+		m.ExecQuerier, m.id = tx.Tx, tx.id
+	}
+	return m
+}
+
+// Log writes an entry with the log function of the driver, if the operations
+// of the context are logged. It is used by the packages extending the driver,
+// e.g. migratehook.
+func (d *DebugDriver) Log(ctx context.Context, msg string, fields ...zap.Field) {
+	if d.logs(ctx) {
+		d.log(ctx, msg, fields...)
+	}
+}
+
+// migration executes the statements of a schema migration.
+type migration struct {
+	dialect.ExecQuerier
+	drv  *DebugDriver
+	id   string       // id of the migration transaction.
+	step atomic.Int64 // number of executed statements.
+}
+
+// Exec logs the statement with its step number and duration, and calls the underlying Exec method.
+func (m *migration) Exec(ctx context.Context, query string, args, v any) error {
+	step := m.step.Add(1)
+	m.drv.statements.Add(1)
+	if m.drv.logs(ctx) {
+		m.drv.logStmt(ctx, fmt.Sprintf("Migrate(%s).Exec: step=%d", m.id, step), query, args,
+			zap.Int64("step", step), zap.String("query", query))
+	}
+	start := time.Now()
+	err := m.drv.run(ctx, nil, m.id, "Migrate", query, args, v, func(ctx context.Context) error {
+		return m.ExecQuerier.Exec(ctx, query, args, v)
+	})
+	if err == nil && m.drv.logs(ctx) {
+		m.drv.log(ctx, fmt.Sprintf("Migrate(%s): step %d applied", m.id, step),
+			zap.Int64("step", step), zap.Duration("duration", time.Since(start)))
+	}
+	return err
+}
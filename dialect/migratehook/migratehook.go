@@ -0,0 +1,152 @@
+// Package migratehook provides ent schema migration hooks executing the
+// migration statements through a driver.DebugDriver, and logging a summary
+// of the schema changes.
+package migratehook
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"ariga.io/atlas/sql/migrate"
+	atlas "ariga.io/atlas/sql/schema"
+	"entgo.io/ent/dialect"
+	"entgo.io/ent/dialect/sql/schema"
+	"github.com/floatyun/entzlog/dialect"
+	"go.uber.org/zap"
+)
+
+// Options returns the migration options logging the schema changes and the
+// DDL statements of the migrations through the driver:
+//
+//	err := client.Schema.Create(ctx, migratehook.Options(drv)...)
+func Options(d *driver.DebugDriver) []schema.MigrateOption {
+	var (
+		mu   sync.Mutex
+		last []zap.Field
+	)
+	diff := DiffHook(func(fields []zap.Field) {
+		mu.Lock()
+		defer mu.Unlock()
+		last = fields
+	})
+	apply := func(next schema.Applier) schema.Applier {
+		return schema.ApplyFunc(func(ctx context.Context, conn dialect.ExecQuerier, plan *migrate.Plan) error {
+			mu.Lock()
+			summary := last
+			last = nil
+			mu.Unlock()
+			start := time.Now()
+			d.Log(ctx, "migrate: applying changes", append(summary, zap.Int("steps", len(plan.Changes)))...)
+			err := ApplyHook(d)(next).Apply(ctx, conn, plan)
+			d.Log(ctx, "migrate: changes applied", zap.Int("steps", len(plan.Changes)), zap.Duration("duration", time.Since(start)), zap.Error(err))
+			return err
+		})
+	}
+	return []schema.MigrateOption{schema.WithDiffHook(diff), schema.WithApplyHook(apply)}
+}
+
+// ApplyHook returns a hook applying the migration plans through the driver:
+// the DDL statements are logged with their step number and duration, and
+// passed to the hooks and sinks of the driver. See DebugDriver.Migration.
+func ApplyHook(d *driver.DebugDriver) schema.ApplyHook {
+	return func(next schema.Applier) schema.Applier {
+		return schema.ApplyFunc(func(ctx context.Context, conn dialect.ExecQuerier, plan *migrate.Plan) error {
+			return next.Apply(ctx, d.Migration(conn), plan)
+		})
+	}
+}
+
+// DiffHook returns a hook passing the summary of the computed schema changes
+// to fn, as log fields: the number of tables before and after the migration,
+// and the added, dropped, altered or renamed tables, columns, indexes and
+// foreign keys.
+func DiffHook(fn func(summary []zap.Field)) schema.DiffHook {
+	return func(next schema.Differ) schema.Differ {
+		return schema.DiffFunc(func(current, desired *atlas.Schema) ([]atlas.Change, error) {
+			changes, err := next.Diff(current, desired)
+			if err == nil {
+				fn(summarize(current, desired, changes))
+			}
+			return changes, err
+		})
+	}
+}
+
+// summary groups the names of the changed schema objects by kind of change.
+type summary struct {
+	keys  []string
+	names map[string][]string
+}
+
+func (s *summary) add(key string, names ...string) {
+	if s.names == nil {
+		s.names = make(map[string][]string)
+	}
+	if _, ok := s.names[key]; !ok {
+		s.keys = append(s.keys, key)
+	}
+	s.names[key] = append(s.names[key], names...)
+}
+
+// summarize returns the summary of the changes as log fields.
+func summarize(current, desired *atlas.Schema, changes []atlas.Change) []zap.Field {
+	var s summary
+	for _, c := range changes {
+		switch c := c.(type) {
+		case *atlas.AddTable:
+			s.add("tables_added", c.T.Name)
+			for _, idx := range c.T.Indexes {
+				s.add("indexes_created", c.T.Name+"."+idx.Name)
+			}
+		case *atlas.DropTable:
+			s.add("tables_dropped", c.T.Name)
+		case *atlas.RenameTable:
+			s.add("tables_renamed", c.From.Name+" -> "+c.To.Name)
+		case *atlas.ModifyTable:
+			s.add("tables_altered", c.T.Name)
+			summarizeTable(&s, c.T.Name, c.Changes)
+		}
+	}
+	fields := []zap.Field{zap.Int("tables_before", tableCount(current)), zap.Int("tables_after", tableCount(desired))}
+	for _, k := range s.keys {
+		fields = append(fields, zap.Strings(k, s.names[k]))
+	}
+	return fields
+}
+
+// summarizeTable adds the changes of a modified table to the summary.
+func summarizeTable(s *summary, table string, changes []atlas.Change) {
+	for _, c := range changes {
+		switch c := c.(type) {
+		case *atlas.AddColumn:
+			s.add("columns_added", table+"."+c.C.Name)
+		case *atlas.DropColumn:
+			s.add("columns_dropped", table+"."+c.C.Name)
+		case *atlas.ModifyColumn:
+			s.add("columns_altered", table+"."+c.To.Name)
+		case *atlas.RenameColumn:
+			s.add("columns_renamed", table+"."+c.From.Name+" -> "+c.To.Name)
+		case *atlas.AddIndex:
+			s.add("indexes_created", table+"."+c.I.Name)
+		case *atlas.DropIndex:
+			s.add("indexes_dropped", table+"."+c.I.Name)
+		case *atlas.ModifyIndex:
+			s.add("indexes_altered", table+"."+c.To.Name)
+		case *atlas.RenameIndex:
+			s.add("indexes_renamed", table+"."+c.From.Name+" -> "+c.To.Name)
+		case *atlas.AddForeignKey:
+			s.add("foreign_keys_added", table+"."+c.F.Symbol)
+		case *atlas.DropForeignKey:
+			s.add("foreign_keys_dropped", table+"."+c.F.Symbol)
+		}
+	}
+}
+
+// tableCount returns the number of tables of the schema.
+func tableCount(s *atlas.Schema) int {
+	if s == nil {
+		return 0
+	}
+	return len(s.Tables)
+}
@@ -0,0 +1,85 @@
+// Package natssink provides a driver.Sink publishing the events to NATS.
+package natssink
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/floatyun/entzlog/dialect"
+	"github.com/nats-io/nats.go"
+)
+
+// Config configures the NATS sink.
+type Config struct {
+	// Conn is the NATS connection. Required. It is not closed by the sink.
+	Conn *nats.Conn
+	// Subject is the subject the events are published to. Defaults to "entzlog.events".
+	Subject string
+	// JetStream enables publishing through JetStream, in which case a
+	// stream must be configured for the subject.
+	JetStream bool
+	// Filter selects the published events. Defaults to the statements,
+	// i.e. transaction events are skipped.
+	Filter func(*driver.Event) bool
+	// FlushTimeout bounds the time Close waits for the pending messages. Defaults to 5s.
+	FlushTimeout time.Duration
+}
+
+// Sink publishes the events as JSON messages. Core NATS messages are
+// buffered by the connection, and JetStream messages are published
+// asynchronously.
+type Sink struct {
+	cfg Config
+	js  nats.JetStreamContext
+}
+
+// New returns a new NATS sink. Use it with driver.WithSink.
+func New(cfg Config) (*Sink, error) {
+	if cfg.Subject == "" {
+		cfg.Subject = "entzlog.events"
+	}
+	if cfg.Filter == nil {
+		cfg.Filter = func(e *driver.Event) bool { return e.Query != "" }
+	}
+	if cfg.FlushTimeout <= 0 {
+		cfg.FlushTimeout = 5 * time.Second
+	}
+	s := &Sink{cfg: cfg}
+	if cfg.JetStream {
+		js, err := cfg.Conn.JetStream()
+		if err != nil {
+			return nil, err
+		}
+		s.js = js
+	}
+	return s, nil
+}
+
+// Write publishes the event.
+func (s *Sink) Write(_ context.Context, e *driver.Event) error {
+	if !s.cfg.Filter(e) {
+		return nil
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if s.js != nil {
+		_, err = s.js.PublishAsync(s.cfg.Subject, data)
+		return err
+	}
+	return s.cfg.Conn.Publish(s.cfg.Subject, data)
+}
+
+// Close waits for the pending messages to be published.
+func (s *Sink) Close() error {
+	if s.js != nil {
+		select {
+		case <-s.js.PublishAsyncComplete():
+		case <-time.After(s.cfg.FlushTimeout):
+		}
+		return nil
+	}
+	return s.cfg.Conn.FlushTimeout(s.cfg.FlushTimeout)
+}
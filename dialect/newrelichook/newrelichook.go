@@ -0,0 +1,100 @@
+// Package newrelichook provides a driver.Hook recording the statements as
+// New Relic datastore segments.
+package newrelichook
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"entgo.io/ent/dialect"
+	"github.com/floatyun/entzlog/dialect"
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+// Config configures the New Relic hook.
+type Config struct {
+	// Dialect is the dialect of the hooked driver, e.g. dialect.Postgres,
+	// used to set the datastore product of the segments.
+	Dialect string
+	// Host, PortPathOrID and DatabaseName identify the database instance
+	// of the segments. Optional.
+	Host         string
+	PortPathOrID string
+	DatabaseName string
+	// Attributes, if set, is called before the segment of each operation
+	// ends, with the context, query and error of the operation, to add
+	// attributes to the segment or modify its fields, e.g. to add the
+	// tenant of the context.
+	Attributes func(ctx context.Context, s *newrelic.DatastoreSegment, op, query string, err error)
+}
+
+// Hook records the operations of the driver executed with a New Relic
+// transaction in their context as datastore segments of this transaction.
+type Hook struct {
+	cfg     Config
+	product newrelic.DatastoreProduct
+}
+
+// New returns a new New Relic hook. Use it with driver.WithHooks, or use
+// Option.
+func New(cfg Config) *Hook {
+	return &Hook{cfg: cfg, product: product(cfg.Dialect)}
+}
+
+// Option returns a driver option recording the operations of the driver
+// as New Relic datastore segments.
+func Option(cfg Config) driver.Option {
+	return driver.WithHooks(New(cfg))
+}
+
+// segmentKey is the context key of the segment of an operation.
+type segmentKey struct{}
+
+// Before starts the segment of the operation, if the context carries a
+// New Relic transaction.
+func (h *Hook) Before(ctx context.Context, op, query string, _ []any) context.Context {
+	txn := newrelic.FromContext(ctx)
+	if txn == nil {
+		return ctx
+	}
+	s := &newrelic.DatastoreSegment{
+		StartTime:    txn.StartSegmentNow(),
+		Product:      h.product,
+		Operation:    op,
+		Host:         h.cfg.Host,
+		PortPathOrID: h.cfg.PortPathOrID,
+		DatabaseName: h.cfg.DatabaseName,
+	}
+	if query != "" {
+		s.Collection = driver.StatementTable(query)
+		s.Operation = strings.ToLower(driver.StatementType(query))
+		s.ParameterizedQuery = driver.SanitizeQuery(query)
+	}
+	return context.WithValue(ctx, segmentKey{}, s)
+}
+
+// After ends the segment of the operation, if it was started.
+func (h *Hook) After(ctx context.Context, op, query string, _ []any, err error, _ time.Duration) {
+	s, ok := ctx.Value(segmentKey{}).(*newrelic.DatastoreSegment)
+	if !ok {
+		return
+	}
+	if h.cfg.Attributes != nil {
+		h.cfg.Attributes(ctx, s, op, query, err)
+	}
+	s.End()
+}
+
+// product returns the datastore product of the dialect.
+func product(name string) newrelic.DatastoreProduct {
+	switch name {
+	case dialect.Postgres:
+		return newrelic.DatastorePostgres
+	case dialect.MySQL:
+		return newrelic.DatastoreMySQL
+	case dialect.SQLite:
+		return newrelic.DatastoreSQLite
+	}
+	return newrelic.DatastoreProduct(name)
+}
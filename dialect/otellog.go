@@ -0,0 +1,96 @@
+package driver
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/log/global"
+)
+
+// OTelLogConfig configures an OTelLogSink.
+type OTelLogConfig struct {
+	// Logger emits the log records. Defaults to the logger of the global
+	// LoggerProvider named after this module.
+	Logger log.Logger
+	// Filter selects the emitted events. Defaults to all events.
+	Filter func(*Event) bool
+}
+
+// OTelLogSink is a Sink that emits the events through the OpenTelemetry log
+// bridge API, with the context of their operations, like the OTLPSink.
+type OTelLogSink struct {
+	cfg OTelLogConfig
+}
+
+// NewOTelLogSink returns a new OTelLogSink.
+func NewOTelLogSink(cfg OTelLogConfig) *OTelLogSink {
+	if cfg.Logger == nil {
+		cfg.Logger = global.GetLoggerProvider().Logger("github.com/floatyun/entzlog")
+	}
+	if cfg.Filter == nil {
+		cfg.Filter = func(*Event) bool { return true }
+	}
+	return &OTelLogSink{cfg: cfg}
+}
+
+// Write emits the event if it is selected by the filter.
+func (s *OTelLogSink) Write(ctx context.Context, e *Event) error {
+	if !s.cfg.Filter(e) {
+		return nil
+	}
+	s.cfg.Logger.Emit(ctx, otelLogRecord(e))
+	return nil
+}
+
+// Close implements the Sink interface. The records are flushed by the
+// LoggerProvider of the logger.
+func (*OTelLogSink) Close() error { return nil }
+
+// otelLogRecord returns the log record of the event.
+func otelLogRecord(e *Event) log.Record {
+	var r log.Record
+	r.SetTimestamp(e.Time)
+	r.SetSeverity(log.SeverityInfo)
+	r.SetSeverityText("INFO")
+	switch {
+	case e.Err != nil || e.Op == OpDegraded:
+		r.SetSeverity(log.SeverityError)
+		r.SetSeverityText("ERROR")
+	case e.Slow:
+		r.SetSeverity(log.SeverityWarn)
+		r.SetSeverityText("WARN")
+	}
+	body := e.Op
+	if e.Query != "" {
+		body = e.Query
+	}
+	r.SetBody(log.StringValue(body))
+	r.AddAttributes(
+		log.String("db.system", e.Dialect),
+		log.String("db.operation", e.Op),
+		log.String("entzlog.status", e.Status()),
+		log.Float64("entzlog.duration_ms", float64(e.Duration)/float64(time.Millisecond)),
+	)
+	for _, kv := range [][2]string{
+		{"db.statement", e.Query},
+		{"entzlog.fingerprint", e.Fingerprint()},
+		{"entzlog.tx_id", e.TxID},
+		{"entzlog.tenant", e.Tenant},
+		{"error.type", string(e.ErrorClass)},
+	} {
+		if kv[1] != "" {
+			r.AddAttributes(log.String(kv[0], kv[1]))
+		}
+	}
+	if e.Err != nil {
+		r.AddAttributes(log.String("exception.message", e.Err.Error()))
+	}
+	if e.Rows >= 0 {
+		r.AddAttributes(log.Int64("entzlog.rows", e.Rows))
+	}
+	for _, a := range e.attrs() {
+		r.AddAttributes(log.String("entzlog."+a.Key, a.Value))
+	}
+	return r
+}
@@ -0,0 +1,183 @@
+package driver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// OTLPConfig configures an OTLPSink.
+type OTLPConfig struct {
+	// Endpoint is the OTLP/HTTP logs endpoint. Defaults to "http://localhost:4318/v1/logs".
+	Endpoint string
+	// Client is the HTTP client used to export the events. Defaults to a
+	// client with a 10s timeout.
+	Client *http.Client
+	// Header holds additional request headers, e.g. for authentication.
+	Header http.Header
+	// Resource holds the resource attributes of the exported logs,
+	// e.g. {"service.name": "api"}.
+	Resource map[string]string
+	// Filter selects the exported events. Defaults to all events.
+	Filter func(*Event) bool
+	// Interval is the time between two exports. Defaults to 1s.
+	Interval time.Duration
+	// BatchSize is the maximum number of events of an export. Defaults to 500.
+	BatchSize int
+	// MaxPending is the maximum number of events waiting to be exported.
+	// Events exceeding it are dropped. Defaults to 10000.
+	MaxPending int
+}
+
+// OTLPSink is a Sink that exports the events as OpenTelemetry log records,
+// using the OTLP/HTTP protocol with JSON encoding. The records follow the
+// database semantic conventions (db.system, db.statement, ...), and carry
+// the entzlog-specific fields as entzlog.* attributes.
+type OTLPSink struct {
+	cfg OTLPConfig
+	b   *batcher
+}
+
+// NewOTLPSink returns a new OTLPSink and starts its exporter.
+func NewOTLPSink(cfg OTLPConfig) *OTLPSink {
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = "http://localhost:4318/v1/logs"
+	}
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	if cfg.Filter == nil {
+		cfg.Filter = func(*Event) bool { return true }
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Second
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 500
+	}
+	if cfg.MaxPending <= 0 {
+		cfg.MaxPending = 10000
+	}
+	s := &OTLPSink{cfg: cfg}
+	s.b = newBatcher(cfg.Interval, cfg.BatchSize, cfg.MaxPending, s.export)
+	return s
+}
+
+// Write queues the event if it is selected by the filter.
+func (s *OTLPSink) Write(_ context.Context, e *Event) error {
+	if !s.cfg.Filter(e) {
+		return nil
+	}
+	return s.b.add(e)
+}
+
+// Dropped returns the number of events dropped because too many were pending.
+func (s *OTLPSink) Dropped() int64 {
+	return s.b.dropped.Load()
+}
+
+// Close stops the exporter and exports the pending events.
+func (s *OTLPSink) Close() error {
+	return s.b.close()
+}
+
+// OTLP JSON encoding of the logs data model.
+type (
+	otlpValue struct {
+		StringValue *string  `json:"stringValue,omitempty"`
+		IntValue    *string  `json:"intValue,omitempty"`
+		DoubleValue *float64 `json:"doubleValue,omitempty"`
+	}
+	otlpKeyValue struct {
+		Key   string    `json:"key"`
+		Value otlpValue `json:"value"`
+	}
+	otlpRecord struct {
+		TimeUnixNano   string         `json:"timeUnixNano"`
+		SeverityNumber int            `json:"severityNumber"`
+		SeverityText   string         `json:"severityText"`
+		Body           otlpValue      `json:"body"`
+		Attributes     []otlpKeyValue `json:"attributes"`
+	}
+)
+
+// otlpString returns a string attribute.
+func otlpString(k, v string) otlpKeyValue {
+	return otlpKeyValue{Key: k, Value: otlpValue{StringValue: &v}}
+}
+
+// export sends the events in a single export request.
+func (s *OTLPSink) export(events []*Event) error {
+	resource := make([]otlpKeyValue, 0, len(s.cfg.Resource))
+	for k, v := range s.cfg.Resource {
+		resource = append(resource, otlpString(k, v))
+	}
+	records := make([]otlpRecord, len(events))
+	for i, e := range events {
+		records[i] = otlpLogRecord(e)
+	}
+	body, err := json.Marshal(map[string]any{
+		"resourceLogs": []any{map[string]any{
+			"resource": map[string]any{"attributes": resource},
+			"scopeLogs": []any{map[string]any{
+				"scope":      map[string]any{"name": "github.com/floatyun/entzlog"},
+				"logRecords": records,
+			}},
+		}},
+	})
+	if err != nil {
+		return err
+	}
+	return post(s.cfg.Client, s.cfg.Endpoint, s.cfg.Header, body, len(events))
+}
+
+// otlpLogRecord returns the log record of the event.
+func otlpLogRecord(e *Event) otlpRecord {
+	r := otlpRecord{
+		TimeUnixNano:   strconv.FormatInt(e.Time.UnixNano(), 10),
+		SeverityNumber: 9,
+		SeverityText:   "INFO",
+	}
+	switch {
+	case e.Err != nil || e.Op == OpDegraded:
+		r.SeverityNumber, r.SeverityText = 17, "ERROR"
+	case e.Slow:
+		r.SeverityNumber, r.SeverityText = 13, "WARN"
+	}
+	body := e.Op
+	if e.Query != "" {
+		body = e.Query
+	}
+	r.Body = otlpValue{StringValue: &body}
+	ms := float64(e.Duration) / float64(time.Millisecond)
+	r.Attributes = []otlpKeyValue{
+		otlpString("db.system", e.Dialect),
+		otlpString("db.operation", e.Op),
+		otlpString("entzlog.status", e.Status()),
+		{Key: "entzlog.duration_ms", Value: otlpValue{DoubleValue: &ms}},
+	}
+	for _, kv := range [][2]string{
+		{"db.statement", e.Query},
+		{"entzlog.fingerprint", e.Fingerprint()},
+		{"entzlog.tx_id", e.TxID},
+		{"entzlog.tenant", e.Tenant},
+		{"error.type", string(e.ErrorClass)},
+	} {
+		if kv[1] != "" {
+			r.Attributes = append(r.Attributes, otlpString(kv[0], kv[1]))
+		}
+	}
+	if e.Err != nil {
+		r.Attributes = append(r.Attributes, otlpString("exception.message", e.Err.Error()))
+	}
+	if e.Rows >= 0 {
+		rows := strconv.FormatInt(e.Rows, 10)
+		r.Attributes = append(r.Attributes, otlpKeyValue{Key: "entzlog.rows", Value: otlpValue{IntValue: &rows}})
+	}
+	for _, a := range e.attrs() {
+		r.Attributes = append(r.Attributes, otlpString("entzlog."+a.Key, a.Value))
+	}
+	return r
+}
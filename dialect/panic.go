@@ -0,0 +1,47 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+
+	"go.uber.org/zap"
+)
+
+// ErrPanic is the error the statements that panicked fail with, when
+// the panics are converted to errors.
+var ErrPanic = errors.New("entzlog: panic")
+
+// WithPanicRecovery returns an option that logs the panics of the underlying
+// driver and of the scan targets, and raises them again, or returns those of
+// the calls as errors wrapping ErrPanic if toError is set.
+func WithPanicRecovery(toError bool) Option {
+	return func(d *DebugDriver) {
+		d.recoverPanics = true
+		d.panicToError = toError
+	}
+}
+
+// recoverPanic recovers a panic of the statement, logs it, and sets it as
+// the error of the statement, so that it completes like failures do before
+// the panic is raised again, if it is. It must be deferred.
+func (d *DebugDriver) recoverPanic(ctx context.Context, txID, op, query string, panicked *any, err *error) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	d.logPanic(ctx, txID, op, query, r)
+	*panicked = r
+	*err = fmt.Errorf("%w: %v", ErrPanic, r)
+}
+
+// logPanic logs the panic of the statement with the stack of the goroutine.
+func (d *DebugDriver) logPanic(ctx context.Context, txID, op, query string, r any) {
+	d.log(ctx, d.opMsg(txID, op)+": panic", ctxFields(ctx, []zap.Field{
+		zap.Any("panic", r),
+		zap.ByteString("stack", debug.Stack()),
+		zap.String("query", query),
+		zap.String("tx_id", txID),
+	})...)
+}
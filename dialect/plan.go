@@ -0,0 +1,186 @@
+package driver
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"sync"
+	"time"
+
+	"entgo.io/ent/dialect"
+	entsql "entgo.io/ent/dialect/sql"
+	"go.uber.org/zap"
+)
+
+// PlanConfig configures WithPlanRegressions.
+type PlanConfig struct {
+	// Interval is the minimum time between two explanations of the statements
+	// of a fingerprint. Defaults to 10 minutes.
+	Interval time.Duration
+	// MaxFingerprints is the maximum number of fingerprints whose plans are
+	// tracked. The statements of the other fingerprints are not explained.
+	// Defaults to 1000.
+	MaxFingerprints int
+}
+
+// WithPlanRegressions returns an option that explains the statements once per
+// interval and fingerprint in the background, and logs the plans that changed.
+func WithPlanRegressions(cfg PlanConfig) Option {
+	return func(d *DebugDriver) {
+		if cfg.Interval <= 0 {
+			cfg.Interval = 10 * time.Minute
+		}
+		if cfg.MaxFingerprints <= 0 {
+			cfg.MaxFingerprints = 1000
+		}
+		d.plans = &planTracker{cfg: cfg, plans: make(map[string]*plan)}
+	}
+}
+
+// planTracker holds the last plans of the fingerprints.
+type planTracker struct {
+	cfg   PlanConfig
+	mu    sync.Mutex
+	plans map[string]*plan // by fingerprint.
+}
+
+// plan is the last plan of a fingerprint.
+type plan struct {
+	hash      string
+	text      string
+	explained time.Time // time of the last explanation.
+	running   bool      // whether the fingerprint is being explained.
+}
+
+// due reports whether the statements of the fingerprint must be explained,
+// and marks them as being explained if so.
+func (t *planTracker) due(fingerprint string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p, ok := t.plans[fingerprint]
+	switch {
+	case !ok && len(t.plans) >= t.cfg.MaxFingerprints:
+		return false
+	case !ok:
+		p = &plan{}
+		t.plans[fingerprint] = p
+	case p.running || time.Since(p.explained) < t.cfg.Interval:
+		return false
+	}
+	p.running = true
+	return true
+}
+
+// update stores the plan of the fingerprint, and returns the previous one
+// if it changed. An empty text leaves the previous plan in place.
+func (t *planTracker) update(fingerprint, text string) (string, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p := t.plans[fingerprint]
+	p.running = false
+	p.explained = time.Now()
+	if text == "" {
+		return "", false
+	}
+	h := fnv.New64a()
+	h.Write([]byte(text))
+	hash := fmt.Sprintf("%016x", h.Sum64())
+	prev, changed := p.text, p.hash != "" && p.hash != hash
+	p.hash, p.text = hash, text
+	return prev, changed
+}
+
+// explain returns the statement explaining the query in the dialect of the
+// driver, or false if it is not supported.
+func (d *DebugDriver) explain(query string) (string, bool) {
+	switch StatementType(query) {
+	case StmtSelect, StmtUpdate, StmtDelete:
+	default:
+		return "", false
+	}
+	switch d.Dialect() {
+	case dialect.Postgres:
+		return "EXPLAIN (COSTS OFF) " + query, true
+	case dialect.MySQL:
+		return "EXPLAIN " + query, true
+	case dialect.SQLite:
+		return "EXPLAIN QUERY PLAN " + query, true
+	}
+	return "", false
+}
+
+// checkPlan explains the statement in the background if its fingerprint is
+// due, and logs its plan if it changed.
+func (d *DebugDriver) checkPlan(ctx context.Context, txID, op, query string, args any) {
+	if d.plans == nil {
+		return
+	}
+	explain, ok := d.explain(query)
+	if !ok {
+		return
+	}
+	fingerprint := Fingerprint(query)
+	if !d.plans.due(fingerprint) {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), lockDiagnosticsTimeout)
+		defer cancel()
+		text, err := planText(ctx, d.Driver, explain, args)
+		if err != nil && d.logs(ctx) {
+			d.log(ctx, d.opMsg(txID, op)+": explain failed", zap.String("query", query), zap.Error(err))
+		}
+		prev, changed := d.plans.update(fingerprint, text)
+		if !changed {
+			return
+		}
+		d.metrics.Count(ctx, "entzlog_plan_changes_total", 1, d.metricLabels()...)
+		d.log(ctx, d.opMsg(txID, op)+": plan changed", ctxFields(ctx, []zap.Field{
+			zap.String("fingerprint", fingerprint),
+			zap.String("query", query),
+			zap.String("previous_plan", prev),
+			zap.String("plan", text),
+		})...)
+	}()
+}
+
+// planText executes the explain statement and returns its rows, one per
+// line, without the values changing along the same plan.
+func planText(ctx context.Context, ex dialect.ExecQuerier, explain string, args any) (string, error) {
+	var rows entsql.Rows
+	if err := ex.Query(ctx, explain, args, &rows); err != nil {
+		return "", err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	for rows.Next() {
+		values := make([]sql.NullString, len(columns))
+		dest := make([]any, len(columns))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return "", err
+		}
+		var line []string
+		for i, c := range columns {
+			switch c {
+			case "rows", "filtered", "id", "parent", "notused":
+				continue
+			}
+			line = append(line, values[i].String)
+		}
+		b.WriteString(strings.Join(line, " "))
+		b.WriteByte('\n')
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(b.String(), "\n"), nil
+}
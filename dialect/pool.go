@@ -0,0 +1,114 @@
+package driver
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"entgo.io/ent/dialect"
+	entsql "entgo.io/ent/dialect/sql"
+	"go.uber.org/zap"
+)
+
+// WithPoolWait returns an option that logs and measures the time the Exec and
+// Query calls of the driver wait for a connection of the pool.
+func WithPoolWait() Option {
+	return func(d *DebugDriver) {
+		d.poolWait = true
+	}
+}
+
+// WithPoolExhaustionWarning returns an option that logs a warning, at most
+// once per interval, when a statement waited threshold for its connection or
+// the pool is exhausted. It implies WithPoolWait.
+func WithPoolExhaustionWarning(threshold, interval time.Duration) Option {
+	return func(d *DebugDriver) {
+		d.poolWait = true
+		d.poolWarnings = true
+		d.poolWarn = threshold
+		d.poolWarnEvery = interval
+	}
+}
+
+// acquire returns the executor of a statement of the driver: a connection
+// acquired from the pool whose wait is logged and recorded, along with the
+// function releasing it, or the underlying driver if the waits are not
+// measured.
+func (d *DebugDriver) acquire(ctx context.Context, op, query string) (dialect.ExecQuerier, func(), error) {
+	if !d.poolWait {
+		return d.Driver, func() {}, nil
+	}
+	db := d.DB()
+	if db == nil {
+		return d.Driver, func() {}, nil
+	}
+	start := time.Now()
+	c, err := db.Conn(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	wait := time.Since(start)
+	d.metrics.Observe(ctx, "entzlog_pool_wait_seconds", wait, d.metricLabels()...)
+	if d.logs(ctx) {
+		d.log(ctx, d.opMsg("", op)+": connection acquired", ctxFields(ctx, []zap.Field{
+			zap.Duration("pool_wait", wait),
+			zap.String("query", query),
+		})...)
+	}
+	if d.poolWarnings {
+		d.warnPool(ctx, db, op, query, wait)
+	}
+	return entsql.Conn{ExecQuerier: c}, func() { c.Close() }, nil
+}
+
+// warnPool logs a warning if the statement waited too long for its
+// connection or if the pool is exhausted, unless a warning was logged
+// less than an interval ago.
+func (d *DebugDriver) warnPool(ctx context.Context, db *sql.DB, op, query string, wait time.Duration) {
+	stats := db.Stats()
+	exhausted := stats.MaxOpenConnections > 0 && stats.OpenConnections >= stats.MaxOpenConnections
+	if (d.poolWarn <= 0 || wait < d.poolWarn) && !exhausted {
+		return
+	}
+	now := time.Now().UnixNano()
+	last := d.poolWarned.Load()
+	if last != 0 && now-last < int64(d.poolWarnEvery) || !d.poolWarned.CompareAndSwap(last, now) {
+		return
+	}
+	d.log(ctx, d.opMsg("", op)+": connection pool exhausted", ctxFields(ctx, []zap.Field{
+		zap.Duration("pool_wait", wait),
+		zap.String("query", query),
+		zap.Int("max_open_connections", stats.MaxOpenConnections),
+		zap.Int("open_connections", stats.OpenConnections),
+		zap.Int("in_use", stats.InUse),
+		zap.Int("idle", stats.Idle),
+		zap.Int64("wait_count", stats.WaitCount),
+		zap.Duration("wait_duration", stats.WaitDuration),
+	})...)
+}
+
+// releaseRows hands the release of the rows returned by a successful Query,
+// e.g. of their connection, over to them, to be called once they are closed,
+// and replaces release with a no-op. Otherwise, it is left to the caller.
+func releaseRows(v any, release *func(), err error) error {
+	rows, ok := v.(*entsql.Rows)
+	if err != nil || !ok || rows.ColumnScanner == nil {
+		return err
+	}
+	rows.ColumnScanner = &releasedRows{ColumnScanner: rows.ColumnScanner, release: *release}
+	*release = func() {}
+	return nil
+}
+
+// releasedRows releases the connection of the rows once they are closed.
+type releasedRows struct {
+	entsql.ColumnScanner
+	release func()
+}
+
+// Close closes the rows and releases their connection.
+func (r *releasedRows) Close() error {
+	err := r.ColumnScanner.Close()
+	r.release()
+	return err
+}
@@ -0,0 +1,31 @@
+package driver
+
+import (
+	"context"
+	"runtime/pprof"
+)
+
+// WithProfilerLabels returns an option that executes the statements with the
+// pprof labels of their fingerprint, table, statement type and op.
+func WithProfilerLabels() Option {
+	return func(d *DebugDriver) {
+		d.pprofLabels = true
+	}
+}
+
+// labeled executes fn with the pprof labels of the statement, if enabled.
+func (d *DebugDriver) labeled(ctx context.Context, op, query string, fn func(context.Context) error) (err error) {
+	if !d.pprofLabels || query == "" {
+		return fn(ctx)
+	}
+	labels := pprof.Labels(
+		"entzlog_fingerprint", Fingerprint(query),
+		"entzlog_table", StatementTable(query),
+		"entzlog_stmt_type", StatementType(query),
+		"entzlog_op", op,
+	)
+	pprof.Do(ctx, labels, func(ctx context.Context) {
+		err = fn(ctx)
+	})
+	return err
+}
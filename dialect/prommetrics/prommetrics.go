@@ -0,0 +1,190 @@
+// Package prommetrics provides a driver.Metrics implementation
+// backed by the Prometheus client library.
+package prommetrics
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/floatyun/entzlog/dialect"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics is a driver.Metrics registering its collectors on first use. The
+// histograms carry the trace id of the operations as exemplars.
+type Metrics struct {
+	reg     prometheus.Registerer
+	buckets []float64
+	byName  map[string][]float64 // buckets of the histograms configured by name.
+	unit    time.Duration
+	traceID func(context.Context) (string, bool)
+	mu      sync.Mutex
+	vecs    map[string]any // *prometheus.CounterVec, *prometheus.GaugeVec or *prometheus.HistogramVec.
+}
+
+// Bucket presets, in seconds.
+var (
+	// OLTPBuckets suit the statements of transactional APIs, from 100µs to 1s.
+	OLTPBuckets = []float64{.0001, .00025, .0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1}
+	// BatchBuckets suit the statements of batch and ETL jobs, from 100ms to 10m.
+	BatchBuckets = []float64{.1, .25, .5, 1, 2.5, 5, 10, 30, 60, 120, 300, 600}
+)
+
+// New returns a new Metrics registering its collectors on reg, or on the
+// default registerer if reg is nil. The histograms use the given buckets
+// (in seconds), or prometheus.DefBuckets if none are given, e.g.
+//
+//	prommetrics.New(nil, prommetrics.OLTPBuckets...)
+func New(reg prometheus.Registerer, buckets ...float64) *Metrics {
+	if reg == nil {
+		reg = prometheus.DefaultRegisterer
+	}
+	if len(buckets) == 0 {
+		buckets = prometheus.DefBuckets
+	}
+	return &Metrics{
+		reg:     reg,
+		buckets: buckets,
+		byName:  make(map[string][]float64),
+		unit:    time.Second,
+		traceID: driver.TraceIDFromContext,
+		vecs:    make(map[string]any),
+	}
+}
+
+// Buckets sets the buckets of the histogram with the given name, e.g.
+// "entzlog_operation_duration_seconds", in the unit of the histograms,
+// and returns m. The other histograms use the buckets given to New. It
+// must be called before the histogram is first used.
+func (m *Metrics) Buckets(name string, buckets ...float64) *Metrics {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.byName[name] = buckets
+	return m
+}
+
+// Unit sets the unit of the observations of the histograms, and of their
+// names and buckets, and returns m. Defaults to time.Second.
+func (m *Metrics) Unit(unit time.Duration) *Metrics {
+	m.unit = unit
+	return m
+}
+
+// ExemplarsFrom sets the function returning the trace id of the exemplars
+// from the context of the observations, e.g. to use the trace id of the
+// OpenTelemetry span of the context, and returns m. Defaults to
+// driver.TraceIDFromContext, and a nil function disables the exemplars.
+func (m *Metrics) ExemplarsFrom(traceID func(context.Context) (string, bool)) *Metrics {
+	m.traceID = traceID
+	return m
+}
+
+// Count adds delta to the counter identified by name and labels.
+func (m *Metrics) Count(_ context.Context, name string, delta float64, labels ...driver.Label) {
+	names, values := split(labels)
+	vec := m.vec(name, names, func() prometheus.Collector {
+		return prometheus.NewCounterVec(prometheus.CounterOpts{Name: name, Help: help(name)}, names)
+	})
+	if c, ok := vec.(*prometheus.CounterVec); ok {
+		c.WithLabelValues(values...).Add(delta)
+	}
+}
+
+// Gauge sets the gauge identified by name and labels to v.
+func (m *Metrics) Gauge(_ context.Context, name string, v float64, labels ...driver.Label) {
+	names, values := split(labels)
+	vec := m.vec(name, names, func() prometheus.Collector {
+		return prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: name, Help: help(name)}, names)
+	})
+	if g, ok := vec.(*prometheus.GaugeVec); ok {
+		g.WithLabelValues(values...).Set(v)
+	}
+}
+
+// Observe records d, in the unit of the histograms, in the histogram
+// identified by name and labels, with the trace id of the context as
+// exemplar, if any.
+func (m *Metrics) Observe(ctx context.Context, name string, d time.Duration, labels ...driver.Label) {
+	names, values := split(labels)
+	vec := m.vec(name, names, func() prometheus.Collector {
+		buckets, ok := m.byName[name]
+		if !ok {
+			buckets = m.buckets
+		}
+		return prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: m.unitName(name), Help: help(name), Buckets: buckets}, names)
+	})
+	h, ok := vec.(*prometheus.HistogramVec)
+	if !ok {
+		return
+	}
+	o := h.WithLabelValues(values...)
+	if m.traceID != nil {
+		if id, ok := m.traceID(ctx); ok && validExemplar(id) {
+			o.(prometheus.ExemplarObserver).ObserveWithExemplar(float64(d)/float64(m.unit), prometheus.Labels{"trace_id": id})
+			return
+		}
+	}
+	o.Observe(float64(d) / float64(m.unit))
+}
+
+// validExemplar reports whether the trace id is a valid exemplar label,
+// which would otherwise make ObserveWithExemplar panic.
+func validExemplar(id string) bool {
+	return utf8.ValidString(id) && utf8.RuneCountInString("trace_id")+utf8.RuneCountInString(id) <= prometheus.ExemplarMaxRunes
+}
+
+// unitName returns the name of the histogram in the unit of the histograms.
+func (m *Metrics) unitName(name string) string {
+	switch m.unit {
+	case time.Second:
+		return name
+	case time.Millisecond:
+		return strings.TrimSuffix(name, "_seconds") + "_milliseconds"
+	case time.Microsecond:
+		return strings.TrimSuffix(name, "_seconds") + "_microseconds"
+	case time.Nanosecond:
+		return strings.TrimSuffix(name, "_seconds") + "_nanoseconds"
+	}
+	return name
+}
+
+// vec returns the collector of the metric, creating and registering it if needed.
+// Measurements with label names different from the registered ones are ignored.
+func (m *Metrics) vec(name string, names []string, create func() prometheus.Collector) any {
+	key := name + "{" + strings.Join(names, ",") + "}"
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if v, ok := m.vecs[key]; ok {
+		return v
+	}
+	c := create()
+	if err := m.reg.Register(c); err != nil {
+		are, ok := err.(prometheus.AlreadyRegisteredError)
+		if !ok {
+			m.vecs[key] = nil
+			return nil
+		}
+		c = are.ExistingCollector
+	}
+	m.vecs[key] = c
+	return c
+}
+
+// split returns the names and values of the labels, sorted by name.
+func split(labels []driver.Label) (names, values []string) {
+	sort.SliceStable(labels, func(i, j int) bool { return labels[i].Name < labels[j].Name })
+	for _, l := range labels {
+		names = append(names, l.Name)
+		values = append(values, l.Value)
+	}
+	return names, values
+}
+
+// help returns the help text of the metric.
+func help(name string) string {
+	return "entzlog metric " + name + "."
+}
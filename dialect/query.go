@@ -0,0 +1,202 @@
+package driver
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strings"
+)
+
+// isReadOnly reports whether the query is a plain read that can be served
+// by a read replica. Locking reads are routed to the primary.
+func isReadOnly(query string) bool {
+	if !isPlainRead(query) {
+		return false
+	}
+	toks := lex(query)
+	for i := 0; i+1 < len(toks); i++ {
+		switch next := strings.ToUpper(toks[i+1].text); strings.ToUpper(toks[i].text) {
+		case "FOR":
+			// FOR UPDATE, FOR SHARE, FOR NO KEY UPDATE and FOR KEY SHARE.
+			if next == "UPDATE" || next == "SHARE" || next == "NO" || next == "KEY" {
+				return false
+			}
+		case "LOCK":
+			// LOCK IN SHARE MODE.
+			if next == "IN" {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Statement types returned by StatementType.
+const (
+	StmtSelect = "SELECT"
+	StmtInsert = "INSERT"
+	StmtUpdate = "UPDATE"
+	StmtDelete = "DELETE"
+	StmtDDL    = "DDL"
+	StmtOther  = "OTHER"
+)
+
+// StatementType classifies the query by its leading verb. The statement of
+// common table expressions (WITH ... SELECT) is classified by its main verb,
+// and REPLACE statements are classified as inserts. It returns an empty
+// string for an empty query.
+func StatementType(query string) string {
+	verb := firstWord(trimComments(query))
+	switch {
+	case verb == "":
+		return ""
+	case strings.EqualFold(verb, "WITH"):
+		return withStatementType(query)
+	case strings.EqualFold(verb, "SELECT"), strings.EqualFold(verb, "VALUES"):
+		return StmtSelect
+	case strings.EqualFold(verb, "INSERT"), strings.EqualFold(verb, "REPLACE"):
+		return StmtInsert
+	case strings.EqualFold(verb, "UPDATE"):
+		return StmtUpdate
+	case strings.EqualFold(verb, "DELETE"):
+		return StmtDelete
+	}
+	for _, ddl := range []string{"CREATE", "ALTER", "DROP", "TRUNCATE", "RENAME", "COMMENT"} {
+		if strings.EqualFold(verb, ddl) {
+			return StmtDDL
+		}
+	}
+	return StmtOther
+}
+
+// isWrite reports whether the statement type is a data modification.
+func isWrite(typ string) bool {
+	return typ == StmtInsert || typ == StmtUpdate || typ == StmtDelete
+}
+
+// isPlainRead reports whether the query only reads data: a SELECT statement,
+// possibly with common table expressions, without data modifications at any
+// depth, e.g. WITH d AS (DELETE ... RETURNING *) SELECT ..., nor SELECT ...
+// INTO. Locking reads (FOR UPDATE, FOR NO KEY UPDATE) are plain reads.
+func isPlainRead(query string) bool {
+	if StatementType(query) != StmtSelect {
+		return false
+	}
+	toks := lex(query)
+	for i, t := range toks {
+		if t.kind != tokIdent {
+			continue
+		}
+		switch strings.ToUpper(t.text) {
+		case "INSERT", "DELETE", "MERGE", "INTO":
+			return false
+		case "UPDATE":
+			if i == 0 || !strings.EqualFold(toks[i-1].text, "FOR") && !strings.EqualFold(toks[i-1].text, "KEY") {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// withStatementType classifies a statement with common table expressions
+// by the first verb outside of their parentheses.
+func withStatementType(query string) string {
+	depth := 0
+	for _, t := range lex(query) {
+		switch {
+		case t.text == "(":
+			depth++
+		case t.text == ")":
+			depth--
+		case depth == 0 && t.kind == tokIdent:
+			switch verb := strings.ToUpper(t.text); verb {
+			case "SELECT", "INSERT", "UPDATE", "DELETE":
+				return verb
+			}
+		}
+	}
+	return StmtOther
+}
+
+// firstWord returns the leading letters of s.
+func firstWord(s string) string {
+	i := 0
+	for i < len(s) && ('a' <= s[i] && s[i] <= 'z' || 'A' <= s[i] && s[i] <= 'Z') {
+		i++
+	}
+	return s[:i]
+}
+
+// normalizeQuery collapses all whitespace sequences of the query into a single space.
+func normalizeQuery(query string) string {
+	return strings.Join(strings.Fields(query), " ")
+}
+
+// trimComments strips leading whitespace and SQL comments from the query.
+func trimComments(query string) string {
+	for {
+		query = strings.TrimSpace(query)
+		switch {
+		case strings.HasPrefix(query, "--"):
+			i := strings.IndexByte(query, '\n')
+			if i == -1 {
+				return ""
+			}
+			query = query[i+1:]
+		case strings.HasPrefix(query, "/*"):
+			i := strings.Index(query, "*/")
+			if i == -1 {
+				return ""
+			}
+			query = query[i+2:]
+		default:
+			return query
+		}
+	}
+}
+
+// SanitizeQuery returns the query with its whitespace collapsed and its
+// string and numeric literals replaced by "?", so it can be reported
+// without leaking the values inlined in it.
+func SanitizeQuery(query string) string {
+	var (
+		b    strings.Builder
+		prev byte
+	)
+	query = normalizeQuery(query)
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c == '\'':
+			// Skip the literal, including escaped quotes ('').
+			for i++; i < len(query); i++ {
+				if query[i] == '\'' {
+					if i+1 < len(query) && query[i+1] == '\'' {
+						i++
+						continue
+					}
+					break
+				}
+			}
+			b.WriteByte('?')
+		case '0' <= c && c <= '9' && !isIdentStart(prev):
+			for i+1 < len(query) && ('0' <= query[i+1] && query[i+1] <= '9' || query[i+1] == '.') {
+				i++
+			}
+			b.WriteByte('?')
+		default:
+			b.WriteByte(c)
+		}
+		prev = c
+	}
+	return b.String()
+}
+
+// Fingerprint returns a short hash identifying the shape of the query,
+// i.e. queries that differ only in their whitespace or inlined literals
+// share the same fingerprint.
+func Fingerprint(query string) string {
+	h := fnv.New64a()
+	h.Write([]byte(SanitizeQuery(query)))
+	return fmt.Sprintf("%016x", h.Sum64())
+}
@@ -0,0 +1,31 @@
+package driver
+
+import "testing"
+
+func TestIsReadOnly(t *testing.T) {
+	tests := []struct {
+		query string
+		want  bool
+	}{
+		{"SELECT * FROM users", true},
+		{"select id from users where name = 'FOR UPDATE'", true},
+		{"WITH u AS (SELECT id FROM users) SELECT * FROM u", true},
+		{"SELECT SUBSTRING(name FROM 1 FOR 2) FROM users", true},
+		{"SELECT * FROM users FOR UPDATE", false},
+		{"SELECT * FROM users FOR SHARE", false},
+		{"SELECT * FROM users FOR NO KEY UPDATE", false},
+		{"SELECT * FROM users FOR KEY SHARE", false},
+		{"SELECT * FROM users for update skip locked", false},
+		{"SELECT * FROM users LOCK IN SHARE MODE", false},
+		{"SELECT * INTO archive FROM users", false},
+		{"WITH d AS (DELETE FROM users RETURNING *) SELECT * FROM d", false},
+		{"WITH i AS (INSERT INTO users (id) VALUES (1) RETURNING id) SELECT * FROM i", false},
+		{"INSERT INTO users (id) VALUES (1)", false},
+		{"UPDATE users SET name = 'a'", false},
+	}
+	for _, tt := range tests {
+		if got := isReadOnly(tt.query); got != tt.want {
+			t.Errorf("isReadOnly(%q) = %t, want %t", tt.query, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package driver
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	entsql "entgo.io/ent/dialect/sql"
+)
+
+// result is a materialized result set.
+type result struct {
+	columns []string
+	values  [][]any
+}
+
+// materialize reads all rows from rs into memory and closes it.
+func materialize(rs entsql.ColumnScanner) (*result, error) {
+	defer rs.Close()
+	columns, err := rs.Columns()
+	if err != nil {
+		return nil, err
+	}
+	res := &result{columns: columns}
+	for rs.Next() {
+		values := make([]any, len(columns))
+		dest := make([]any, len(columns))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err := rs.Scan(dest...); err != nil {
+			return nil, err
+		}
+		res.values = append(res.values, values)
+	}
+	if err := rs.Err(); err != nil {
+		return nil, err
+	}
+	return res, rs.Close()
+}
+
+// rows returns a new *sql.Rows reading the materialized result. The values
+// are served through database/sql, so they are converted to the scan
+// destinations exactly as they are for rows read from the database.
+func (r *result) rows(ctx context.Context) (*sql.Rows, error) {
+	token := strconv.FormatUint(replayID.Add(1), 10)
+	replayResults.Store(token, r)
+	defer replayResults.Delete(token)
+	return replayDB.QueryContext(ctx, token)
+}
+
+var (
+	replayID      atomic.Uint64
+	replayResults sync.Map // token => *result
+	replayDB      = sql.OpenDB(replayConnector{})
+)
+
+// replayConnector is the driver.Connector of the replay database.
+type replayConnector struct{}
+
+func (replayConnector) Connect(context.Context) (driver.Conn, error) { return replayConn{}, nil }
+func (replayConnector) Driver() driver.Driver                        { return nil }
+
+// replayConn is a connection that serves materialized results
+// registered under the query text.
+type replayConn struct{}
+
+func (replayConn) Prepare(string) (driver.Stmt, error) { return nil, driver.ErrSkip }
+func (replayConn) Close() error                        { return nil }
+func (replayConn) Begin() (driver.Tx, error) {
+	return nil, unsupported("replay.Begin")
+}
+
+func (replayConn) QueryContext(_ context.Context, token string, _ []driver.NamedValue) (driver.Rows, error) {
+	r, ok := replayResults.Load(token)
+	if !ok {
+		return nil, fmt.Errorf("entzlog: unknown replay result %q", token)
+	}
+	return &replayRows{result: r.(*result)}, nil
+}
+
+// replayRows implements driver.Rows for a materialized result.
+type replayRows struct {
+	*result
+	pos int
+}
+
+func (r *replayRows) Columns() []string { return r.columns }
+func (r *replayRows) Close() error      { return nil }
+
+func (r *replayRows) Next(dest []driver.Value) error {
+	if r.pos >= len(r.values) {
+		return io.EOF
+	}
+	for i, v := range r.values[r.pos] {
+		dest[i] = v
+	}
+	r.pos++
+	return nil
+}
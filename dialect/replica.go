@@ -0,0 +1,228 @@
+package driver
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"entgo.io/ent/dialect"
+	"go.uber.org/zap"
+)
+
+// BalancePolicy defines how the ReplicaDriver picks a replica for a read.
+type BalancePolicy int
+
+const (
+	// RoundRobin cycles through the healthy replicas.
+	RoundRobin BalancePolicy = iota
+	// LeastLoaded picks the healthy replica with the fewest in-flight reads.
+	// The reads of Query are in flight until their rows are closed, and those
+	// of QueryContext until they return.
+	LeastLoaded
+)
+
+// Replica is a named read replica.
+type Replica struct {
+	Name   string
+	Driver Driver
+}
+
+// ReplicaConfig configures a ReplicaDriver.
+type ReplicaConfig struct {
+	// Policy used to pick a replica. Defaults to RoundRobin.
+	Policy BalancePolicy
+	// MaxFailures is the number of consecutive connection failures
+	// after which a replica is marked unhealthy. Defaults to 3.
+	MaxFailures int
+	// Cooldown is the time an unhealthy replica is skipped before it
+	// is tried again. Defaults to 30 seconds.
+	Cooldown time.Duration
+	// Log is the log function. Optional.
+	Log LogFunc
+	// Metrics receives the per-replica measurements. Optional.
+	Metrics Metrics
+}
+
+// ReplicaDriver is a driver that sends read-only queries to a set of read
+// replicas and everything else (writes, locking reads and transactions) to
+// the primary driver.
+type ReplicaDriver struct {
+	Driver   // primary driver.
+	replicas []*replica
+	next     atomic.Uint64
+	cfg      ReplicaConfig
+}
+
+// replica holds the health state of a Replica.
+type replica struct {
+	Replica
+	inflight atomic.Int64
+	mu       sync.Mutex
+	failures int
+	downAt   time.Time // zero if healthy.
+}
+
+// NewReplicaDriver returns a ReplicaDriver that balances the read-only
+// queries between the given replicas.
+func NewReplicaDriver(primary Driver, replicas []Replica, cfg ReplicaConfig) *ReplicaDriver {
+	if cfg.MaxFailures <= 0 {
+		cfg.MaxFailures = 3
+	}
+	if cfg.Cooldown <= 0 {
+		cfg.Cooldown = 30 * time.Second
+	}
+	if cfg.Log == nil {
+		cfg.Log = nopLog
+	}
+	if cfg.Metrics == nil {
+		cfg.Metrics = nopMetrics{}
+	}
+	d := &ReplicaDriver{Driver: primary, cfg: cfg}
+	for _, r := range replicas {
+		d.replicas = append(d.replicas, &replica{Replica: r})
+	}
+	return d
+}
+
+type primaryKey struct{}
+
+// WithPrimary returns a context that forces all queries executed with it to
+// the primary driver. Useful for reading your own writes.
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+// Query sends read-only queries to a replica and calls the primary Query method otherwise.
+func (d *ReplicaDriver) Query(ctx context.Context, query string, args, v any) error {
+	r := d.pick(ctx, query)
+	if r == nil {
+		return d.Driver.Query(ctx, query, args, v)
+	}
+	logArgs(ctx, d.cfg.Log, false, "replica.Query", args, zap.String("replica", r.Name), zap.String("query", query))
+	// The read is in flight until its rows are closed.
+	var once sync.Once
+	release := func() { once.Do(func() { r.inflight.Add(-1) }) }
+	r.inflight.Add(1)
+	defer func() { release() }()
+	return d.observe(ctx, r, func() error {
+		return releaseRows(v, &release, r.Driver.Query(ctx, query, args, v))
+	})
+}
+
+// QueryContext sends read-only queries to a replica and calls the primary QueryContext method otherwise.
+func (d *ReplicaDriver) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	drv := d.Driver
+	r := d.pick(ctx, query)
+	if r != nil {
+		drv = r.Driver
+	}
+	if r == nil {
+		return queryContext(ctx, drv, query, args)
+	}
+	logArgs(ctx, d.cfg.Log, false, "replica.QueryContext", args, zap.String("replica", r.Name), zap.String("query", query))
+	var rows *sql.Rows
+	r.inflight.Add(1)
+	defer r.inflight.Add(-1)
+	err := d.observe(ctx, r, func() (err error) {
+		rows, err = queryContext(ctx, drv, query, args)
+		return err
+	})
+	return rows, err
+}
+
+// ExecContext calls the primary ExecContext method.
+func (d *ReplicaDriver) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return execContext(ctx, d.Driver, query, args)
+}
+
+// BeginTx calls the primary BeginTx method if it is supported.
+func (d *ReplicaDriver) BeginTx(ctx context.Context, opts *sql.TxOptions) (dialect.Tx, error) {
+	return beginTx(ctx, d.Driver, opts)
+}
+
+// Close closes the primary and all replica drivers.
+func (d *ReplicaDriver) Close() error {
+	errs := []error{d.Driver.Close()}
+	for _, r := range d.replicas {
+		errs = append(errs, r.Driver.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// pick returns the replica that should serve the query, or nil if
+// it should be sent to the primary.
+func (d *ReplicaDriver) pick(ctx context.Context, query string) *replica {
+	if len(d.replicas) == 0 || !isReadOnly(query) {
+		return nil
+	}
+	if force, _ := ctx.Value(primaryKey{}).(bool); force {
+		return nil
+	}
+	now := time.Now()
+	var (
+		picked *replica
+		n      = uint64(len(d.replicas))
+		start  = d.next.Add(1)
+	)
+	for i := uint64(0); i < n; i++ {
+		r := d.replicas[(start+i)%n]
+		if !r.available(now, d.cfg.Cooldown) {
+			continue
+		}
+		if d.cfg.Policy == RoundRobin {
+			return r
+		}
+		if picked == nil || r.inflight.Load() < picked.inflight.Load() {
+			picked = r
+		}
+	}
+	if picked == nil {
+		d.cfg.Log(ctx, "replica: no healthy replica, using primary", zap.String("query", query))
+		d.cfg.Metrics.Count(ctx, "entzlog_replica_fallback_total", 1)
+	}
+	return picked
+}
+
+// observe runs fn against the replica r and records its outcome.
+func (d *ReplicaDriver) observe(ctx context.Context, r *replica, fn func() error) error {
+	start := time.Now()
+	err := fn()
+	status := "ok"
+	if err != nil {
+		status = "error"
+	}
+	labels := []Label{{"replica", r.Name}, {"status", status}}
+	d.cfg.Metrics.Count(ctx, "entzlog_replica_queries_total", 1, labels...)
+	d.cfg.Metrics.Observe(ctx, "entzlog_replica_query_duration_seconds", time.Since(start), labels...)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch {
+	case isConnError(err):
+		r.failures++
+		if r.failures == d.cfg.MaxFailures || !r.downAt.IsZero() {
+			r.downAt = time.Now()
+			d.cfg.Log(ctx, "replica: marked unhealthy", zap.String("replica", r.Name), zap.Int("failures", r.failures), zap.Error(err))
+			d.cfg.Metrics.Gauge(ctx, "entzlog_replica_healthy", 0, Label{"replica", r.Name})
+		}
+	case ctx.Err() != nil:
+		// The caller gave up; this says nothing about the replica health.
+	default:
+		if !r.downAt.IsZero() {
+			d.cfg.Log(ctx, "replica: recovered", zap.String("replica", r.Name), zap.Duration("downtime", time.Since(r.downAt)))
+			d.cfg.Metrics.Gauge(ctx, "entzlog_replica_healthy", 1, Label{"replica", r.Name})
+		}
+		r.failures, r.downAt = 0, time.Time{}
+	}
+	return err
+}
+
+// available reports whether the replica can serve reads. An unhealthy
+// replica becomes available again once its cooldown has elapsed.
+func (r *replica) available(now time.Time, cooldown time.Duration) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.downAt.IsZero() || now.Sub(r.downAt) >= cooldown
+}
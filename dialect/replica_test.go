@@ -0,0 +1,82 @@
+package driver
+
+import (
+	"context"
+	"testing"
+
+	"entgo.io/ent/dialect"
+	entsql "entgo.io/ent/dialect/sql"
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestReplicaDriverRouting(t *testing.T) {
+	tests := []struct {
+		query   string
+		ctx     context.Context
+		replica bool
+	}{
+		{query: "SELECT id FROM users", ctx: context.Background(), replica: true},
+		{query: "SELECT id FROM users", ctx: WithPrimary(context.Background())},
+		{query: "SELECT id FROM users FOR UPDATE", ctx: context.Background()},
+		{query: "SELECT id FROM users FOR NO KEY UPDATE", ctx: context.Background()},
+		{query: "SELECT id FROM users FOR KEY SHARE", ctx: context.Background()},
+		{query: "SELECT id FROM users LOCK IN SHARE MODE", ctx: context.Background()},
+		{query: "WITH d AS (DELETE FROM users RETURNING id) SELECT id FROM d", ctx: context.Background()},
+		{query: "SELECT id INTO archive FROM users", ctx: context.Background()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			pdb, primary, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer pdb.Close()
+			rdb, replica, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rdb.Close()
+			target := primary
+			if tt.replica {
+				target = replica
+			}
+			target.ExpectQuery(tt.query).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+			drv := NewReplicaDriver(entsql.OpenDB(dialect.Postgres, pdb), []Replica{{Name: "r1", Driver: entsql.OpenDB(dialect.Postgres, rdb)}}, ReplicaConfig{})
+			var rows entsql.Rows
+			if err := drv.Query(tt.ctx, tt.query, []any{}, &rows); err != nil {
+				t.Fatal(err)
+			}
+			rows.Close()
+			for _, m := range []sqlmock.Sqlmock{primary, replica} {
+				if err := m.ExpectationsWereMet(); err != nil {
+					t.Error(err)
+				}
+			}
+		})
+	}
+}
+
+func TestReplicaDriverInflight(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	mock.ExpectQuery("SELECT id FROM users").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2))
+	drv := NewReplicaDriver(nil, []Replica{{Name: "r1", Driver: entsql.OpenDB(dialect.Postgres, db)}}, ReplicaConfig{Policy: LeastLoaded})
+	var rows entsql.Rows
+	if err := drv.Query(context.Background(), "SELECT id FROM users", []any{}, &rows); err != nil {
+		t.Fatal(err)
+	}
+	r := drv.replicas[0]
+	if n := r.inflight.Load(); n != 1 {
+		t.Errorf("inflight = %d before the rows are closed, want 1", n)
+	}
+	for rows.Next() {
+	}
+	rows.Close()
+	rows.Close()
+	if n := r.inflight.Load(); n != 0 {
+		t.Errorf("inflight = %d after the rows are closed, want 0", n)
+	}
+}
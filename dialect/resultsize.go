@@ -0,0 +1,191 @@
+package driver
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// RequestStats aggregates the statements executed with the context of a
+// request, and the results of its queries. See WithRequestStats.
+type RequestStats struct {
+	queries    atomic.Int64
+	rows       atomic.Int64
+	bytes      atomic.Int64
+	statements atomic.Int64
+	duration   atomic.Int64 // total duration of the statements, in nanoseconds.
+
+	mu      sync.Mutex
+	slowest string               // query of the slowest statement.
+	slowDur time.Duration        // duration of the slowest statement.
+	errors  map[ErrorClass]int64 // failed statements, by error class.
+}
+
+type requestStatsKey struct{}
+
+// WithRequestStats returns a context aggregating the results of the queries
+// executed with it through a DebugDriver, e.g. to log the number of rows and
+// bytes read by an endpoint. It is typically called once per request:
+//
+//	ctx, stats := driver.WithRequestStats(r.Context())
+//	next.ServeHTTP(w, r.WithContext(ctx))
+//	logger.Info("request served", stats.Fields()...)
+func WithRequestStats(ctx context.Context) (context.Context, *RequestStats) {
+	s := &RequestStats{}
+	return context.WithValue(ctx, requestStatsKey{}, s), s
+}
+
+// RequestStatsFromContext returns the request stats of the context, if any.
+func RequestStatsFromContext(ctx context.Context) (*RequestStats, bool) {
+	s, ok := ctx.Value(requestStatsKey{}).(*RequestStats)
+	return s, ok
+}
+
+// Queries returns the number of queries whose rows were consumed or closed.
+func (s *RequestStats) Queries() int64 { return s.queries.Load() }
+
+// Rows returns the number of rows returned by the queries.
+func (s *RequestStats) Rows() int64 { return s.rows.Load() }
+
+// Bytes returns the approximate size of the data scanned from the rows.
+// See ResultSize.
+func (s *RequestStats) Bytes() int64 { return s.bytes.Load() }
+
+// Statements returns the number of statements executed.
+func (s *RequestStats) Statements() int64 { return s.statements.Load() }
+
+// Duration returns the total duration of the statements.
+func (s *RequestStats) Duration() time.Duration { return time.Duration(s.duration.Load()) }
+
+// Slowest returns the query and the duration of the slowest statement.
+func (s *RequestStats) Slowest() (string, time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.slowest, s.slowDur
+}
+
+// Errors returns the number of failed statements by error class.
+func (s *RequestStats) Errors() map[ErrorClass]int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	errs := make(map[ErrorClass]int64, len(s.errors))
+	for c, n := range s.errors {
+		errs[c] = n
+	}
+	return errs
+}
+
+// Fields returns the stats as log fields.
+func (s *RequestStats) Fields() []zap.Field {
+	var failed int64
+	for _, n := range s.Errors() {
+		failed += n
+	}
+	return []zap.Field{
+		zap.Int64("db_queries", s.Queries()),
+		zap.Int64("db_rows", s.Rows()),
+		zap.Int64("db_result_bytes", s.Bytes()),
+		zap.Int64("db_statements", s.Statements()),
+		zap.Duration("db_duration", s.Duration()),
+		zap.Int64("db_errors", failed),
+	}
+}
+
+// record aggregates an executed statement, with the class of its error.
+func (s *RequestStats) record(query string, took time.Duration, class ErrorClass) {
+	s.statements.Add(1)
+	s.duration.Add(int64(took))
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if took > s.slowDur || s.slowest == "" {
+		s.slowest, s.slowDur = query, took
+	}
+	if class != "" {
+		if s.errors == nil {
+			s.errors = make(map[ErrorClass]int64)
+		}
+		s.errors[class]++
+	}
+}
+
+// add aggregates the result of a query.
+func (s *RequestStats) add(rows, bytes int64) {
+	s.queries.Add(1)
+	s.rows.Add(rows)
+	s.bytes.Add(bytes)
+}
+
+// ResultSize returns the approximate size in bytes of the values scanned
+// into dest: the length of strings and byte slices, and the in-memory size
+// of the other values. It is an estimate of the data transferred from the
+// database, not of its wire size.
+func ResultSize(dest ...any) int64 {
+	var n int64
+	for _, v := range dest {
+		switch v := v.(type) {
+		case *string:
+			n += int64(len(*v))
+		case *[]byte:
+			n += int64(len(*v))
+		case *sql.RawBytes:
+			n += int64(len(*v))
+		case *sql.NullString:
+			n += int64(len(v.String))
+		case *int, *int64, *uint64, *float64, *sql.NullInt64, *sql.NullFloat64, *time.Time, *sql.NullTime:
+			n += 8
+		case *bool, *sql.NullBool:
+			n++
+		default:
+			n += valueSize(reflect.ValueOf(v))
+		}
+	}
+	return n
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// valueSize returns the approximate size of the value.
+func valueSize(v reflect.Value) int64 {
+	switch v.Kind() {
+	case reflect.Invalid:
+		return 0
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return 0
+		}
+		return valueSize(v.Elem())
+	case reflect.String:
+		return int64(v.Len())
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return int64(v.Len())
+		}
+		var n int64
+		for i := 0; i < v.Len(); i++ {
+			n += valueSize(v.Index(i))
+		}
+		return n
+	case reflect.Map:
+		var n int64
+		for it := v.MapRange(); it.Next(); {
+			n += valueSize(it.Key()) + valueSize(it.Value())
+		}
+		return n
+	case reflect.Struct:
+		if v.Type() == timeType {
+			return 8
+		}
+		var n int64
+		for i := 0; i < v.NumField(); i++ {
+			n += valueSize(v.Field(i))
+		}
+		return n
+	default:
+		return int64(v.Type().Size())
+	}
+}
@@ -0,0 +1,118 @@
+package driver
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// RotateConfig configures the rotation of the files written by the file sinks.
+type RotateConfig struct {
+	// MaxSize is the size in bytes from which the file is rotated. Defaults to 100MB.
+	MaxSize int64
+	// MaxAge is the time after which the file is rotated. Zero disables
+	// time-based rotation.
+	MaxAge time.Duration
+	// MaxBackups is the number of rotated files kept. Defaults to 7.
+	MaxBackups int
+}
+
+// rotatingFile is a file that is rotated by size and age. Rotated files
+// are renamed with their rotation time, e.g. "queries.jsonl" is rotated
+// to "queries-20060102T150405.000.jsonl".
+type rotatingFile struct {
+	path   string
+	cfg    RotateConfig
+	header []byte // written at the start of each file.
+	mu     sync.Mutex
+	f      *os.File
+	size   int64
+	opened time.Time
+}
+
+// openRotating opens or creates the file at path for appending.
+func openRotating(path string, cfg RotateConfig, header []byte) (*rotatingFile, error) {
+	if cfg.MaxSize <= 0 {
+		cfg.MaxSize = 100 << 20
+	}
+	if cfg.MaxBackups <= 0 {
+		cfg.MaxBackups = 7
+	}
+	r := &rotatingFile{path: path, cfg: cfg, header: header}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Write writes p to the file, rotating it first if needed. A single
+// write is never split across files.
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.size > int64(len(r.header)) && (r.size+int64(len(p)) > r.cfg.MaxSize || r.cfg.MaxAge > 0 && time.Since(r.opened) >= r.cfg.MaxAge) {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// Close closes the file.
+func (r *rotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.f.Close()
+}
+
+// open opens the file and writes its header if it is new.
+func (r *rotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.f, r.size, r.opened = f, info.Size(), time.Now()
+	if r.size == 0 && len(r.header) > 0 {
+		n, err := f.Write(r.header)
+		r.size += int64(n)
+		return err
+	}
+	return nil
+}
+
+// rotate renames the current file, opens a new one and removes the
+// backups exceeding MaxBackups.
+func (r *rotatingFile) rotate() error {
+	if err := r.f.Close(); err != nil {
+		return err
+	}
+	ext := filepath.Ext(r.path)
+	base := r.path[:len(r.path)-len(ext)]
+	backup := fmt.Sprintf("%s-%s%s", base, time.Now().Format("20060102T150405.000"), ext)
+	if err := os.Rename(r.path, backup); err != nil {
+		return err
+	}
+	if err := r.open(); err != nil {
+		return err
+	}
+	backups, err := filepath.Glob(base + "-*" + ext)
+	if err != nil {
+		return err
+	}
+	sort.Strings(backups)
+	for len(backups) > r.cfg.MaxBackups {
+		os.Remove(backups[0])
+		backups = backups[1:]
+	}
+	return nil
+}
@@ -0,0 +1,216 @@
+package driver
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+
+	entsql "entgo.io/ent/dialect/sql"
+	"go.uber.org/zap"
+)
+
+// WithOpenRowsWarning returns an option that logs a warning, with the query
+// and its caller, for the rows returned by Query that are neither consumed
+// nor closed after the given duration, and may hold their connection for
+// too long. Like failures, these warnings are always logged.
+func WithOpenRowsWarning(after time.Duration) Option {
+	return func(d *DebugDriver) {
+		d.openRows = after
+	}
+}
+
+// countRows wraps the rows of a successful Query to log and measure them once
+// they are consumed or closed, and returns err.
+func (d *DebugDriver) countRows(ctx context.Context, start time.Time, txID, op, query string, v any, err error) error {
+	if err != nil {
+		return err
+	}
+	logged := d.logs(ctx)
+	stats, _ := RequestStatsFromContext(ctx)
+	if !logged && d.openRows <= 0 && stats == nil && !d.recoverPanics && len(d.sinks) == 0 {
+		return nil
+	}
+	rows, ok := v.(*entsql.Rows)
+	if !ok || rows.ColumnScanner == nil {
+		return nil
+	}
+	var emit func(n int64)
+	if p, ok := rows.ColumnScanner.(*pendingRows); ok {
+		rows.ColumnScanner, emit = p.ColumnScanner, p.emit
+	}
+	cr := &countedRows{ColumnScanner: rows.ColumnScanner, start: start, exec: time.Since(start), measure: logged || stats != nil}
+	if d.openRows > 0 {
+		caller := d.caller()
+		cr.timer = time.AfterFunc(d.openRows, func() {
+			fields := []zap.Field{zap.Duration("open", d.openRows), zap.String("query", query)}
+			if caller != "" {
+				fields = append(fields, zap.String("caller", caller))
+			}
+			d.log(ctx, d.opMsg(txID, op)+": rows still open", ctxFields(ctx, fields)...)
+		})
+	}
+	cr.done = func(r *countedRows) {
+		if stats != nil {
+			stats.add(r.n, r.bytes)
+		}
+		if emit != nil {
+			emit(r.n)
+		}
+		if !logged {
+			return
+		}
+		d.log(ctx, d.opMsg(txID, op)+": rows returned", ctxFields(ctx, []zap.Field{
+			zap.Int64("rows_returned", r.n),
+			zap.Duration("exec_duration", r.exec),
+			zap.Duration("time_to_first_row", r.first),
+			zap.Duration("consume_duration", r.consumed),
+			zap.Duration("iterate_duration", r.iterate),
+			zap.Duration("scan_duration", r.scan),
+			zap.Int64("result_bytes", r.bytes),
+			zap.String("query", query),
+		})...)
+	}
+	cr.fail = func(method string, err error) {
+		d.log(ctx, d.opMsg(txID, op)+": rows."+method+" failed", ctxFields(ctx, []zap.Field{
+			zap.Error(err),
+			LazyString("error_class", func() string { return string(classifyContextError(ctx, err)) }),
+			zap.Int64("rows_returned", cr.n),
+			zap.String("query", query),
+		})...)
+	}
+	if d.recoverPanics {
+		cr.panicked = func(r any) { d.logPanic(ctx, txID, op, query, r) }
+	}
+	rows.ColumnScanner = cr
+	return nil
+}
+
+// pendingRows carries the event of a Query, set by run, until its rows are
+// counted by countRows.
+type pendingRows struct {
+	entsql.ColumnScanner
+	emit func(n int64)
+}
+
+// affectedRows returns the rows affected by the statement with the given
+// result, or -1 if unknown.
+func affectedRows(v any) int64 {
+	var res sql.Result
+	switch v := v.(type) {
+	case *sql.Result:
+		if v != nil {
+			res = *v
+		}
+	case sql.Result:
+		res = v
+	}
+	if res == nil {
+		return -1
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+// countedRows counts the rows read from the underlying scanner and times
+// their consumption, and passes itself to done once they are consumed or it
+// is closed.
+type countedRows struct {
+	entsql.ColumnScanner
+	n        int64
+	start    time.Time     // start of the statement.
+	exec     time.Duration // time from start to the return of the statement.
+	first    time.Duration // time from start to the first row.
+	consumed time.Duration // time from the first row to the end of the rows.
+	iterate  time.Duration // time spent in Next.
+	scan     time.Duration // time spent in Scan.
+	bytes    int64         // approximate size of the scanned values.
+	measure  bool          // whether the scanned values are measured.
+	once     sync.Once
+	timer    *time.Timer // warns about rows left open, if set.
+	done     func(r *countedRows)
+	failed   bool                           // whether an error was reported.
+	fail     func(method string, err error) // reports the errors of the rows, if set.
+	panicked func(r any)                    // logs the panics of the scan targets, if set.
+}
+
+// Next advances to the next row, and counts it.
+func (r *countedRows) Next() bool {
+	t := time.Now()
+	ok := r.ColumnScanner.Next()
+	r.iterate += time.Since(t)
+	if !ok {
+		r.report()
+		return false
+	}
+	if r.n++; r.n == 1 {
+		r.first = time.Since(r.start)
+	}
+	return true
+}
+
+// Scan copies the columns of the current row, and times and measures it.
+func (r *countedRows) Scan(dest ...any) error {
+	if r.panicked != nil {
+		defer func() {
+			if v := recover(); v != nil {
+				r.panicked(v)
+				panic(v)
+			}
+		}()
+	}
+	t := time.Now()
+	err := r.ColumnScanner.Scan(dest...)
+	r.scan += time.Since(t)
+	if err == nil && r.measure {
+		r.bytes += ResultSize(dest...)
+	}
+	return err
+}
+
+// Err returns the error of the iteration, and reports it.
+func (r *countedRows) Err() error {
+	err := r.ColumnScanner.Err()
+	r.reportErr("Err", err)
+	return err
+}
+
+// Close closes the underlying scanner, and reports the rows read and
+// the error of the iteration or of the close.
+func (r *countedRows) Close() error {
+	err := r.ColumnScanner.Close()
+	r.report()
+	if err != nil {
+		r.reportErr("Close", err)
+	} else {
+		r.reportErr("Err", r.ColumnScanner.Err())
+	}
+	return err
+}
+
+// reportErr passes the first error of the rows to fail.
+func (r *countedRows) reportErr(method string, err error) {
+	if err == nil || r.fail == nil || r.failed {
+		return
+	}
+	r.failed = true
+	r.fail(method, err)
+}
+
+// report passes the count and timings of the rows to done, once.
+func (r *countedRows) report() {
+	r.once.Do(func() {
+		if r.timer != nil {
+			r.timer.Stop()
+		}
+		took := time.Since(r.start)
+		if r.n == 0 {
+			r.first = took
+		}
+		r.consumed = took - r.first
+		r.done(r)
+	})
+}
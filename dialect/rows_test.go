@@ -0,0 +1,68 @@
+package driver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"entgo.io/ent/dialect"
+	entsql "entgo.io/ent/dialect/sql"
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestCountRowsMeasure(t *testing.T) {
+	quiet := WithLogFilter(func(context.Context) bool { return false })
+	tests := []struct {
+		name  string
+		opts  []Option
+		stats bool
+		want  bool
+	}{
+		{name: "logged", want: true},
+		{name: "stats", opts: []Option{quiet}, stats: true, want: true},
+		{name: "sink", opts: []Option{quiet, WithSink(&recordSink{})}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			mock.ExpectQuery("SELECT name FROM users").WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("a8m"))
+			drv := newDebugDriver(entsql.OpenDB(dialect.Postgres, db), nopLog, tt.opts...)
+			ctx := context.Background()
+			var stats *RequestStats
+			if tt.stats {
+				ctx, stats = WithRequestStats(ctx)
+			}
+			var rows entsql.Rows
+			if err := drv.Driver.Query(ctx, "SELECT name FROM users", []any{}, &rows); err != nil {
+				t.Fatal(err)
+			}
+			if err := drv.countRows(ctx, time.Now(), "", "Query", "SELECT name FROM users", &rows, nil); err != nil {
+				t.Fatal(err)
+			}
+			cr, ok := rows.ColumnScanner.(*countedRows)
+			if !ok {
+				t.Fatalf("rows are not counted: %T", rows.ColumnScanner)
+			}
+			for rows.Next() {
+				var name string
+				if err := rows.Scan(&name); err != nil {
+					t.Fatal(err)
+				}
+			}
+			rows.Close()
+			if cr.n != 1 {
+				t.Errorf("counted %d rows, want 1", cr.n)
+			}
+			if measured := cr.bytes > 0; measured != tt.want {
+				t.Errorf("measured %d bytes, want measured %t", cr.bytes, tt.want)
+			}
+			if stats != nil && stats.Bytes() != cr.bytes {
+				t.Errorf("stats bytes = %d, want %d", stats.Bytes(), cr.bytes)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+
+	"entgo.io/ent/dialect"
+	entsql "entgo.io/ent/dialect/sql"
+)
+
+// AttrDBSchema is the attribute key of the database schema.
+const AttrDBSchema = "db_schema"
+
+// WithSchema returns an option that adds the schema the driver operates on
+// as the db_schema attribute of all log entries and events.
+func WithSchema(schema string) Option {
+	return WithAttrs(Attr{AttrDBSchema, schema})
+}
+
+// Discover queries the current database and schema of the driver, and
+// returns the option adding them as the db_name and db_schema attributes
+// of all log entries and events. For example:
+//
+//	opt, err := driver.Discover(ctx, drv)
+//	if err != nil {
+//		return err
+//	}
+//	drv = driver.DebugWithContext(drv, log, opt)
+func Discover(ctx context.Context, d Driver) (Option, error) {
+	var query string
+	switch d.Dialect() {
+	case dialect.Postgres:
+		query = "SELECT current_database(), current_schema()"
+	case dialect.MySQL:
+		query = "SELECT DATABASE(), DATABASE()"
+	case dialect.SQLite:
+		// The main database is the file or memory database of the DSN.
+		return WithSchema("main"), nil
+	default:
+		return nil, unsupported("Discover(" + d.Dialect() + ")")
+	}
+	var rows entsql.Rows
+	if err := d.Query(ctx, query, []any{}, &rows); err != nil {
+		return nil, fmt.Errorf("entzlog: discovering database: %w", err)
+	}
+	defer rows.Close()
+	var name, schema entsql.NullString
+	if rows.Next() {
+		if err := rows.Scan(&name, &schema); err != nil {
+			return nil, fmt.Errorf("entzlog: discovering database: %w", err)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("entzlog: discovering database: %w", err)
+	}
+	return WithAttrs(Attr{AttrDBName, name.String}, Attr{AttrDBSchema, schema.String}), nil
+}
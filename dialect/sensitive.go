@@ -0,0 +1,81 @@
+package driver
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	entsql "entgo.io/ent/dialect/sql"
+	"go.uber.org/zap"
+)
+
+// WithSensitiveTables returns an option that logs the SELECT statements
+// reading the given tables, with their actor, purpose and rows, to logger at
+// the Info level, regardless of the filters of the driver.
+func WithSensitiveTables(logger *zap.Logger, tables ...string) Option {
+	if logger == nil {
+		logger = zap.L()
+	}
+	return func(d *DebugDriver) {
+		if d.sensitive == nil {
+			d.sensitive = make(map[string]bool)
+		}
+		for _, t := range tables {
+			d.sensitive[strings.ToLower(t)] = true
+		}
+		d.accessLog = logger
+	}
+}
+
+type purposeKey struct{}
+
+// WithPurpose returns a context carrying the purpose of the data accesses
+// issued with it, e.g. "support-ticket" or "billing", which is logged with
+// the reads of the sensitive tables. See WithSensitiveTables.
+func WithPurpose(ctx context.Context, purpose string) context.Context {
+	return context.WithValue(ctx, purposeKey{}, purpose)
+}
+
+// PurposeFromContext returns the purpose stored in the context, if any.
+func PurposeFromContext(ctx context.Context) (string, bool) {
+	p, ok := ctx.Value(purposeKey{}).(string)
+	return p, ok
+}
+
+// access logs the successful reads of the sensitive tables, and returns err.
+// If v holds the rows of the statement, the entry is logged once they are
+// consumed or closed, with their count.
+func (d *DebugDriver) access(ctx context.Context, txID, query string, v any, err error) error {
+	if err != nil || len(d.sensitive) == 0 || StatementType(query) != StmtSelect {
+		return err
+	}
+	var tables []string
+	for _, t := range queryTables(query) {
+		if d.sensitive[strings.ToLower(t)] {
+			tables = append(tables, t)
+		}
+	}
+	if len(tables) == 0 {
+		return nil
+	}
+	fields := []zap.Field{zap.Strings("tables", tables), zap.String("query", query)}
+	if txID != "" {
+		fields = append(fields, zap.String("tx_id", txID))
+	}
+	if actor, ok := ActorFromContext(ctx); ok {
+		fields = append(fields, zap.String("actor", actor))
+	}
+	if purpose, ok := PurposeFromContext(ctx); ok {
+		fields = append(fields, zap.String("purpose", purpose))
+	}
+	fields = ctxFields(ctx, fields)
+	rows, ok := v.(*entsql.Rows)
+	if !ok || rows.ColumnScanner == nil {
+		d.accessLog.Info("driver: sensitive access", fields...)
+		return nil
+	}
+	rows.ColumnScanner = &countedRows{ColumnScanner: rows.ColumnScanner, start: time.Now(), done: func(r *countedRows) {
+		d.accessLog.Info("driver: sensitive access", append(fields, zap.Int64("rows", r.n))...)
+	}}
+	return nil
+}
@@ -0,0 +1,98 @@
+// Package sentryhook provides a driver.Hook reporting failed statements to Sentry.
+package sentryhook
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/floatyun/entzlog/dialect"
+	"github.com/getsentry/sentry-go"
+)
+
+// Config configures the Sentry hook.
+type Config struct {
+	// Hub is the hub cloned for each statement whose context carries none,
+	// so that their breadcrumbs and scopes do not leak across requests.
+	// Defaults to sentry.CurrentHub().
+	Hub *sentry.Hub
+	// Classes are the error classes of the failures sent as events. Other
+	// failures are only recorded as breadcrumbs. Defaults to client and server
+	// timeouts, deadlocks, connection errors and unclassified errors.
+	Classes []driver.ErrorClass
+}
+
+// Hook records failed statements as Sentry breadcrumbs, and sends events
+// for the failures whose error class is configured. The events carry the
+// fingerprint and the sanitized query of the statement, and the id of its
+// transaction. The error messages are sanitized with driver.SanitizeError.
+type Hook struct {
+	cfg     Config
+	classes map[driver.ErrorClass]bool
+}
+
+// New returns a new Sentry hook. Use it with driver.WithHooks.
+func New(cfg Config) *Hook {
+	if cfg.Hub == nil {
+		cfg.Hub = sentry.CurrentHub()
+	}
+	if cfg.Classes == nil {
+		cfg.Classes = []driver.ErrorClass{driver.ErrorTimeout, driver.ErrorServerTimeout, driver.ErrorDeadlock, driver.ErrorConnection, driver.ErrorOther}
+	}
+	h := &Hook{cfg: cfg, classes: make(map[driver.ErrorClass]bool)}
+	for _, c := range cfg.Classes {
+		h.classes[c] = true
+	}
+	return h
+}
+
+// Before implements the driver.Hook interface.
+func (*Hook) Before(ctx context.Context, _, _ string, _ []any) context.Context {
+	return ctx
+}
+
+// After reports the statement to Sentry if it failed.
+func (h *Hook) After(ctx context.Context, op, query string, _ []any, err error, d time.Duration) {
+	if err == nil || query == "" {
+		return
+	}
+	hub := sentry.GetHubFromContext(ctx)
+	if hub == nil {
+		hub = h.cfg.Hub.Clone()
+	}
+	var (
+		class       = driver.ClassifyError(err)
+		fingerprint = driver.Fingerprint(query)
+		data        = map[string]any{
+			"op":          op,
+			"query":       driver.SanitizeQuery(query),
+			"fingerprint": fingerprint,
+			"error_class": string(class),
+			"duration_ms": d.Milliseconds(),
+		}
+	)
+	if id, ok := driver.TxIDFromContext(ctx); ok {
+		data["tx_id"] = id
+	}
+	hub.AddBreadcrumb(&sentry.Breadcrumb{
+		Type:      "query",
+		Category:  "db.sql",
+		Message:   data["query"].(string),
+		Data:      data,
+		Level:     sentry.LevelError,
+		Timestamp: time.Now(),
+	}, nil)
+	if !h.classes[class] {
+		return
+	}
+	hub.WithScope(func(scope *sentry.Scope) {
+		scope.SetTag("error_class", string(class))
+		scope.SetTag("db.fingerprint", fingerprint)
+		scope.SetContext("sql", data)
+		scope.SetFingerprint([]string{"{{ default }}", fingerprint})
+		event := sentry.NewEvent()
+		event.Level = sentry.LevelError
+		event.Exception = []sentry.Exception{{Type: fmt.Sprintf("%T", err), Value: driver.SanitizeError(err)}}
+		hub.CaptureEvent(event)
+	})
+}
@@ -0,0 +1,169 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"entgo.io/ent/dialect"
+	entsql "entgo.io/ent/dialect/sql"
+	"go.uber.org/zap"
+)
+
+// WithServerTimeout returns an option that passes the time remaining before
+// the deadline of the contexts to the database as a statement timeout, for
+// the transactions on Postgres and the SELECT statements on MySQL.
+func WithServerTimeout() Option {
+	return func(d *DebugDriver) {
+		d.serverTimeout = true
+	}
+}
+
+// ServerTimeouts holds static server-side timeouts of the statements,
+// applied in addition to the deadlines of the contexts. See WithServerTimeouts.
+type ServerTimeouts struct {
+	// Fingerprints maps the fingerprints of the statements to their timeout.
+	// See Fingerprint.
+	Fingerprints map[string]time.Duration
+	// Tables maps the tables to the timeout of the statements on them. The
+	// timeouts of the fingerprints take precedence. See StatementTable.
+	Tables map[string]time.Duration
+}
+
+// WithServerTimeouts returns an option that passes static timeouts of the
+// statements, by fingerprint or table, to the database, like WithServerTimeout.
+func WithServerTimeouts(t ServerTimeouts) Option {
+	return func(d *DebugDriver) {
+		d.serverTimeouts = t
+	}
+}
+
+// statementTimeout returns the static timeout of the statement, if any.
+func (d *DebugDriver) statementTimeout(query string) (time.Duration, bool) {
+	if len(d.serverTimeouts.Fingerprints) > 0 {
+		if t, ok := d.serverTimeouts.Fingerprints[Fingerprint(query)]; ok {
+			return t, true
+		}
+	}
+	if len(d.serverTimeouts.Tables) > 0 {
+		if t, ok := d.serverTimeouts.Tables[StatementTable(query)]; ok {
+			return t, true
+		}
+	}
+	return 0, false
+}
+
+// logServerTimeout logs a statement that failed with a server timeout
+// passed by the driver, along with its static timeout, if any.
+func (d *DebugDriver) logServerTimeout(ctx context.Context, txID, op, query string) {
+	limit, ok := d.statementTimeout(query)
+	if !ok && !d.serverTimeout {
+		return
+	}
+	fields := []zap.Field{zap.String("query", query)}
+	if ok {
+		fields = append(fields, zap.Duration("server_timeout", limit))
+	}
+	d.log(ctx, d.opMsg(txID, op)+": server timeout triggered", fields...)
+}
+
+// remaining returns the time remaining before the deadline of the context,
+// rounded up to the millisecond, or false if it has none or it expired.
+func remaining(ctx context.Context) (time.Duration, bool) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return 0, false
+	}
+	left := time.Until(deadline)
+	if left <= 0 {
+		return 0, false
+	}
+	return (left + time.Millisecond - 1).Truncate(time.Millisecond), true
+}
+
+// timeoutQuery returns the query to execute with the MAX_EXECUTION_TIME hint
+// of the deadline of the context or of the static timeout of the statement
+// on MySQL, whichever is shorter, or the query itself.
+func (d *DebugDriver) timeoutQuery(ctx context.Context, query string) string {
+	if d.Dialect() != dialect.MySQL {
+		return query
+	}
+	var left time.Duration
+	if d.serverTimeout {
+		left, _ = remaining(ctx)
+	}
+	if t, ok := d.statementTimeout(query); ok && (left == 0 || t < left) {
+		left = t
+	}
+	if left <= 0 {
+		return query
+	}
+	trimmed := strings.TrimLeft(query, " \t\r\n")
+	if len(trimmed) < len("SELECT") || !strings.EqualFold(trimmed[:len("SELECT")], "SELECT") || strings.Contains(query, "MAX_EXECUTION_TIME") {
+		return query
+	}
+	n := len(query) - len(trimmed) + len("SELECT")
+	return fmt.Sprintf("%s /*+ MAX_EXECUTION_TIME(%d) */%s", query[:n], left.Milliseconds(), query[n:])
+}
+
+// setTimeout sets the statement_timeout of the transaction on Postgres from
+// the deadline of its context. It is rolled back if it cannot be set.
+func (d *DebugDriver) setTimeout(ctx context.Context, tx dialect.Tx, id string) error {
+	if !d.serverTimeout || d.Dialect() != dialect.Postgres {
+		return nil
+	}
+	left, ok := remaining(ctx)
+	if !ok {
+		return nil
+	}
+	if err := tx.Exec(ctx, "SELECT set_config('statement_timeout', $1, true)", []any{strconv.FormatInt(left.Milliseconds(), 10)}, nil); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("entzlog: setting statement timeout: %w", err)
+	}
+	if d.logs(ctx) {
+		d.log(ctx, fmt.Sprintf("Tx(%s): statement timeout applied", id), zap.Duration("statement_timeout", left))
+	}
+	return nil
+}
+
+// limitStatement overrides the statement_timeout of the Postgres transaction
+// with the static timeout of the statement, or restores it if the previous
+// statement overrode it and this one has no timeout.
+func (d *DebugTx) limitStatement(ctx context.Context, query string) error {
+	if len(d.drv.serverTimeouts.Fingerprints) == 0 && len(d.drv.serverTimeouts.Tables) == 0 || d.drv.Dialect() != dialect.Postgres {
+		return nil
+	}
+	var timeout string
+	if t, ok := d.drv.statementTimeout(query); ok {
+		timeout = strconv.FormatInt(t.Milliseconds(), 10)
+	}
+	if timeout == d.timeout {
+		return nil
+	}
+	var err error
+	switch {
+	case d.timeout == "":
+		// The first override also saves the timeout of the transaction.
+		var rows entsql.Rows
+		if err = d.Tx.Query(ctx, "SELECT current_setting('statement_timeout'), set_config('statement_timeout', $1, true)", []any{timeout}, &rows); err != nil {
+			break
+		}
+		if rows.Next() {
+			err = rows.Scan(&d.baseTimeout, new(string))
+		}
+		if cerr := rows.Close(); err == nil {
+			err = cerr
+		}
+	case timeout == "":
+		err = d.Tx.Exec(ctx, "SELECT set_config('statement_timeout', $1, true)", []any{d.baseTimeout}, nil)
+	default:
+		err = d.Tx.Exec(ctx, "SELECT set_config('statement_timeout', $1, true)", []any{timeout}, nil)
+	}
+	if err != nil {
+		return fmt.Errorf("entzlog: setting statement timeout: %w", err)
+	}
+	d.timeout = timeout
+	return nil
+}
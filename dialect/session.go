@@ -0,0 +1,75 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"entgo.io/ent/dialect"
+	"go.uber.org/zap"
+)
+
+// SessionVar is a session variable set at the start of the transactions
+// of the driver. See WithSessionVars.
+type SessionVar struct {
+	// Name is the name of the variable, e.g. "app.current_tenant".
+	Name string
+	// Value returns the value of the variable for the context of the
+	// transaction. The variable is not set if it returns false.
+	Value func(ctx context.Context) (string, bool)
+}
+
+// TenantVar returns the session variable holding the tenant of the context.
+// See WithTenant.
+func TenantVar(name string) SessionVar {
+	return SessionVar{Name: name, Value: TenantFromContext}
+}
+
+// ActorVar returns the session variable holding the actor of the context.
+// See WithActor.
+func ActorVar(name string) SessionVar {
+	return SessionVar{Name: name, Value: ActorFromContext}
+}
+
+// WithSessionVars returns an option that sets the given variables at the start
+// of every transaction, from its context, as SET LOCAL does. Only Postgres
+// supports them, and the other dialects fail with ErrUnsupported.
+func WithSessionVars(vars ...SessionVar) Option {
+	return func(d *DebugDriver) {
+		d.sessionVars = append(d.sessionVars, vars...)
+	}
+}
+
+// setSession sets the session variables of the transaction. It is rolled
+// back if they cannot be set.
+func (d *DebugDriver) setSession(ctx context.Context, tx dialect.Tx, id string) error {
+	var (
+		exprs   []string
+		args    []any
+		applied []string
+	)
+	for _, v := range d.sessionVars {
+		value, ok := v.Value(ctx)
+		if !ok {
+			continue
+		}
+		exprs = append(exprs, fmt.Sprintf("set_config($%d, $%d, true)", len(args)+1, len(args)+2))
+		args = append(args, v.Name, value)
+		applied = append(applied, v.Name+"="+value)
+	}
+	if len(exprs) == 0 {
+		return nil
+	}
+	err := fmt.Errorf("%w: session variables on %s", ErrUnsupported, d.Dialect())
+	if d.Dialect() == dialect.Postgres {
+		err = tx.Exec(ctx, "SELECT "+strings.Join(exprs, ", "), args, nil)
+	}
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("entzlog: setting session variables: %w", err)
+	}
+	if d.logs(ctx) {
+		d.log(ctx, fmt.Sprintf("Tx(%s): session variables applied", id), zap.Strings("session_vars", applied))
+	}
+	return nil
+}
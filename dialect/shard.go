@@ -0,0 +1,214 @@
+package driver
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"slices"
+	"strconv"
+	"time"
+
+	"entgo.io/ent/dialect"
+)
+
+// ErrNoShardKey is returned by the ShardDriver when no shard key
+// can be extracted from the context.
+var ErrNoShardKey = errors.New("entzlog: no shard key in context")
+
+// ErrNoShards is returned by NewShardDriver when no shard is configured.
+var ErrNoShards = errors.New("entzlog: no shards")
+
+type (
+	shardKeyKey struct{}
+	shardIDKey  struct{}
+)
+
+// WithShardKey returns a context carrying the given shard key.
+func WithShardKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, shardKeyKey{}, key)
+}
+
+// ShardKeyFromContext returns the shard key stored in the context by WithShardKey.
+// It is the default ShardKeyFunc.
+func ShardKeyFromContext(ctx context.Context) (string, bool) {
+	key, ok := ctx.Value(shardKeyKey{}).(string)
+	return key, ok
+}
+
+// ShardFromContext returns the id of the shard the operation was routed to.
+// It is available to the drivers wrapped by the ShardDriver.
+func ShardFromContext(ctx context.Context) (int, bool) {
+	id, ok := ctx.Value(shardIDKey{}).(int)
+	return id, ok
+}
+
+// ShardKeyFunc extracts the shard key from the context.
+type ShardKeyFunc func(ctx context.Context) (string, bool)
+
+// ShardConfig configures a ShardDriver.
+type ShardConfig struct {
+	// Shards holds the shard drivers indexed by their shard id. Required.
+	Shards []Driver
+	// Key extracts the shard key. Defaults to ShardKeyFromContext.
+	Key ShardKeyFunc
+	// Shard maps a shard key to a shard id. Defaults to the FNV-1a
+	// hash of the key modulo the number of shards.
+	Shard func(key string) int
+	// Log is the log function used for all shard statements.
+	Log LogFunc
+	// Metrics receives the per-shard measurements. Optional.
+	Metrics Metrics
+	// Options configure the debug drivers of the shards, e.g. with hooks
+	// or sinks. Optional.
+	Options []Option
+}
+
+// ShardDriver is a driver that routes each operation to the shard resolved
+// from the context, and logs every statement with the shard id.
+type ShardDriver struct {
+	cfg    ShardConfig
+	shards []Driver // debugged shard drivers.
+}
+
+// NewShardDriver returns a new ShardDriver, or ErrNoShards if cfg has no shards.
+func NewShardDriver(cfg ShardConfig) (*ShardDriver, error) {
+	if len(cfg.Shards) == 0 {
+		return nil, ErrNoShards
+	}
+	if cfg.Key == nil {
+		cfg.Key = ShardKeyFromContext
+	}
+	if cfg.Shard == nil {
+		n := uint32(len(cfg.Shards))
+		cfg.Shard = func(key string) int {
+			h := fnv.New32a()
+			h.Write([]byte(key))
+			return int(h.Sum32() % n)
+		}
+	}
+	if cfg.Log == nil {
+		cfg.Log = nopLog
+	}
+	if cfg.Metrics == nil {
+		cfg.Metrics = nopMetrics{}
+	}
+	d := &ShardDriver{cfg: cfg}
+	for i, drv := range cfg.Shards {
+		opts := append(slices.Clip(cfg.Options), WithAttrs(Attr{"shard", strconv.Itoa(i)}))
+		d.shards = append(d.shards, DebugWithContext(drv, cfg.Log, opts...))
+	}
+	return d, nil
+}
+
+// Exec calls the Exec method of the shard driver.
+func (d *ShardDriver) Exec(ctx context.Context, query string, args, v any) error {
+	ctx, drv, err := d.resolve(ctx)
+	if err != nil {
+		return err
+	}
+	return d.observe(ctx, func() error {
+		return drv.Exec(ctx, query, args, v)
+	})
+}
+
+// Query calls the Query method of the shard driver.
+func (d *ShardDriver) Query(ctx context.Context, query string, args, v any) error {
+	ctx, drv, err := d.resolve(ctx)
+	if err != nil {
+		return err
+	}
+	return d.observe(ctx, func() error {
+		return drv.Query(ctx, query, args, v)
+	})
+}
+
+// ExecContext calls the ExecContext method of the shard driver.
+func (d *ShardDriver) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	ctx, drv, err := d.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var res sql.Result
+	err = d.observe(ctx, func() (err error) {
+		res, err = execContext(ctx, drv, query, args)
+		return err
+	})
+	return res, err
+}
+
+// QueryContext calls the QueryContext method of the shard driver.
+func (d *ShardDriver) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	ctx, drv, err := d.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var rows *sql.Rows
+	err = d.observe(ctx, func() (err error) {
+		rows, err = queryContext(ctx, drv, query, args)
+		return err
+	})
+	return rows, err
+}
+
+// Tx starts a transaction on the shard driver.
+func (d *ShardDriver) Tx(ctx context.Context) (dialect.Tx, error) {
+	ctx, drv, err := d.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return drv.Tx(ctx)
+}
+
+// BeginTx starts a transaction on the shard driver if it is supported.
+func (d *ShardDriver) BeginTx(ctx context.Context, opts *sql.TxOptions) (dialect.Tx, error) {
+	ctx, drv, err := d.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return beginTx(ctx, drv, opts)
+}
+
+// Dialect returns the dialect of the first shard.
+func (d *ShardDriver) Dialect() string {
+	return d.cfg.Shards[0].Dialect()
+}
+
+// Close closes all shard drivers.
+func (d *ShardDriver) Close() error {
+	var errs []error
+	for _, drv := range d.cfg.Shards {
+		errs = append(errs, drv.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// resolve returns the debugged driver of the shard resolved from the
+// context, and a context carrying the shard id.
+func (d *ShardDriver) resolve(ctx context.Context) (context.Context, Driver, error) {
+	key, ok := d.cfg.Key(ctx)
+	if !ok {
+		return ctx, nil, ErrNoShardKey
+	}
+	id := d.cfg.Shard(key)
+	if id < 0 || id >= len(d.shards) {
+		return ctx, nil, fmt.Errorf("entzlog: shard %d out of range [0, %d)", id, len(d.shards))
+	}
+	return context.WithValue(ctx, shardIDKey{}, id), d.shards[id], nil
+}
+
+// observe runs fn and records the per-shard measurements.
+func (d *ShardDriver) observe(ctx context.Context, fn func() error) error {
+	start := time.Now()
+	err := fn()
+	status := "ok"
+	if err != nil {
+		status = "error"
+	}
+	id, _ := ShardFromContext(ctx)
+	labels := []Label{{"shard", strconv.Itoa(id)}, {"status", status}}
+	d.cfg.Metrics.Count(ctx, "entzlog_shard_statements_total", 1, labels...)
+	d.cfg.Metrics.Observe(ctx, "entzlog_shard_statement_duration_seconds", time.Since(start), labels...)
+	return err
+}
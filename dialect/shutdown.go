@@ -0,0 +1,145 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ErrShutdown is the error the statements and transactions started after
+// Shutdown was called fail with.
+var ErrShutdown = errors.New("entzlog: driver shut down")
+
+// drain tracks the statements in flight and the open transactions of a
+// driver, to wait for them on Shutdown. The statements are only counted
+// until Shutdown is called, and registered with their details afterwards.
+type drain struct {
+	closing  atomic.Bool
+	inflight atomic.Int64        // statements in flight.
+	mu       sync.Mutex          // guards the fields below.
+	seq      uint64              // sequence number of the last registered statement.
+	running  map[uint64]*running // statements in flight registered after Shutdown, by sequence number.
+	open     map[string]*running // open transactions, by id.
+	drained  chan struct{}       // closed once nothing runs after Shutdown.
+	notified bool
+}
+
+// running is a statement in flight or an open transaction.
+type running struct {
+	txID, op, query string
+	start           time.Time
+}
+
+// enter counts a statement in flight, and returns the sequence number to pass
+// to leave. After Shutdown, only the statements of open transactions are accepted.
+func (d *DebugDriver) enter(txID, op, query string) (uint64, error) {
+	d.drain.inflight.Add(1)
+	if !d.drain.closing.Load() {
+		return 0, nil
+	}
+	d.drain.mu.Lock()
+	defer d.drain.mu.Unlock()
+	if txID == "" {
+		d.drain.inflight.Add(-1)
+		d.drain.notify()
+		return 0, ErrShutdown
+	}
+	d.drain.seq++
+	d.drain.running[d.drain.seq] = &running{txID: txID, op: op, query: query, start: time.Now()}
+	return d.drain.seq, nil
+}
+
+// leave uncounts a statement returned by enter.
+func (d *DebugDriver) leave(seq uint64) {
+	if left := d.drain.inflight.Add(-1); !d.drain.closing.Load() || left > 0 && seq == 0 {
+		return
+	}
+	d.drain.mu.Lock()
+	defer d.drain.mu.Unlock()
+	delete(d.drain.running, seq)
+	d.drain.notify()
+}
+
+// openTx registers an open transaction, until endTx is called.
+func (d *DebugDriver) openTx(txID string) {
+	d.drain.mu.Lock()
+	defer d.drain.mu.Unlock()
+	if d.drain.open == nil {
+		d.drain.open = make(map[string]*running)
+	}
+	d.drain.open[txID] = &running{txID: txID, start: time.Now()}
+}
+
+// closeTx unregisters an open transaction.
+func (d *DebugDriver) closeTx(txID string) {
+	d.drain.mu.Lock()
+	defer d.drain.mu.Unlock()
+	delete(d.drain.open, txID)
+	d.drain.notify()
+}
+
+// notify closes the drained channel once nothing runs after Shutdown.
+// It must be called with the lock held.
+func (dr *drain) notify() {
+	if dr.closing.Load() && !dr.notified && dr.inflight.Load() == 0 && len(dr.open) == 0 {
+		dr.notified = true
+		close(dr.drained)
+	}
+}
+
+// Shutdown stops the driver from accepting new statements and transactions,
+// waits for those running, or until ctx expires, and closes the driver.
+func (d *DebugDriver) Shutdown(ctx context.Context) error {
+	d.drain.mu.Lock()
+	if d.drain.closing.Load() {
+		d.drain.mu.Unlock()
+		return ErrShutdown
+	}
+	d.drain.running = make(map[uint64]*running)
+	d.drain.drained = make(chan struct{})
+	d.drain.closing.Store(true)
+	statements, transactions := d.drain.inflight.Load(), len(d.drain.open)
+	d.drain.notify()
+	d.drain.mu.Unlock()
+	d.log(ctx, "driver.Shutdown: draining", zap.Int64("statements", statements), zap.Int("transactions", transactions))
+	select {
+	case <-d.drain.drained:
+		return d.Close()
+	case <-ctx.Done():
+	}
+	d.drain.mu.Lock()
+	now := time.Now()
+	statements = d.drain.inflight.Load() - int64(len(d.drain.running))
+	stmts := make([]running, 0, len(d.drain.running))
+	for _, r := range d.drain.running {
+		stmts = append(stmts, *r)
+	}
+	open := make([]running, 0, len(d.drain.open))
+	for _, r := range d.drain.open {
+		open = append(open, *r)
+	}
+	d.drain.mu.Unlock()
+	if statements > 0 {
+		// The statements started before Shutdown are only counted.
+		d.log(ctx, "driver.Shutdown: statements still running", zap.Int64("statements", statements))
+	}
+	for _, r := range stmts {
+		d.log(ctx, d.opMsg(r.txID, r.op)+": still running on shutdown",
+			zap.Duration("running", now.Sub(r.start)),
+			zap.String("query", r.query),
+			zap.String("tx_id", r.txID),
+		)
+	}
+	for _, r := range open {
+		d.log(ctx, fmt.Sprintf("driver.Tx(%s): still open on shutdown", r.txID),
+			zap.Duration("open", now.Sub(r.start)),
+			zap.String("tx_id", r.txID),
+		)
+	}
+	return errors.Join(context.Cause(ctx), d.Close())
+}
@@ -0,0 +1,131 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"entgo.io/ent/dialect"
+	entsql "entgo.io/ent/dialect/sql"
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestShutdown(t *testing.T) {
+	tests := []struct {
+		name   string
+		expect func(sqlmock.Sqlmock)
+		// start runs before Shutdown, and returns the function finishing
+		// its work while the driver drains, if any.
+		start   func(context.Context, *DebugDriver) (func() error, error)
+		timeout time.Duration
+		wantErr error
+	}{
+		{
+			name: "idle",
+			expect: func(m sqlmock.Sqlmock) {
+				m.ExpectClose()
+			},
+			start: func(context.Context, *DebugDriver) (func() error, error) {
+				return nil, nil
+			},
+		},
+		{
+			name: "statement in flight",
+			expect: func(m sqlmock.Sqlmock) {
+				m.ExpectExec("UPDATE users").WillDelayFor(50 * time.Millisecond).WillReturnResult(sqlmock.NewResult(0, 1))
+				m.ExpectClose()
+			},
+			start: func(ctx context.Context, d *DebugDriver) (func() error, error) {
+				errc := make(chan error, 1)
+				go func() { errc <- d.Exec(ctx, "UPDATE users SET name = 'a8m'", []any{}, nil) }()
+				// Let the statement start before the driver is shut down.
+				for d.drain.inflight.Load() == 0 {
+					time.Sleep(time.Millisecond)
+				}
+				return func() error { return <-errc }, nil
+			},
+		},
+		{
+			name: "open transaction",
+			expect: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin()
+				m.ExpectExec("UPDATE users").WillReturnResult(sqlmock.NewResult(0, 1))
+				m.ExpectCommit()
+				m.ExpectClose()
+			},
+			start: func(ctx context.Context, d *DebugDriver) (func() error, error) {
+				tx, err := d.Tx(ctx)
+				if err != nil {
+					return nil, err
+				}
+				return func() error {
+					// The statements of the open transactions are still accepted.
+					if err := tx.Exec(ctx, "UPDATE users SET name = 'a8m'", []any{}, nil); err != nil {
+						return err
+					}
+					return tx.Commit()
+				}, nil
+			},
+		},
+		{
+			name: "expired",
+			expect: func(m sqlmock.Sqlmock) {
+				// The connection of the open transaction is not closed.
+				m.ExpectBegin()
+			},
+			start: func(ctx context.Context, d *DebugDriver) (func() error, error) {
+				_, err := d.Tx(ctx)
+				return nil, err
+			},
+			timeout: 10 * time.Millisecond,
+			wantErr: context.DeadlineExceeded,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.expect(mock)
+			drv := newDebugDriver(entsql.OpenDB(dialect.Postgres, db), nopLog)
+			ctx := context.Background()
+			finish, err := tt.start(ctx, drv)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sctx := ctx
+			if tt.timeout > 0 {
+				var cancel context.CancelFunc
+				sctx, cancel = context.WithTimeout(ctx, tt.timeout)
+				defer cancel()
+			}
+			errc := make(chan error, 1)
+			go func() { errc <- drv.Shutdown(sctx) }()
+			for !drv.drain.closing.Load() {
+				time.Sleep(time.Millisecond)
+			}
+			if err := drv.Exec(ctx, "DELETE FROM users", []any{}, nil); !errors.Is(err, ErrShutdown) {
+				t.Errorf("statement after shutdown: err = %v, want ErrShutdown", err)
+			}
+			if finish != nil {
+				if err := finish(); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := <-errc; !errors.Is(err, tt.wantErr) {
+				t.Errorf("Shutdown: err = %v, want %v", err, tt.wantErr)
+			}
+			if n := drv.drain.inflight.Load(); n != 0 {
+				t.Errorf("%d statements counted in flight after shutdown", n)
+			}
+			if err := drv.Shutdown(ctx); !errors.Is(err, ErrShutdown) {
+				t.Errorf("second Shutdown: err = %v, want ErrShutdown", err)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package driver
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+
+	entsql "entgo.io/ent/dialect/sql"
+	"go.uber.org/zap"
+)
+
+// SingleflightConfig configures a SingleflightDriver.
+type SingleflightConfig struct {
+	// Timeout bounds the shared executions, which are not canceled with the
+	// contexts of their callers. Defaults to 30 seconds.
+	Timeout time.Duration
+	// Log is the log function. Optional.
+	Log LogFunc
+	// Metrics receives the coalescing measurements. Optional.
+	Metrics Metrics
+}
+
+// SingleflightDriver is a driver that collapses identical read-only queries
+// executed concurrently outside of transactions into a single execution.
+type SingleflightDriver struct {
+	Driver // underlying driver.
+	cfg    SingleflightConfig
+	mu     sync.Mutex
+	calls  map[string]*flight
+}
+
+// flight is an in-flight or completed query execution.
+type flight struct {
+	done     chan struct{} // closed once executed.
+	res      *result
+	err      error
+	panicked any // panic of the execution, if any.
+	shared   int // number of coalesced callers.
+}
+
+// NewSingleflightDriver returns a new SingleflightDriver wrapping the given driver.
+func NewSingleflightDriver(d Driver, cfg SingleflightConfig) *SingleflightDriver {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 30 * time.Second
+	}
+	if cfg.Log == nil {
+		cfg.Log = nopLog
+	}
+	if cfg.Metrics == nil {
+		cfg.Metrics = nopMetrics{}
+	}
+	return &SingleflightDriver{Driver: d, cfg: cfg, calls: make(map[string]*flight)}
+}
+
+// Query coalesces identical read-only queries and calls the underlying driver Query method otherwise.
+func (d *SingleflightDriver) Query(ctx context.Context, query string, args, v any) error {
+	vr, ok := v.(*entsql.Rows)
+	if !ok || !isReadOnly(query) {
+		return d.Driver.Query(ctx, query, args, v)
+	}
+	rows, err := d.do(ctx, "singleflight.Query", query, args, func(ctx context.Context) (entsql.ColumnScanner, error) {
+		var rows entsql.Rows
+		if err := d.Driver.Query(ctx, query, args, &rows); err != nil {
+			return nil, err
+		}
+		return rows.ColumnScanner, nil
+	})
+	if err != nil {
+		return err
+	}
+	*vr = entsql.Rows{ColumnScanner: rows}
+	return nil
+}
+
+// QueryContext coalesces identical read-only queries and calls the underlying driver QueryContext method otherwise.
+func (d *SingleflightDriver) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	if !isReadOnly(query) {
+		return queryContext(ctx, d.Driver, query, args)
+	}
+	return d.do(ctx, "singleflight.QueryContext", query, args, func(ctx context.Context) (entsql.ColumnScanner, error) {
+		return queryContext(ctx, d.Driver, query, args)
+	})
+}
+
+// do executes the query with exec, unless an identical query is already in
+// flight, and waits for the result of the execution or for ctx to be done.
+func (d *SingleflightDriver) do(ctx context.Context, op, query string, args any, exec func(context.Context) (entsql.ColumnScanner, error)) (*sql.Rows, error) {
+	key := cacheKey(query, args)
+	d.mu.Lock()
+	f, ok := d.calls[key]
+	if ok {
+		f.shared++
+	} else {
+		f = &flight{done: make(chan struct{})}
+		d.calls[key] = f
+		go d.exec(context.WithoutCancel(ctx), op, query, key, f, exec)
+	}
+	d.mu.Unlock()
+	select {
+	case <-f.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if f.panicked != nil {
+		panic(f.panicked)
+	}
+	if f.err != nil {
+		return nil, f.err
+	}
+	return f.res.rows(ctx)
+}
+
+// exec executes the flight with its own timeout, and releases its callers.
+func (d *SingleflightDriver) exec(ctx context.Context, op, query, key string, f *flight, exec func(context.Context) (entsql.ColumnScanner, error)) {
+	defer func() {
+		f.panicked = recover()
+		d.mu.Lock()
+		delete(d.calls, key)
+		shared := f.shared
+		d.mu.Unlock()
+		close(f.done)
+		if shared > 0 {
+			d.cfg.Log(ctx, op, zap.String("query", query), zap.Int("coalesced", shared))
+			d.cfg.Metrics.Count(ctx, "entzlog_singleflight_coalesced_total", float64(shared))
+		}
+	}()
+	ectx, cancel := context.WithTimeout(ctx, d.cfg.Timeout)
+	defer cancel()
+	rs, err := exec(ectx)
+	if err == nil {
+		f.res, err = materialize(rs)
+	}
+	f.err = err
+}
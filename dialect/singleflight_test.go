@@ -0,0 +1,116 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"entgo.io/ent/dialect"
+	entsql "entgo.io/ent/dialect/sql"
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestSingleflightDriver(t *testing.T) {
+	tests := []struct {
+		name    string
+		leader  func(context.Context) (context.Context, context.CancelFunc)
+		waiter  func(context.Context) (context.Context, context.CancelFunc)
+		wantErr [2]error // errors of the leader and the waiter.
+	}{
+		{
+			name:   "shared",
+			leader: context.WithCancel,
+			waiter: context.WithCancel,
+		},
+		{
+			name: "leader canceled",
+			leader: func(ctx context.Context) (context.Context, context.CancelFunc) {
+				return context.WithTimeout(ctx, 10*time.Millisecond)
+			},
+			waiter:  context.WithCancel,
+			wantErr: [2]error{context.DeadlineExceeded, nil},
+		},
+		{
+			name:   "waiter canceled",
+			leader: context.WithCancel,
+			waiter: func(ctx context.Context) (context.Context, context.CancelFunc) {
+				return context.WithTimeout(ctx, 10*time.Millisecond)
+			},
+			wantErr: [2]error{nil, context.DeadlineExceeded},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			mock.ExpectQuery("SELECT name FROM users").
+				WillDelayFor(100 * time.Millisecond).
+				WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("a8m"))
+			drv := NewSingleflightDriver(entsql.OpenDB(dialect.Postgres, db), SingleflightConfig{})
+			ctxs := [2]func(context.Context) (context.Context, context.CancelFunc){tt.leader, tt.waiter}
+			var (
+				wg    sync.WaitGroup
+				errs  [2]error
+				names [2]string
+				took  [2]time.Duration
+			)
+			for i, with := range ctxs {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					ctx, cancel := with(context.Background())
+					defer cancel()
+					start := time.Now()
+					defer func() { took[i] = time.Since(start) }()
+					var rows entsql.Rows
+					if errs[i] = drv.Query(ctx, "SELECT name FROM users", []any{}, &rows); errs[i] != nil {
+						return
+					}
+					defer rows.Close()
+					for rows.Next() {
+						errs[i] = rows.Scan(&names[i])
+					}
+				}()
+				// Let the leader start the execution.
+				time.Sleep(5 * time.Millisecond)
+			}
+			wg.Wait()
+			for i, want := range tt.wantErr {
+				switch {
+				case !errors.Is(errs[i], want):
+					t.Errorf("caller %d: err = %v, want %v", i, errs[i], want)
+				case want == nil && names[i] != "a8m":
+					t.Errorf("caller %d: name = %q, want a8m", i, names[i])
+				}
+			}
+			if tt.wantErr[1] != nil && took[1] > 50*time.Millisecond {
+				t.Error("canceled waiter kept waiting for the execution")
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestSingleflightDriverPanic(t *testing.T) {
+	drv := NewSingleflightDriver(panicDriver{}, SingleflightConfig{})
+	defer func() {
+		if r := recover(); r != "boom" {
+			t.Errorf("recovered %v, want boom", r)
+		}
+	}()
+	var rows entsql.Rows
+	drv.Query(context.Background(), "SELECT 1", []any{}, &rows)
+	t.Error("panic was not raised in the caller")
+}
+
+// panicDriver is a driver whose queries panic.
+type panicDriver struct{ Driver }
+
+func (panicDriver) Query(context.Context, string, any, any) error { panic("boom") }
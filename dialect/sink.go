@@ -0,0 +1,240 @@
+package driver
+
+import (
+	"context"
+	"errors"
+
+	"go.uber.org/zap"
+)
+
+// Tee returns a sink writing the events to all the given sinks. Errors
+// of the sinks are joined, and do not prevent the other sinks from
+// receiving the events.
+func Tee(sinks ...Sink) Sink {
+	return teeSink(sinks)
+}
+
+// teeSink is the Sink returned by Tee.
+type teeSink []Sink
+
+// Write writes the event to all sinks.
+func (t teeSink) Write(ctx context.Context, e *Event) error {
+	var errs []error
+	for _, s := range t {
+		errs = append(errs, s.Write(ctx, e))
+	}
+	return errors.Join(errs...)
+}
+
+// Close closes all sinks.
+func (t teeSink) Close() error {
+	var errs []error
+	for _, s := range t {
+		errs = append(errs, s.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// FilterSink returns a sink writing to s the events for which fn returns true.
+func FilterSink(s Sink, fn func(*Event) bool) Sink {
+	return &filterSink{Sink: s, fn: fn}
+}
+
+// filterSink is the Sink returned by FilterSink.
+type filterSink struct {
+	Sink
+	fn func(*Event) bool
+}
+
+// Write writes the event to the underlying sink if it is selected by the filter.
+func (f *filterSink) Write(ctx context.Context, e *Event) error {
+	if !f.fn(e) {
+		return nil
+	}
+	return f.Sink.Write(ctx, e)
+}
+
+// LogSink returns a sink writing the events as log entries, for example
+// to log only the slow and failed statements with a dedicated logger:
+//
+//	driver.FilterSink(driver.LogSink(log), func(e *driver.Event) bool {
+//		return e.Slow || e.Err != nil
+//	})
+func LogSink(log LogFunc) Sink {
+	return logSink(log)
+}
+
+// logSink is the Sink returned by LogSink.
+type logSink LogFunc
+
+// Write logs the event.
+func (l logSink) Write(ctx context.Context, e *Event) error {
+	fields := []zap.Field{
+		zap.String("dialect", e.Dialect),
+		zap.String("status", e.Status()),
+		zap.Duration("duration", e.Duration),
+	}
+	if e.Query != "" {
+		fields = append(fields, zap.String("query", e.Query), argsField(e.Args), LazyString("fingerprint", e.Fingerprint))
+	}
+	if e.TxID != "" {
+		fields = append(fields, zap.String("tx_id", e.TxID))
+	}
+	if e.Tenant != "" {
+		fields = append(fields, zap.String("tenant", e.Tenant))
+	}
+	if e.Rows >= 0 {
+		fields = append(fields, zap.Int64("rows", e.Rows))
+	}
+	if e.Err != nil {
+		fields = append(fields, zap.Error(e.Err), zap.String("error_class", string(e.ErrorClass)))
+	}
+	if e.Failures > 0 {
+		fields = append(fields, zap.Int64("failures", e.Failures))
+	}
+	for _, a := range e.attrs() {
+		fields = append(fields, zap.String(a.Key, a.Value))
+	}
+	l(ctx, "driver."+e.Op, fields...)
+	return nil
+}
+
+// Close implements the Sink interface.
+func (logSink) Close() error { return nil }
+
+// MetricsSink returns a sink recording the events as metrics: the
+// entzlog_operations_total counter and the entzlog_operation_duration_seconds
+// histogram, labeled by dialect, op, status, stmt_type, table, db_host and
+// db_name.
+func MetricsSink(m Metrics) Sink {
+	return NewMetricsSink(m, MetricsSinkConfig{})
+}
+
+// Labels of the metrics recorded by the sinks returned by NewMetricsSink.
+const (
+	LabelDialect     = "dialect"
+	LabelOp          = "op"
+	LabelStatus      = "status"
+	LabelStmtType    = "stmt_type"
+	LabelTable       = "table"
+	LabelFingerprint = "fingerprint"
+	LabelTenant      = "tenant"
+	LabelDBHost      = AttrDBHost
+	LabelDBName      = AttrDBName
+)
+
+// MetricsSinkConfig configures the sinks returned by NewMetricsSink.
+type MetricsSinkConfig struct {
+	// Labels are the labels of the metrics, among the Label constants.
+	// Defaults to all labels but fingerprint and tenant, which are usually
+	// of high cardinality.
+	Labels []string
+	// MaxValues is the maximum number of distinct values of each label. The
+	// values seen once it is reached are recorded as "other". Defaults to no
+	// limit. See LimitCardinality.
+	MaxValues int
+	// Tenants, if set, bounds the values of the tenant label to these
+	// tenants. The operations of the other tenants are recorded as "other".
+	Tenants []string
+	// TopTenants, if set, bounds the values of the tenant label to the given
+	// number of tenants with the most operations. The tenants are ranked every
+	// 16 operations per bounded tenant, until that many are recorded, and the
+	// operations of the others, or before their ranking, are recorded as "other".
+	TopTenants int
+	// TopFingerprints, if set, bounds the values of the fingerprint label to
+	// the given number of fingerprints with the most operations, tracked like
+	// TopTenants, to build per-query dashboards without unbounded series.
+	TopFingerprints int
+}
+
+// NewMetricsSink returns a sink recording the events as metrics, like
+// MetricsSink, with the labels configured by cfg:
+//
+//	driver.NewMetricsSink(m, driver.MetricsSinkConfig{TopTenants: 20, TopFingerprints: 50})
+func NewMetricsSink(m Metrics, cfg MetricsSinkConfig) Sink {
+	if cfg.Labels == nil {
+		cfg.Labels = []string{LabelDialect, LabelOp, LabelStatus, LabelStmtType, LabelTable, LabelDBHost, LabelDBName}
+		if cfg.Tenants != nil || cfg.TopTenants > 0 {
+			cfg.Labels = append(cfg.Labels, LabelTenant)
+		}
+		if cfg.TopFingerprints > 0 {
+			cfg.Labels = append(cfg.Labels, LabelFingerprint)
+		}
+	}
+	if cfg.MaxValues > 0 {
+		m = LimitCardinality(m, cfg.MaxValues)
+	}
+	s := metricsSink{m: m, labels: cfg.Labels, bounds: make(map[string]func(string) string)}
+	switch {
+	case cfg.Tenants != nil:
+		allowed := make(map[string]bool, len(cfg.Tenants))
+		for _, t := range cfg.Tenants {
+			allowed[t] = true
+		}
+		s.bounds[LabelTenant] = func(t string) string {
+			if t == "" || allowed[t] {
+				return t
+			}
+			return "other"
+		}
+	case cfg.TopTenants > 0:
+		s.bounds[LabelTenant] = newTopK(cfg.TopTenants).label
+	}
+	if cfg.TopFingerprints > 0 {
+		s.bounds[LabelFingerprint] = newTopK(cfg.TopFingerprints).label
+	}
+	return s
+}
+
+// metricsSink is the Sink returned by NewMetricsSink.
+type metricsSink struct {
+	m      Metrics
+	labels []string
+	bounds map[string]func(string) string // bounds the values of the labels, by name.
+}
+
+// Write records the event.
+func (s metricsSink) Write(ctx context.Context, e *Event) error {
+	if e.Op == OpDegraded || e.Op == OpRecovered {
+		return nil
+	}
+	labels := make([]Label, len(s.labels))
+	for i, name := range s.labels {
+		labels[i] = Label{name, eventLabel(e, name)}
+		if bound, ok := s.bounds[name]; ok {
+			labels[i].Value = bound(labels[i].Value)
+		}
+	}
+	s.m.Count(ctx, "entzlog_operations_total", 1, labels...)
+	s.m.Observe(ctx, "entzlog_operation_duration_seconds", e.Duration, labels...)
+	return nil
+}
+
+// eventLabel returns the value of the label of the event.
+func eventLabel(e *Event, name string) string {
+	switch name {
+	case LabelDialect:
+		return e.Dialect
+	case LabelOp:
+		return e.Op
+	case LabelStatus:
+		return e.Status()
+	case LabelStmtType:
+		return StatementType(e.Query)
+	case LabelTable:
+		return StatementTable(e.Query)
+	case LabelFingerprint:
+		return e.Fingerprint()
+	case LabelTenant:
+		return e.Tenant
+	}
+	for _, a := range e.Attrs {
+		if a.Key == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// Close implements the Sink interface.
+func (metricsSink) Close() error { return nil }
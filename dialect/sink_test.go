@@ -0,0 +1,75 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordMetrics is a Metrics recording the counted label values.
+type recordMetrics struct {
+	mu     sync.Mutex
+	counts map[string]float64 // counts by name and labels.
+}
+
+func (m *recordMetrics) Count(_ context.Context, name string, delta float64, labels ...Label) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.counts == nil {
+		m.counts = make(map[string]float64)
+	}
+	m.counts[fmt.Sprint(name, labels)] += delta
+}
+
+func (*recordMetrics) Gauge(context.Context, string, float64, ...Label)         {}
+func (*recordMetrics) Observe(context.Context, string, time.Duration, ...Label) {}
+
+func TestMetricsSinkTenants(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  MetricsSinkConfig
+		want map[string]float64
+	}{
+		{
+			name: "allowlist",
+			cfg:  MetricsSinkConfig{Labels: []string{LabelTenant}, Tenants: []string{"busy"}},
+			want: map[string]float64{"busy": 60, "other": 10},
+		},
+		{
+			name: "top",
+			cfg:  MetricsSinkConfig{Labels: []string{LabelTenant}, TopTenants: 1},
+			// The first ranking is done on the 16th operation, the 6th of busy.
+			want: map[string]float64{"busy": 60 - 5, "other": 10 + 5},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &recordMetrics{}
+			s := NewMetricsSink(m, tt.cfg)
+			// The rare tenants come first, and must not take the label of the busy one.
+			var tenants []string
+			for i := 0; i < 10; i++ {
+				tenants = append(tenants, fmt.Sprint("rare", i))
+			}
+			for i := 0; i < 60; i++ {
+				tenants = append(tenants, "busy")
+			}
+			for _, tenant := range tenants {
+				if err := s.Write(context.Background(), &Event{Op: "driver.Exec", Tenant: tenant}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for tenant, n := range tt.want {
+				key := fmt.Sprint("entzlog_operations_total", []Label{{LabelTenant, tenant}})
+				if got := m.counts[key]; got != n {
+					t.Errorf("%s operations = %v, want %v", tenant, got, n)
+				}
+			}
+			if len(m.counts) != len(tt.want) {
+				t.Errorf("recorded %v, want %d series", m.counts, len(tt.want))
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package driver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// SlackConfig configures a SlackSink.
+type SlackConfig struct {
+	// WebhookURL is the Slack incoming webhook URL. Required.
+	WebhookURL string
+	// Client is the HTTP client used to post the messages. Defaults to a
+	// client with a 10s timeout.
+	Client *http.Client
+	// Threshold is the minimum duration of the reported statements. Defaults
+	// to reporting the statements exceeding the slow threshold of the driver.
+	Threshold time.Duration
+	// Dedup is the period during which a statement with the same fingerprint
+	// is reported at most once. Defaults to 1h.
+	Dedup time.Duration
+	// DailyCap is the maximum number of messages posted per day (UTC).
+	// Defaults to 100.
+	DailyCap int
+}
+
+// SlackSink is a Sink that notifies a Slack channel of slow statements and
+// of degradation events. Messages are posted in the background, and are
+// dropped if Slack cannot keep up. The dropped messages, and those whose post
+// failed, do not count towards the daily cap and the deduplication.
+type SlackSink struct {
+	cfg    SlackConfig
+	mu     sync.Mutex
+	seen   map[string]time.Time // last message time by fingerprint.
+	day    string               // current day of the cap.
+	sent   int                  // messages sent or queued today.
+	err    error                // last post error, returned by Write.
+	closed bool                 // whether Close was called.
+	msgs   chan slackMessage
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+// slackMessage is a queued message of a SlackSink.
+type slackMessage struct {
+	key, text string
+	time      time.Time
+}
+
+// NewSlackSink returns a new SlackSink and starts its sender.
+func NewSlackSink(cfg SlackConfig) *SlackSink {
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	if cfg.Dedup <= 0 {
+		cfg.Dedup = time.Hour
+	}
+	if cfg.DailyCap <= 0 {
+		cfg.DailyCap = 100
+	}
+	s := &SlackSink{
+		cfg:  cfg,
+		seen: make(map[string]time.Time),
+		msgs: make(chan slackMessage, 16),
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	go s.loop()
+	return s
+}
+
+// Write queues a message for slow statements and degradation events.
+// It returns the error of the last failed post, if any, so that it gets logged.
+func (s *SlackSink) Write(_ context.Context, e *Event) error {
+	var key, text string
+	switch {
+	case e.Op == OpDegraded:
+		key, text = e.Op, fmt.Sprintf(":rotating_light: %s database degraded after %d consecutive failures: %v", e.Dialect, e.Failures, e.Err)
+	case e.Op == OpRecovered:
+		key, text = e.Op, fmt.Sprintf(":white_check_mark: %s database recovered after %d consecutive failures", e.Dialect, e.Failures)
+	case e.Query != "" && (s.cfg.Threshold > 0 && e.Duration >= s.cfg.Threshold || s.cfg.Threshold <= 0 && e.Slow):
+		key = e.Fingerprint()
+		text = fmt.Sprintf(":turtle: slow %s statement `%s` took %s\n```%s```", e.Dialect, key, e.Duration.Round(time.Millisecond), SanitizeQuery(e.Query))
+	default:
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed || !s.allow(key, e.Time) {
+		return nil
+	}
+	select {
+	case s.msgs <- slackMessage{key: key, text: text, time: e.Time}:
+		s.sent++
+		s.seen[key] = e.Time
+	default:
+	}
+	err := s.err
+	s.err = nil
+	return err
+}
+
+// allow reports whether a message with the given key can be sent at t.
+// It must be called with the lock held.
+func (s *SlackSink) allow(key string, t time.Time) bool {
+	if last, ok := s.seen[key]; ok && t.Sub(last) < s.cfg.Dedup {
+		return false
+	}
+	if day := t.UTC().Format(time.DateOnly); day != s.day {
+		s.day, s.sent = day, 0
+		clear(s.seen)
+	}
+	return s.sent < s.cfg.DailyCap
+}
+
+// Close posts the queued messages and stops the sender. The messages
+// written afterwards are dropped.
+func (s *SlackSink) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.mu.Unlock()
+	close(s.stop)
+	<-s.done
+	return nil
+}
+
+// loop posts the queued messages until the sink is closed, and then
+// the messages still queued.
+func (s *SlackSink) loop() {
+	defer close(s.done)
+	for {
+		select {
+		case m := <-s.msgs:
+			s.post(m)
+		case <-s.stop:
+			for {
+				select {
+				case m := <-s.msgs:
+					s.post(m)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// post posts the message, and releases its slot of the daily cap and of
+// the deduplication if it failed.
+func (s *SlackSink) post(m slackMessage) {
+	body, err := json.Marshal(map[string]string{"text": m.text})
+	if err == nil {
+		var resp *http.Response
+		resp, err = s.cfg.Client.Post(s.cfg.WebhookURL, "application/json", bytes.NewReader(body))
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode >= 300 {
+				err = fmt.Errorf("unexpected status %s", resp.Status)
+			}
+		}
+	}
+	if err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = fmt.Errorf("entzlog: posting to slack: %w", err)
+	if s.day == m.time.UTC().Format(time.DateOnly) && s.sent > 0 {
+		s.sent--
+	}
+	if s.seen[m.key].Equal(m.time) {
+		delete(s.seen, m.key)
+	}
+}
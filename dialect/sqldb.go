@@ -0,0 +1,45 @@
+package driver
+
+import (
+	"database/sql"
+	"database/sql/driver"
+
+	entsql "entgo.io/ent/dialect/sql"
+)
+
+// Open opens a database with database/sql and returns a debugged-driver for
+// it, logging with the host and database name of the source. See WithDSN.
+func Open(dialectName, source string, logger LogFunc, opts ...Option) (*DebugDriver, error) {
+	db, err := sql.Open(dialectName, source)
+	if err != nil {
+		return nil, err
+	}
+	return OpenDB(dialectName, db, logger, append([]Option{WithDSN(source)}, opts...)...), nil
+}
+
+// OpenDB returns a debugged-driver for the given *sql.DB. See Open for details.
+func OpenDB(dialectName string, db *sql.DB, logger LogFunc, opts ...Option) *DebugDriver {
+	return newDebugDriver(entsql.OpenDB(dialectName, db), logger, opts...)
+}
+
+// OpenConnector returns a debugged-driver for a database opened from the
+// given driver.Connector. See Open for details.
+func OpenConnector(dialectName string, c driver.Connector, logger LogFunc, opts ...Option) *DebugDriver {
+	return OpenDB(dialectName, sql.OpenDB(c), logger, opts...)
+}
+
+// DB returns the *sql.DB of the underlying driver chain, or nil if none
+// exposes one. See As.
+func (d *DebugDriver) DB() *sql.DB {
+	return sqlDB(d.Driver)
+}
+
+// sqlDB returns the *sql.DB exposed by the first driver of the chain of
+// drv that has a DB method, if any.
+func sqlDB(drv any) *sql.DB {
+	var db interface{ DB() *sql.DB }
+	if drv == nil || !As(drv, &db) {
+		return nil
+	}
+	return db.DB()
+}
@@ -0,0 +1,49 @@
+package driver
+
+import (
+	"testing"
+
+	"entgo.io/ent/dialect"
+	entsql "entgo.io/ent/dialect/sql"
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestDebugDriverDB(t *testing.T) {
+	db, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	drv := entsql.OpenDB(dialect.Postgres, db)
+	tests := []struct {
+		name string
+		drv  Driver
+	}{
+		{"sql", drv},
+		{"replica", NewReplicaDriver(drv, nil, ReplicaConfig{})},
+		{"cache", NewCacheDriver(drv, CacheConfig{})},
+		{"memo", NewMemoDriver(drv, MemoConfig{})},
+		{"batch", NewBatchDriver(drv, BatchConfig{})},
+		{"singleflight", NewSingleflightDriver(drv, SingleflightConfig{})},
+		{"stmtcache", NewStmtCacheDriver(NewCacheDriver(drv, CacheConfig{}), StmtCacheConfig{})},
+		{"debug", newDebugDriver(NewCacheDriver(drv, CacheConfig{}), nopLog)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newDebugDriver(tt.drv, nopLog).DB(); got != db {
+				t.Errorf("DB() = %p, want %p", got, db)
+			}
+		})
+	}
+	if got := newDebugDriver(nopDriver{}, nopLog).DB(); got != nil {
+		t.Errorf("DB() = %p, want nil", got)
+	}
+	if cached := NewStmtCacheDriver(NewMemoDriver(drv, MemoConfig{}), StmtCacheConfig{}); cached.db != db {
+		t.Error("statement cache did not find the database behind a wrapper")
+	}
+}
+
+// nopDriver is a driver without a database.
+type nopDriver struct{ Driver }
+
+func (nopDriver) Dialect() string { return dialect.Postgres }
@@ -0,0 +1,162 @@
+package driver
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// SQLiteConfig configures a SQLiteSink.
+type SQLiteConfig struct {
+	// Table is the name of the events table. Defaults to "entzlog_events".
+	Table string
+	// Filter selects the stored events. Defaults to the statements,
+	// i.e. transaction events are skipped.
+	Filter func(*Event) bool
+	// Interval is the time between two batches of inserts. Defaults to 1s.
+	Interval time.Duration
+	// BatchSize is the maximum number of events inserted by a transaction.
+	// Defaults to 500.
+	BatchSize int
+	// MaxPending is the maximum number of events waiting to be stored.
+	// Events exceeding it are dropped. Defaults to 10000.
+	MaxPending int
+}
+
+// SQLiteSink is a Sink that stores the events in a local SQLite database,
+// indexed by fingerprint and time, for forensic analysis without an
+// observability stack. Events are inserted in batches in the background.
+type SQLiteSink struct {
+	db  *sql.DB
+	cfg SQLiteConfig
+	b   *batcher
+}
+
+// NewSQLiteSink creates the events table and its indexes if needed, and
+// returns a new SQLiteSink storing the events in it. The database must be
+// opened with a SQLite driver (e.g. mattn/go-sqlite3 or modernc.org/sqlite),
+// and must not be instrumented by the driver the sink is attached to.
+func NewSQLiteSink(ctx context.Context, db *sql.DB, cfg SQLiteConfig) (*SQLiteSink, error) {
+	if cfg.Table == "" {
+		cfg.Table = "entzlog_events"
+	}
+	if cfg.Filter == nil {
+		cfg.Filter = func(e *Event) bool { return e.Query != "" }
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Second
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 500
+	}
+	if cfg.MaxPending <= 0 {
+		cfg.MaxPending = 10000
+	}
+	for _, stmt := range []string{
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %q (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	time INTEGER NOT NULL,
+	dialect TEXT NOT NULL,
+	op TEXT NOT NULL,
+	tx_id TEXT,
+	tenant TEXT,
+	fingerprint TEXT,
+	query TEXT,
+	duration_ns INTEGER NOT NULL,
+	rows INTEGER,
+	status TEXT NOT NULL,
+	error TEXT,
+	error_class TEXT
+)`, cfg.Table),
+		fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %q ON %q (fingerprint, time)`, cfg.Table+"_fingerprint", cfg.Table),
+		fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %q ON %q (time)`, cfg.Table+"_time", cfg.Table),
+	} {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			return nil, fmt.Errorf("entzlog: creating events table: %w", err)
+		}
+	}
+	s := &SQLiteSink{db: db, cfg: cfg}
+	s.b = newBatcher(cfg.Interval, cfg.BatchSize, cfg.MaxPending, s.insert)
+	return s, nil
+}
+
+// Write queues the event if it is selected by the filter.
+func (s *SQLiteSink) Write(_ context.Context, e *Event) error {
+	if !s.cfg.Filter(e) {
+		return nil
+	}
+	return s.b.add(e)
+}
+
+// Close stores the pending events. The database is not closed.
+func (s *SQLiteSink) Close() error {
+	return s.b.close()
+}
+
+// insert stores the events in a single transaction.
+func (s *SQLiteSink) insert(events []*Event) error {
+	ctx := context.Background()
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	stmt, err := tx.PrepareContext(ctx, fmt.Sprintf(`INSERT INTO %q
+	(time, dialect, op, tx_id, tenant, fingerprint, query, duration_ns, rows, status, error, error_class)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`, s.cfg.Table))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, e := range events {
+		var (
+			rows    = sql.NullInt64{Int64: e.Rows, Valid: e.Rows >= 0}
+			errText sql.NullString
+		)
+		if e.Err != nil {
+			errText = sql.NullString{String: e.Err.Error(), Valid: true}
+		}
+		if _, err := stmt.ExecContext(ctx, e.Time.UnixNano(), e.Dialect, e.Op, e.TxID, e.Tenant, e.Fingerprint(),
+			e.Query, int64(e.Duration), rows, e.Status(), errText, string(e.ErrorClass)); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// SlowQuery is an entry of the report returned by TopSlow.
+type SlowQuery struct {
+	Fingerprint string
+	Query       string // query of the slowest execution.
+	Count       int64
+	Errors      int64
+	Avg, Max    time.Duration
+}
+
+// TopSlow returns the limit statements with the highest maximum duration
+// since the given time, grouped by fingerprint.
+func (s *SQLiteSink) TopSlow(ctx context.Context, since time.Time, limit int) ([]SlowQuery, error) {
+	// SQLite returns the bare columns of the row holding the MAX aggregate.
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf(`SELECT fingerprint, query, COUNT(*), SUM(status NOT IN ('ok', 'slow')),
+	CAST(AVG(duration_ns) AS INTEGER), MAX(duration_ns)
+	FROM %q WHERE time >= ? AND fingerprint <> ''
+	GROUP BY fingerprint ORDER BY MAX(duration_ns) DESC LIMIT ?`, s.cfg.Table), since.UnixNano(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var top []SlowQuery
+	for rows.Next() {
+		var (
+			q        SlowQuery
+			avg, max int64
+		)
+		if err := rows.Scan(&q.Fingerprint, &q.Query, &q.Count, &q.Errors, &avg, &max); err != nil {
+			return nil, err
+		}
+		q.Avg, q.Max = time.Duration(avg), time.Duration(max)
+		top = append(top, q)
+	}
+	return top, rows.Err()
+}
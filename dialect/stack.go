@@ -0,0 +1,50 @@
+package driver
+
+import (
+	"runtime"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// WithStackTrace returns an option that logs the application frames of the
+// call stack with the failed and slow operations. See WithCaller.
+func WithStackTrace(skip ...string) Option {
+	return func(d *DebugDriver) {
+		d.stackSkip = append(append([]string{}, callerSkip...), skip...)
+	}
+}
+
+// withStack appends the stack field of the application frames of the
+// call stack to the fields, if the stack traces are configured.
+func (d *DebugDriver) withStack(fields []zap.Field) []zap.Field {
+	if d.stackSkip == nil {
+		return fields
+	}
+	var (
+		b   strings.Builder
+		pcs [64]uintptr
+	)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs[:])])
+	for {
+		f, more := frames.Next()
+		if f.Function != "" && !hasPrefix(f.Function, d.stackSkip) {
+			if b.Len() > 0 {
+				b.WriteByte('\n')
+			}
+			b.WriteString(f.Function)
+			b.WriteByte(' ')
+			b.WriteString(f.File)
+			b.WriteByte(':')
+			b.WriteString(strconv.Itoa(f.Line))
+		}
+		if !more {
+			break
+		}
+	}
+	if b.Len() == 0 {
+		return fields
+	}
+	return append(fields, zap.String("stack", b.String()))
+}
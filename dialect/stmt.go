@@ -0,0 +1,144 @@
+package driver
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"entgo.io/ent/dialect"
+	entsql "entgo.io/ent/dialect/sql"
+)
+
+// DebugStmt is a prepared statement that logs all its executions.
+// The underlying *sql.Stmt is embedded and can be used directly
+// to bypass the logging.
+type DebugStmt struct {
+	*sql.Stmt                     // underlying statement.
+	id        string              // statement logging id.
+	query     string              // prepared query.
+	log       LogFunc             // log function.
+	drv       *DebugDriver        // driver that prepared the statement.
+	txID      string              // id of the transaction that prepared the statement, if any.
+	ex        dialect.ExecQuerier // driver or transaction that prepared the statement.
+}
+
+// Exec logs its params and calls the underlying statement ExecContext method with a background context.
+func (s *DebugStmt) Exec(args ...any) (sql.Result, error) {
+	return s.ExecContext(context.Background(), args...)
+}
+
+// ExecContext logs its params and calls the underlying statement ExecContext method.
+func (s *DebugStmt) ExecContext(ctx context.Context, args ...any) (res sql.Result, err error) {
+	const op = "Stmt.ExecContext"
+	seq, err := s.drv.enter(s.txID, op, s.query)
+	if err != nil {
+		return nil, err
+	}
+	defer s.drv.leave(seq)
+	s.drv.statements.Add(1)
+	if err := s.drv.canceled(ctx, s.txID, op, s.query); err != nil {
+		return nil, err
+	}
+	if s.drv.logs(ctx) {
+		s.drv.logStmt(ctx, fmt.Sprintf("Stmt(%s).ExecContext: query=%v", s.id, s.query), s.query, args)
+	}
+	err = s.drv.run(ctx, s.ex, s.txID, op, s.query, args, &res, func(ctx context.Context) (err error) {
+		res, err = s.Stmt.ExecContext(ctx, args...)
+		return err
+	})
+	return res, s.drv.logInsertID(ctx, s.txID, op, s.query, res, err)
+}
+
+// Query logs its params and calls the underlying statement QueryContext method with a background context.
+func (s *DebugStmt) Query(args ...any) (*sql.Rows, error) {
+	return s.QueryContext(context.Background(), args...)
+}
+
+// QueryContext logs its params and calls the underlying statement QueryContext method.
+func (s *DebugStmt) QueryContext(ctx context.Context, args ...any) (rows *sql.Rows, err error) {
+	const op = "Stmt.QueryContext"
+	seq, err := s.drv.enter(s.txID, op, s.query)
+	if err != nil {
+		return nil, err
+	}
+	defer s.drv.leave(seq)
+	s.drv.statements.Add(1)
+	if err := s.drv.canceled(ctx, s.txID, op, s.query); err != nil {
+		return nil, err
+	}
+	if s.drv.logs(ctx) {
+		s.drv.logStmt(ctx, fmt.Sprintf("Stmt(%s).QueryContext: query=%v", s.id, s.query), s.query, args)
+	}
+	err = s.drv.run(ctx, s.ex, s.txID, op, s.query, args, nil, func(ctx context.Context) (err error) {
+		rows, err = s.Stmt.QueryContext(ctx, args...)
+		return s.drv.access(ctx, s.txID, s.query, nil, err)
+	})
+	return rows, err
+}
+
+// QueryRow logs its params and calls the underlying statement QueryRowContext method with a background context.
+func (s *DebugStmt) QueryRow(args ...any) *sql.Row {
+	return s.QueryRowContext(context.Background(), args...)
+}
+
+// QueryRowContext logs its params and calls the underlying statement QueryRowContext method.
+// Once the driver is shut down, the statements prepared outside of transactions return a row
+// failing with context.Canceled, as a *sql.Row cannot carry ErrShutdown.
+func (s *DebugStmt) QueryRowContext(ctx context.Context, args ...any) (row *sql.Row) {
+	const op = "Stmt.QueryRowContext"
+	seq, err := s.drv.enter(s.txID, op, s.query)
+	if err != nil {
+		ctx, cancel := context.WithCancel(ctx)
+		cancel()
+		return s.Stmt.QueryRowContext(ctx, args...)
+	}
+	defer s.drv.leave(seq)
+	s.drv.statements.Add(1)
+	if s.drv.logs(ctx) {
+		s.drv.logStmt(ctx, fmt.Sprintf("Stmt(%s).QueryRowContext: query=%v", s.id, s.query), s.query, args)
+	}
+	s.drv.run(ctx, s.ex, s.txID, op, s.query, args, nil, func(ctx context.Context) error {
+		row = s.Stmt.QueryRowContext(ctx, args...)
+		return s.drv.access(ctx, s.txID, s.query, nil, row.Err())
+	})
+	return row
+}
+
+// Close logs this step and calls the underlying statement Close method.
+func (s *DebugStmt) Close() error {
+	if s.drv.logs(context.Background()) {
+		s.log(context.Background(), fmt.Sprintf("Stmt(%s): closed", s.id))
+	}
+	return s.Stmt.Close()
+}
+
+// preparer returns the PrepareContext method of the given driver or
+// transaction, if it has one or if its chain exposes a *sql.DB, or if
+// it exposes a *sql.Tx.
+func preparer(v any) (func(context.Context, string) (*sql.Stmt, error), bool) {
+	switch v := v.(type) {
+	case interface {
+		PrepareContext(context.Context, string) (*sql.Stmt, error)
+	}:
+		return v.PrepareContext, true
+	case dialect.Tx:
+		if tx, ok := sqlTx(v); ok {
+			return tx.PrepareContext, true
+		}
+	case dialect.Driver:
+		if db := sqlDB(v); db != nil {
+			return db.PrepareContext, true
+		}
+	}
+	return nil, false
+}
+
+// sqlTx returns the *sql.Tx underlying the given ent transaction, if any.
+func sqlTx(tx dialect.Tx) (*sql.Tx, bool) {
+	etx, ok := tx.(*entsql.Tx)
+	if !ok {
+		return nil, false
+	}
+	stx, ok := etx.Tx.(*sql.Tx)
+	return stx, ok
+}
@@ -0,0 +1,123 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"entgo.io/ent/dialect"
+	entsql "entgo.io/ent/dialect/sql"
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// recordSink is a Sink recording the events it receives.
+type recordSink struct {
+	mu     sync.Mutex
+	events []*Event
+}
+
+func (s *recordSink) Write(_ context.Context, e *Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, e)
+	return nil
+}
+
+func (*recordSink) Close() error { return nil }
+
+func TestDebugStmt(t *testing.T) {
+	errBoom := errors.New("boom")
+	tests := []struct {
+		name   string
+		expect func(sqlmock.Sqlmock)
+		exec   func(context.Context, *DebugStmt) error
+		op     string
+		err    error
+	}{
+		{
+			name: "exec",
+			expect: func(m sqlmock.Sqlmock) {
+				m.ExpectPrepare("UPDATE users SET name = ?").ExpectExec().WithArgs("a8m").WillReturnResult(sqlmock.NewResult(0, 3))
+			},
+			exec: func(ctx context.Context, s *DebugStmt) error {
+				_, err := s.ExecContext(ctx, "a8m")
+				return err
+			},
+			op: "Stmt.ExecContext",
+		},
+		{
+			name: "exec error",
+			expect: func(m sqlmock.Sqlmock) {
+				m.ExpectPrepare("UPDATE users SET name = ?").ExpectExec().WithArgs("a8m").WillReturnError(errBoom)
+			},
+			exec: func(ctx context.Context, s *DebugStmt) error {
+				_, err := s.ExecContext(ctx, "a8m")
+				return err
+			},
+			op:  "Stmt.ExecContext",
+			err: errBoom,
+		},
+		{
+			name: "query error",
+			expect: func(m sqlmock.Sqlmock) {
+				m.ExpectPrepare("UPDATE users SET name = ?").ExpectQuery().WithArgs("a8m").WillReturnError(errBoom)
+			},
+			exec: func(ctx context.Context, s *DebugStmt) error {
+				_, err := s.QueryContext(ctx, "a8m")
+				return err
+			},
+			op:  "Stmt.QueryContext",
+			err: errBoom,
+		},
+		{
+			name: "query row error",
+			expect: func(m sqlmock.Sqlmock) {
+				m.ExpectPrepare("UPDATE users SET name = ?").ExpectQuery().WithArgs("a8m").WillReturnError(errBoom)
+			},
+			exec: func(ctx context.Context, s *DebugStmt) error {
+				return s.QueryRowContext(ctx, "a8m").Err()
+			},
+			op:  "Stmt.QueryRowContext",
+			err: errBoom,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			tt.expect(mock)
+			sink := &recordSink{}
+			var onErr []error
+			drv := newDebugDriver(entsql.OpenDB(dialect.MySQL, db), nopLog, WithSink(sink), OnError(func(_ context.Context, _, _ string, _ []any, err error) {
+				onErr = append(onErr, err)
+			}))
+			ctx := context.Background()
+			stmt, err := drv.PrepareContext(ctx, "UPDATE users SET name = ?")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := tt.exec(ctx, stmt); !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			if n := drv.statements.Load(); n != 1 {
+				t.Errorf("counted %d statements, want 1", n)
+			}
+			if len(sink.events) != 1 {
+				t.Fatalf("sink received %d events, want 1", len(sink.events))
+			}
+			if e := sink.events[0]; e.Op != tt.op || !errors.Is(e.Err, tt.err) || tt.err != nil && e.ErrorClass == "" {
+				t.Errorf("event op = %q, err = %v, class = %q, want %q, %v", e.Op, e.Err, e.ErrorClass, tt.op, tt.err)
+			}
+			if tt.err != nil && (len(onErr) != 1 || !errors.Is(onErr[0], tt.err)) {
+				t.Errorf("OnError received %v, want %v", onErr, tt.err)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,340 @@
+package driver
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"entgo.io/ent/dialect"
+	entsql "entgo.io/ent/dialect/sql"
+	"go.uber.org/zap"
+)
+
+// StmtCacheConfig configures a StmtCacheDriver.
+type StmtCacheConfig struct {
+	// Size is the maximum number of cached statements. Defaults to 256.
+	Size int
+	// Log is the log function. Optional.
+	Log LogFunc
+	// Metrics receives the cache measurements. Optional.
+	Metrics Metrics
+}
+
+// StmtCacheStats holds the counters of a StmtCacheDriver.
+type StmtCacheStats struct {
+	Hits, Prepares, Evictions int64
+}
+
+// HitRatio returns the ratio of executions that reused a prepared statement.
+func (s StmtCacheStats) HitRatio() float64 {
+	if s.Hits+s.Prepares == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Prepares)
+}
+
+// StmtCacheDriver is a driver that prepares the executed statements once and
+// reuses them, if the underlying driver exposes its *sql.DB.
+type StmtCacheDriver struct {
+	Driver                    // underlying driver.
+	db                        *sql.DB
+	cfg                       StmtCacheConfig
+	mu                        sync.Mutex
+	lru                       *list.List // of *cachedStmt.
+	stmts                     map[string]*list.Element
+	hits, prepares, evictions atomic.Int64
+}
+
+// cachedStmt is a prepared statement held by the cache. It is closed once
+// evicted and no longer used by the executions.
+type cachedStmt struct {
+	query   string
+	stmt    *sql.Stmt
+	refs    int  // executions using the statement.
+	evicted bool // whether the statement left the cache.
+}
+
+// NewStmtCacheDriver returns a new StmtCacheDriver wrapping the given driver.
+func NewStmtCacheDriver(d Driver, cfg StmtCacheConfig) *StmtCacheDriver {
+	if cfg.Size <= 0 {
+		cfg.Size = 256
+	}
+	if cfg.Log == nil {
+		cfg.Log = nopLog
+	}
+	if cfg.Metrics == nil {
+		cfg.Metrics = nopMetrics{}
+	}
+	return &StmtCacheDriver{Driver: d, cfg: cfg, lru: list.New(), stmts: make(map[string]*list.Element), db: sqlDB(d)}
+}
+
+// Stats returns the cache counters.
+func (d *StmtCacheDriver) Stats() StmtCacheStats {
+	return StmtCacheStats{Hits: d.hits.Load(), Prepares: d.prepares.Load(), Evictions: d.evictions.Load()}
+}
+
+// Exec executes the statement using a cached prepared statement.
+func (d *StmtCacheDriver) Exec(ctx context.Context, query string, args, v any) error {
+	argv, ok := args.([]any)
+	if d.db == nil || !ok {
+		return d.Driver.Exec(ctx, query, args, v)
+	}
+	cs, err := d.stmt(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer d.release(cs)
+	return stmtExec(ctx, cs.stmt, argv, v)
+}
+
+// ExecContext executes the statement using a cached prepared statement.
+func (d *StmtCacheDriver) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	if d.db == nil {
+		return execContext(ctx, d.Driver, query, args)
+	}
+	cs, err := d.stmt(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer d.release(cs)
+	return cs.stmt.ExecContext(ctx, args...)
+}
+
+// Query executes the query using a cached prepared statement.
+func (d *StmtCacheDriver) Query(ctx context.Context, query string, args, v any) error {
+	argv, ok := args.([]any)
+	if d.db == nil || !ok {
+		return d.Driver.Query(ctx, query, args, v)
+	}
+	cs, err := d.stmt(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer d.release(cs)
+	return stmtQuery(ctx, cs.stmt, argv, v)
+}
+
+// QueryContext executes the query using a cached prepared statement.
+func (d *StmtCacheDriver) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	if d.db == nil {
+		return queryContext(ctx, d.Driver, query, args)
+	}
+	cs, err := d.stmt(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer d.release(cs)
+	return cs.stmt.QueryContext(ctx, args...)
+}
+
+// Tx starts a transaction that executes its statements using the cached prepared statements.
+func (d *StmtCacheDriver) Tx(ctx context.Context) (dialect.Tx, error) {
+	tx, err := d.Driver.Tx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return d.wrapTx(tx), nil
+}
+
+// BeginTx starts a transaction with options if it is supported by the underlying driver.
+// The transaction executes its statements using the cached prepared statements.
+func (d *StmtCacheDriver) BeginTx(ctx context.Context, opts *sql.TxOptions) (dialect.Tx, error) {
+	tx, err := beginTx(ctx, d.Driver, opts)
+	if err != nil {
+		return nil, err
+	}
+	return d.wrapTx(tx), nil
+}
+
+// Close closes all cached statements and the underlying driver. The
+// statements still in use are closed once their executions return.
+func (d *StmtCacheDriver) Close() error {
+	d.mu.Lock()
+	var errs []error
+	for e := d.lru.Front(); e != nil; e = e.Next() {
+		if cs := e.Value.(*cachedStmt); cs.evict() {
+			errs = append(errs, cs.stmt.Close())
+		}
+	}
+	d.lru.Init()
+	clear(d.stmts)
+	d.mu.Unlock()
+	return errors.Join(append(errs, d.Driver.Close())...)
+}
+
+// stmt returns the cached prepared statement of the query, preparing it if
+// needed. The statement is used until release is called.
+func (d *StmtCacheDriver) stmt(ctx context.Context, query string) (*cachedStmt, error) {
+	d.mu.Lock()
+	if e, ok := d.stmts[query]; ok {
+		d.lru.MoveToFront(e)
+		cs := e.Value.(*cachedStmt)
+		cs.refs++
+		d.mu.Unlock()
+		d.hits.Add(1)
+		d.cfg.Metrics.Count(ctx, "entzlog_stmt_cache_lookups_total", 1, Label{"result", "hit"})
+		return cs, nil
+	}
+	d.mu.Unlock()
+	stmt, err := d.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	d.prepares.Add(1)
+	d.cfg.Log(ctx, "stmtcache: prepared", zap.String("query", query), zap.Float64("hit_ratio", d.Stats().HitRatio()))
+	d.cfg.Metrics.Count(ctx, "entzlog_stmt_cache_lookups_total", 1, Label{"result", "miss"})
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if e, ok := d.stmts[query]; ok {
+		// Prepared concurrently by another caller.
+		stmt.Close()
+		cs := e.Value.(*cachedStmt)
+		cs.refs++
+		return cs, nil
+	}
+	cs := &cachedStmt{query: query, stmt: stmt, refs: 1}
+	d.stmts[query] = d.lru.PushFront(cs)
+	for d.lru.Len() > d.cfg.Size {
+		old := d.lru.Remove(d.lru.Back()).(*cachedStmt)
+		delete(d.stmts, old.query)
+		if old.evict() {
+			old.stmt.Close()
+		}
+		d.evictions.Add(1)
+		d.cfg.Metrics.Count(ctx, "entzlog_stmt_cache_evictions_total", 1)
+	}
+	return cs, nil
+}
+
+// release ends an execution using the statement, and closes it if it was
+// evicted and is no longer used.
+func (d *StmtCacheDriver) release(cs *cachedStmt) {
+	d.mu.Lock()
+	cs.refs--
+	unused := cs.evicted && cs.refs == 0
+	d.mu.Unlock()
+	if unused {
+		cs.stmt.Close()
+	}
+}
+
+// evict marks the statement as evicted, and reports whether it can be
+// closed right away. It must be called with the lock held.
+func (cs *cachedStmt) evict() bool {
+	cs.evicted = true
+	return cs.refs == 0
+}
+
+// wrapTx returns a transaction using the cached statements, if the
+// underlying *sql.Tx is reachable, or tx itself otherwise.
+func (d *StmtCacheDriver) wrapTx(tx dialect.Tx) dialect.Tx {
+	stx, ok := sqlTx(tx)
+	if !ok || d.db == nil {
+		return tx
+	}
+	return &stmtCacheTx{Tx: tx, tx: stx, drv: d}
+}
+
+// stmtCacheTx is a transaction that executes its statements using the
+// cached prepared statements of the driver.
+type stmtCacheTx struct {
+	dialect.Tx
+	tx  *sql.Tx
+	drv *StmtCacheDriver
+}
+
+// Exec executes the statement using a cached prepared statement.
+func (tx *stmtCacheTx) Exec(ctx context.Context, query string, args, v any) error {
+	argv, ok := args.([]any)
+	if !ok {
+		return tx.Tx.Exec(ctx, query, args, v)
+	}
+	stmt, release, err := tx.stmt(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return stmtExec(ctx, stmt, argv, v)
+}
+
+// Query executes the query using a cached prepared statement.
+func (tx *stmtCacheTx) Query(ctx context.Context, query string, args, v any) error {
+	argv, ok := args.([]any)
+	if !ok {
+		return tx.Tx.Query(ctx, query, args, v)
+	}
+	stmt, release, err := tx.stmt(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return stmtQuery(ctx, stmt, argv, v)
+}
+
+// ExecContext executes the statement using a cached prepared statement.
+func (tx *stmtCacheTx) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	stmt, release, err := tx.stmt(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return stmt.ExecContext(ctx, args...)
+}
+
+// QueryContext executes the query using a cached prepared statement.
+func (tx *stmtCacheTx) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	stmt, release, err := tx.stmt(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return stmt.QueryContext(ctx, args...)
+}
+
+// stmt returns the transaction-specific version of the cached statement,
+// and the function to call once it was executed.
+func (tx *stmtCacheTx) stmt(ctx context.Context, query string) (*sql.Stmt, func(), error) {
+	cs, err := tx.drv.stmt(ctx, query)
+	if err != nil {
+		return nil, nil, err
+	}
+	return tx.tx.StmtContext(ctx, cs.stmt), func() { tx.drv.release(cs) }, nil
+}
+
+// stmtExec executes the prepared statement and stores its result in v,
+// following the dialect.ExecQuerier conventions.
+func stmtExec(ctx context.Context, stmt *sql.Stmt, args []any, v any) error {
+	switch v := v.(type) {
+	case nil:
+		_, err := stmt.ExecContext(ctx, args...)
+		return err
+	case *sql.Result:
+		res, err := stmt.ExecContext(ctx, args...)
+		if err != nil {
+			return err
+		}
+		*v = res
+		return nil
+	default:
+		return fmt.Errorf("entzlog: invalid type %T. expect *sql.Result", v)
+	}
+}
+
+// stmtQuery executes the prepared query and stores its rows in v,
+// following the dialect.ExecQuerier conventions.
+func stmtQuery(ctx context.Context, stmt *sql.Stmt, args []any, v any) error {
+	vr, ok := v.(*entsql.Rows)
+	if !ok {
+		return fmt.Errorf("entzlog: invalid type %T. expect *sql.Rows", v)
+	}
+	rows, err := stmt.QueryContext(ctx, args...)
+	if err != nil {
+		return err
+	}
+	*vr = entsql.Rows{ColumnScanner: rows}
+	return nil
+}
@@ -0,0 +1,188 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SyslogConfig configures a SyslogSink.
+type SyslogConfig struct {
+	// Network is the network of Addr: "udp", "tcp" or "unix". Defaults to "udp".
+	Network string
+	// Addr is the address of the syslog server. Defaults to "localhost:514".
+	Addr string
+	// Facility is the syslog facility. Defaults to 16 (local0).
+	Facility int
+	// AppName is the APP-NAME of the messages. Defaults to the program name.
+	AppName string
+	// Hostname is the HOSTNAME of the messages. Defaults to os.Hostname.
+	Hostname string
+	// Filter selects the sent events. Defaults to all events.
+	Filter func(*Event) bool
+	// Interval is the time between two sent batches. Defaults to 1s.
+	Interval time.Duration
+	// BatchSize is the maximum number of events of a batch. Defaults to 500.
+	BatchSize int
+	// MaxPending is the maximum number of events waiting to be sent.
+	// Events exceeding it are dropped. Defaults to 10000.
+	MaxPending int
+	// Timeout is the connection and write timeout. Defaults to 5s.
+	Timeout time.Duration
+}
+
+// SyslogSink is a Sink that sends the events to syslog as RFC 5424 messages,
+// in batches from a background goroutine.
+type SyslogSink struct {
+	cfg  SyslogConfig
+	b    *batcher
+	mu   sync.Mutex
+	conn redialer
+}
+
+// NewSyslogSink returns a new SyslogSink and starts its sender. The
+// connection is established by the first batch, and re-established after
+// failures with a backoff.
+func NewSyslogSink(cfg SyslogConfig) *SyslogSink {
+	if cfg.Network == "" {
+		cfg.Network = "udp"
+	}
+	if cfg.Addr == "" {
+		cfg.Addr = "localhost:514"
+	}
+	if cfg.Facility == 0 {
+		cfg.Facility = 16
+	}
+	if cfg.AppName == "" {
+		cfg.AppName = filepath.Base(os.Args[0])
+	}
+	if cfg.Hostname == "" {
+		cfg.Hostname, _ = os.Hostname()
+	}
+	if cfg.Filter == nil {
+		cfg.Filter = func(*Event) bool { return true }
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Second
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 500
+	}
+	if cfg.MaxPending <= 0 {
+		cfg.MaxPending = 10000
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+	s := &SyslogSink{cfg: cfg, conn: redialer{network: cfg.Network, addr: cfg.Addr, timeout: cfg.Timeout}}
+	s.b = newBatcher(cfg.Interval, cfg.BatchSize, cfg.MaxPending, s.send)
+	return s
+}
+
+// Syslog severities used by the sink.
+const (
+	severityError   = 3
+	severityWarning = 4
+	severityInfo    = 6
+)
+
+// Write queues the event if it is selected by the filter.
+func (s *SyslogSink) Write(_ context.Context, e *Event) error {
+	if !s.cfg.Filter(e) {
+		return nil
+	}
+	return s.b.add(e)
+}
+
+// Dropped returns the number of events dropped because too many were pending.
+func (s *SyslogSink) Dropped() int64 {
+	return s.b.dropped.Load()
+}
+
+// Close sends the pending events and closes the connection.
+func (s *SyslogSink) Close() error {
+	err := s.b.close()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.conn.close()
+	return err
+}
+
+// send sends the events, one message each.
+func (s *SyslogSink) send(events []*Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	conn, err := s.conn.get()
+	if err != nil {
+		return fmt.Errorf("entzlog: connecting to syslog: %w", err)
+	}
+	for _, e := range events {
+		msg := s.format(e)
+		if s.cfg.Network != "udp" && s.cfg.Network != "unixgram" {
+			msg = fmt.Sprintf("%d %s", len(msg), msg)
+		}
+		conn.SetWriteDeadline(time.Now().Add(s.cfg.Timeout))
+		if _, err := conn.Write([]byte(msg)); err != nil {
+			s.conn.reset()
+			return fmt.Errorf("entzlog: writing to syslog: %w", err)
+		}
+	}
+	return nil
+}
+
+// format returns the event as an RFC 5424 message.
+func (s *SyslogSink) format(e *Event) string {
+	severity := severityInfo
+	switch {
+	case e.Err != nil || e.Op == OpDegraded:
+		severity = severityError
+	case e.Slow:
+		severity = severityWarning
+	}
+	var sd strings.Builder
+	sd.WriteString("[entzlog@32473")
+	for _, p := range [][2]string{
+		{"dialect", e.Dialect},
+		{"op", e.Op},
+		{"status", e.Status()},
+		{"duration_ms", fmt.Sprintf("%.3f", float64(e.Duration)/float64(time.Millisecond))},
+		{"tx_id", e.TxID},
+		{"tenant", e.Tenant},
+		{"fingerprint", e.Fingerprint()},
+		{"error_class", string(e.ErrorClass)},
+	} {
+		if p[1] != "" {
+			fmt.Fprintf(&sd, " %s=\"%s\"", p[0], sdEscaper.Replace(p[1]))
+		}
+	}
+	for _, a := range e.attrs() {
+		fmt.Fprintf(&sd, " %s=\"%s\"", a.Key, sdEscaper.Replace(a.Value))
+	}
+	sd.WriteByte(']')
+	msg := e.Query
+	if e.Err != nil {
+		msg = strings.TrimSpace(msg + " error: " + e.Err.Error())
+	}
+	if msg == "" {
+		msg = e.Op
+	}
+	// <PRI>VERSION TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA MSG
+	return fmt.Sprintf("<%d>1 %s %s %s %d %s %s %s",
+		s.cfg.Facility*8+severity, e.Time.Format(time.RFC3339Nano), nilValue(s.cfg.Hostname),
+		nilValue(s.cfg.AppName), os.Getpid(), e.Op, sd.String(), msg)
+}
+
+// sdEscaper escapes the characters that must be escaped in structured data values.
+var sdEscaper = strings.NewReplacer(`"`, `\"`, `\`, `\\`, `]`, `\]`)
+
+// nilValue returns the syslog NILVALUE for empty header fields.
+func nilValue(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return strings.ReplaceAll(s, " ", "_")
+}
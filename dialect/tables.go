@@ -0,0 +1,206 @@
+package driver
+
+import (
+	"strings"
+	"unicode"
+)
+
+// queryTables returns the tables read or written by the query, in order of
+// appearance and without duplicates. It understands the statement shapes
+// generated by ent, and may miss tables referenced by hand-written SQL.
+func queryTables(query string) []string {
+	var (
+		names []string
+		toks  = lex(query)
+	)
+	add := func(t token) {
+		if t.kind != tokIdent || isKeyword(t.text) {
+			return
+		}
+		name := t.name()
+		for _, n := range names {
+			if n == name {
+				return
+			}
+		}
+		names = append(names, name)
+	}
+	for i := 0; i < len(toks); i++ {
+		if toks[i].kind != tokIdent {
+			continue
+		}
+		switch t := toks[i].text; {
+		case equalFoldAny(t, "INTO", "UPDATE", "JOIN", "TABLE"):
+			if j := skipModifiers(toks, i+1); j < len(toks) {
+				add(toks[j])
+			}
+		case strings.EqualFold(t, "FROM"):
+			// FROM a [AS] x, b [AS] y.
+			for j := skipModifiers(toks, i+1); j < len(toks); j++ {
+				add(toks[j])
+				for j+1 < len(toks) && toks[j+1].kind == tokIdent && !isKeyword(toks[j+1].text) {
+					j++ // alias.
+				}
+				if j+1 >= len(toks) || toks[j+1].text != "," {
+					break
+				}
+				j++
+			}
+		}
+	}
+	return names
+}
+
+// StatementTable returns the primary table of the query: the table modified
+// by write statements, or the first table read by other statements. It
+// returns an empty string if the query has no table.
+func StatementTable(query string) string {
+	tables := writeTables(query)
+	if tables == nil {
+		tables = queryTables(query)
+	}
+	if len(tables) == 0 {
+		return ""
+	}
+	return tables[0]
+}
+
+// skipModifiers skips the modifiers that may precede a table name
+// (e.g. IF NOT EXISTS) and returns the offset of the next token.
+func skipModifiers(toks []token, i int) int {
+	for ; i < len(toks) && toks[i].kind == tokIdent; i++ {
+		if !equalFoldAny(toks[i].text, "IF", "NOT", "EXISTS", "ONLY") {
+			return i
+		}
+	}
+	return i
+}
+
+// writeTables returns the tables modified by the query, or nil if
+// the query does not modify any table.
+func writeTables(query string) []string {
+	q := trimComments(query)
+	for _, verb := range []string{"INSERT", "UPDATE", "DELETE", "REPLACE", "MERGE", "TRUNCATE", "ALTER", "DROP", "CREATE"} {
+		if len(q) >= len(verb) && strings.EqualFold(q[:len(verb)], verb) {
+			tables := queryTables(query)
+			if len(tables) > 1 && verb != "INSERT" && verb != "REPLACE" {
+				// Tables referenced by subqueries are only read.
+				tables = tables[:1]
+			}
+			return tables
+		}
+	}
+	return nil
+}
+
+// isKeyword reports whether the word is an SQL keyword that may
+// follow the clauses recognized by queryTables.
+func isKeyword(word string) bool {
+	return equalFoldAny(word, "SELECT", "LATERAL", "ONLY", "IF", "EXISTS", "NOT", "WHERE", "SET", "VALUES",
+		"ON", "USING", "AS", "LEFT", "RIGHT", "INNER", "OUTER", "CROSS", "FULL", "JOIN",
+		"GROUP", "ORDER", "LIMIT", "OFFSET", "HAVING", "UNION", "DEFAULT", "RETURNING", "FOR")
+}
+
+// equalFoldAny reports whether the word is equal to one of the keywords,
+// ignoring case, without allocating its upper-case form.
+func equalFoldAny(word string, keywords ...string) bool {
+	for _, k := range keywords {
+		if strings.EqualFold(word, k) {
+			return true
+		}
+	}
+	return false
+}
+
+// token kinds.
+const (
+	tokIdent = iota
+	tokPunct
+	tokString
+)
+
+// token is a lexical token of an SQL statement.
+type token struct {
+	kind int
+	text string
+}
+
+// name returns the unquoted name of an identifier token. For qualified
+// identifiers, the last part is returned.
+func (t token) name() string {
+	name := t.text
+	if i := strings.LastIndexByte(name, '.'); i != -1 {
+		name = name[i+1:]
+	}
+	return strings.Trim(name, "`\"[]")
+}
+
+// lex splits the query into tokens. Identifiers keep their quotes
+// and qualified identifiers (e.g. "public"."users") are one token.
+func lex(query string) []token {
+	toks := make([]token, 0, 32)
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case unicode.IsSpace(rune(c)):
+			i++
+		case c == '\'':
+			j := i + 1
+			for j < len(query) {
+				if query[j] == '\'' {
+					if j+1 < len(query) && query[j+1] == '\'' {
+						j += 2
+						continue
+					}
+					break
+				}
+				j++
+			}
+			toks = append(toks, token{tokString, query[i:min(j+1, len(query))]})
+			i = j + 1
+		case isIdentStart(c):
+			j := i
+			for j < len(query) {
+				if isIdentStart(query[j]) {
+					j = identPart(query, j)
+				}
+				if j < len(query) && query[j] == '.' && j+1 < len(query) && isIdentStart(query[j+1]) {
+					j++
+					continue
+				}
+				break
+			}
+			toks = append(toks, token{tokIdent, query[i:j]})
+			i = j
+		default:
+			toks = append(toks, token{tokPunct, query[i : i+1]})
+			i++
+		}
+	}
+	return toks
+}
+
+// isIdentStart reports whether c starts a (possibly quoted) identifier.
+func isIdentStart(c byte) bool {
+	return c == '`' || c == '"' || c == '[' || c == '_' || c == '$' || c >= 0x80 ||
+		'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+}
+
+// identPart returns the end offset of the identifier part starting at i.
+func identPart(query string, i int) int {
+	switch q := query[i]; q {
+	case '`', '"', '[':
+		if q == '[' {
+			q = ']'
+		}
+		if j := strings.IndexByte(query[i+1:], q); j != -1 {
+			return i + j + 2
+		}
+		return len(query)
+	}
+	j := i
+	for j < len(query) && query[j] != '.' && isIdentStart(query[j]) && query[j] != '`' && query[j] != '"' && query[j] != '[' {
+		j++
+	}
+	return j
+}
@@ -0,0 +1,231 @@
+package driver
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+
+	"entgo.io/ent/dialect"
+	"go.uber.org/zap"
+)
+
+// ErrNoTenant is returned by the TenantDriver when the context
+// carries no tenant and no default driver is configured.
+var ErrNoTenant = errors.New("entzlog: no tenant in context")
+
+type tenantKey struct{}
+
+// WithTenant returns a context carrying the given tenant key.
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// TenantFromContext returns the tenant key stored in the context, if any.
+func TenantFromContext(ctx context.Context) (string, bool) {
+	tenant, ok := ctx.Value(tenantKey{}).(string)
+	return tenant, ok
+}
+
+// TenantTarget is the driver a tenant resolves to.
+type TenantTarget struct {
+	Driver Driver
+	// Database is the database (or schema) name of the tenant, used for logging.
+	Database string
+}
+
+// TenantResolver resolves the target of a tenant. It is called once per
+// tenant, and its successful results are cached.
+type TenantResolver func(ctx context.Context, tenant string) (TenantTarget, error)
+
+// StaticTenants returns a TenantResolver for a fixed set of tenants.
+func StaticTenants(targets map[string]TenantTarget) TenantResolver {
+	return func(_ context.Context, tenant string) (TenantTarget, error) {
+		t, ok := targets[tenant]
+		if !ok {
+			return TenantTarget{}, fmt.Errorf("entzlog: unknown tenant %q", tenant)
+		}
+		return t, nil
+	}
+}
+
+// TenantConfig configures a TenantDriver.
+type TenantConfig struct {
+	// Dialect of the tenant drivers. Required.
+	Dialect string
+	// Resolver resolves the driver of a tenant. Required. For schema-per-tenant
+	// setups, it returns a driver bound to the tenant schema (e.g. using the
+	// search_path DSN parameter).
+	Resolver TenantResolver
+	// Default is the driver used when the context carries no tenant. Optional.
+	Default Driver
+	// Log is the log function used for all tenant statements.
+	Log LogFunc
+}
+
+// TenantDriver is a driver that routes each operation to the driver of the
+// tenant stored in the context, and logs every statement with the resolved
+// tenant and database.
+type TenantDriver struct {
+	cfg       TenantConfig
+	mu        sync.RWMutex
+	targets   map[string]Driver         // debugged tenant drivers.
+	resolving map[string]*tenantResolve // tenants being resolved.
+	closers   []Driver                  // drivers returned by the resolver.
+}
+
+// tenantResolve is the resolution of a tenant in progress.
+type tenantResolve struct {
+	done chan struct{} // closed once resolved.
+	drv  Driver
+	err  error
+}
+
+// NewTenantDriver returns a new TenantDriver.
+func NewTenantDriver(cfg TenantConfig) *TenantDriver {
+	if cfg.Log == nil {
+		cfg.Log = nopLog
+	}
+	return &TenantDriver{cfg: cfg, targets: make(map[string]Driver), resolving: make(map[string]*tenantResolve)}
+}
+
+// Exec calls the Exec method of the tenant driver.
+func (d *TenantDriver) Exec(ctx context.Context, query string, args, v any) error {
+	drv, err := d.resolve(ctx)
+	if err != nil {
+		return err
+	}
+	return drv.Exec(ctx, query, args, v)
+}
+
+// Query calls the Query method of the tenant driver.
+func (d *TenantDriver) Query(ctx context.Context, query string, args, v any) error {
+	drv, err := d.resolve(ctx)
+	if err != nil {
+		return err
+	}
+	return drv.Query(ctx, query, args, v)
+}
+
+// ExecContext calls the ExecContext method of the tenant driver.
+func (d *TenantDriver) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	drv, err := d.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return execContext(ctx, drv, query, args)
+}
+
+// QueryContext calls the QueryContext method of the tenant driver.
+func (d *TenantDriver) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	drv, err := d.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return queryContext(ctx, drv, query, args)
+}
+
+// Tx starts a transaction on the tenant driver.
+func (d *TenantDriver) Tx(ctx context.Context) (dialect.Tx, error) {
+	drv, err := d.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return drv.Tx(ctx)
+}
+
+// BeginTx starts a transaction on the tenant driver if it is supported.
+func (d *TenantDriver) BeginTx(ctx context.Context, opts *sql.TxOptions) (dialect.Tx, error) {
+	drv, err := d.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return beginTx(ctx, drv, opts)
+}
+
+// Dialect returns the configured dialect.
+func (d *TenantDriver) Dialect() string {
+	return d.cfg.Dialect
+}
+
+// Close closes the default driver and all drivers returned by the resolver.
+func (d *TenantDriver) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var errs []error
+	if d.cfg.Default != nil {
+		errs = append(errs, d.cfg.Default.Close())
+	}
+	for _, drv := range d.closers {
+		errs = append(errs, drv.Close())
+	}
+	d.targets, d.closers = make(map[string]Driver), nil
+	return errors.Join(errs...)
+}
+
+// resolve returns the debugged driver of the tenant stored in the context.
+func (d *TenantDriver) resolve(ctx context.Context) (Driver, error) {
+	tenant, _ := TenantFromContext(ctx)
+	if tenant == "" && d.cfg.Default == nil {
+		return nil, ErrNoTenant
+	}
+	d.mu.RLock()
+	drv, ok := d.targets[tenant]
+	d.mu.RUnlock()
+	if ok {
+		return drv, nil
+	}
+	d.mu.Lock()
+	if drv, ok := d.targets[tenant]; ok {
+		d.mu.Unlock()
+		return drv, nil
+	}
+	if r, ok := d.resolving[tenant]; ok {
+		d.mu.Unlock()
+		select {
+		case <-r.done:
+			return r.drv, r.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	r := &tenantResolve{done: make(chan struct{})}
+	d.resolving[tenant] = r
+	d.mu.Unlock()
+	defer func() {
+		if p := recover(); p != nil {
+			r.drv, r.err = nil, fmt.Errorf("entzlog: resolving tenant %q panicked: %v", tenant, p)
+			defer panic(p)
+		}
+		d.mu.Lock()
+		delete(d.resolving, tenant)
+		if r.err == nil && r.drv != nil {
+			d.targets[tenant] = r.drv
+		}
+		d.mu.Unlock()
+		close(r.done)
+	}()
+	t := TenantTarget{Driver: d.cfg.Default}
+	if tenant != "" {
+		if t, r.err = d.cfg.Resolver(ctx, tenant); r.err != nil {
+			return nil, r.err
+		}
+		if t.Driver == nil {
+			r.err = fmt.Errorf("entzlog: no driver for tenant %q", tenant)
+			return nil, r.err
+		}
+		d.mu.Lock()
+		d.closers = append(d.closers, t.Driver)
+		d.mu.Unlock()
+	}
+	r.drv = DebugWithContext(t.Driver, withFields(d.cfg.Log, zap.String("tenant", tenant), zap.String("database", t.Database)))
+	return r.drv, nil
+}
+
+// withFields returns a LogFunc that appends the given fields to every entry.
+func withFields(log LogFunc, fields ...zap.Field) LogFunc {
+	return func(ctx context.Context, msg string, fs ...zap.Field) {
+		log(ctx, msg, append(fs, fields...)...)
+	}
+}
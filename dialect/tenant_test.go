@@ -0,0 +1,112 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"entgo.io/ent/dialect"
+	entsql "entgo.io/ent/dialect/sql"
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestTenantDriverResolve(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	mock.ExpectExec("DELETE FROM users").WillReturnResult(sqlmock.NewResult(0, 1))
+	valid := entsql.OpenDB(dialect.Postgres, db)
+	tests := []struct {
+		name     string
+		resolver TenantResolver
+		tenant   string
+		wantErr  bool
+		panics   bool
+	}{
+		{
+			name:     "resolved",
+			resolver: StaticTenants(map[string]TenantTarget{"acme": {Driver: valid}}),
+			tenant:   "acme",
+		},
+		{
+			name:     "unknown",
+			resolver: StaticTenants(map[string]TenantTarget{"acme": {Driver: valid}}),
+			tenant:   "other",
+			wantErr:  true,
+		},
+		{
+			name: "nil driver",
+			resolver: func(context.Context, string) (TenantTarget, error) {
+				return TenantTarget{}, nil
+			},
+			tenant:  "acme",
+			wantErr: true,
+		},
+		{
+			name: "panic",
+			resolver: func(context.Context, string) (TenantTarget, error) {
+				panic("boom")
+			},
+			tenant: "acme",
+			panics: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			drv := NewTenantDriver(TenantConfig{Dialect: dialect.Postgres, Resolver: tt.resolver})
+			ctx := WithTenant(context.Background(), tt.tenant)
+			err := func() (err error) {
+				defer func() {
+					if r := recover(); r != nil && !tt.panics {
+						t.Errorf("unexpected panic: %v", r)
+					} else if r == nil && tt.panics {
+						t.Error("panic was not raised")
+					}
+				}()
+				return drv.Exec(ctx, "DELETE FROM users", []any{}, nil)
+			}()
+			if !tt.panics && (err != nil) != tt.wantErr {
+				t.Errorf("err = %v, want error %t", err, tt.wantErr)
+			}
+		})
+	}
+	if _, err := NewTenantDriver(TenantConfig{Dialect: dialect.Postgres}).resolve(context.Background()); !errors.Is(err, ErrNoTenant) {
+		t.Errorf("err = %v, want ErrNoTenant", err)
+	}
+}
+
+func TestTenantDriverResolvePanicWaiters(t *testing.T) {
+	release := make(chan struct{})
+	drv := NewTenantDriver(TenantConfig{Dialect: dialect.Postgres, Resolver: func(context.Context, string) (TenantTarget, error) {
+		<-release
+		panic("boom")
+	}})
+	ctx := WithTenant(context.Background(), "acme")
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer func() { recover() }()
+		drv.resolve(ctx)
+	}()
+	// Let the first caller start resolving the tenant.
+	time.Sleep(10 * time.Millisecond)
+	errc := make(chan error, 1)
+	go func() {
+		_, err := drv.resolve(ctx)
+		errc <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if err := <-errc; err == nil {
+		t.Error("waiter of a panicking resolution got no error")
+	}
+	if len(drv.targets) != 0 {
+		t.Error("panicking resolution was cached")
+	}
+}
@@ -0,0 +1,58 @@
+package driver
+
+import (
+	"context"
+	"runtime/trace"
+)
+
+// WithTraceRegions returns an option that records the transactions and
+// statements of the driver as tasks and regions of runtime/trace.
+func WithTraceRegions() Option {
+	return func(d *DebugDriver) {
+		d.traced = true
+	}
+}
+
+// tracing reports whether the operations must be recorded in the trace.
+func (d *DebugDriver) tracing() bool {
+	return d.traced && trace.IsEnabled()
+}
+
+// startTask starts the trace task of the transaction, if tracing.
+func (d *DebugDriver) startTask(ctx context.Context, txID string) {
+	if !d.tracing() {
+		return
+	}
+	ctx, task := trace.NewTask(ctx, "entzlog.Tx")
+	trace.Log(ctx, "entzlog.tx_id", txID)
+	d.tasks.Store(txID, &txTask{ctx: ctx, task: task})
+}
+
+// endTask ends the trace task of the transaction, if it was started.
+func (d *DebugDriver) endTask(txID string) {
+	if t, ok := d.tasks.LoadAndDelete(txID); ok {
+		t.(*txTask).task.End()
+	}
+}
+
+// txTask is the trace task of a transaction, along with its context.
+type txTask struct {
+	ctx  context.Context
+	task *trace.Task
+}
+
+// startRegion starts the trace region of the operation, within the task
+// of its transaction, if any. It returns nil if not tracing.
+func (d *DebugDriver) startRegion(ctx context.Context, txID, op, query string) *trace.Region {
+	if !d.tracing() {
+		return nil
+	}
+	if t, ok := d.tasks.Load(txID); ok {
+		ctx = t.(*txTask).ctx
+	}
+	if query == "" {
+		return trace.StartRegion(ctx, "entzlog:"+op)
+	}
+	trace.Log(ctx, "entzlog.query", SanitizeQuery(query))
+	return trace.StartRegion(ctx, "entzlog:"+Fingerprint(query))
+}
@@ -0,0 +1,78 @@
+package driver
+
+import (
+	"reflect"
+
+	"entgo.io/ent/dialect"
+)
+
+// As finds the first driver (or transaction) in the Unwrap chain of v that
+// matches target, and if one is found, sets target to it and returns true,
+// like errors.As. It panics if target is not a non-nil pointer.
+func As(v, target any) bool {
+	val := reflect.ValueOf(target)
+	if val.Kind() != reflect.Pointer || val.IsNil() {
+		panic("entzlog: target must be a non-nil pointer")
+	}
+	typ := val.Type().Elem()
+	for v != nil {
+		if reflect.TypeOf(v).AssignableTo(typ) {
+			val.Elem().Set(reflect.ValueOf(v))
+			return true
+		}
+		switch u := v.(type) {
+		case interface{ Unwrap() dialect.Driver }:
+			v = u.Unwrap()
+		case interface{ Unwrap() dialect.Tx }:
+			v = u.Unwrap()
+		default:
+			return false
+		}
+	}
+	return false
+}
+
+// Unwrap returns the underlying driver.
+func (d *DebugDriver) Unwrap() dialect.Driver { return d.Driver }
+
+// Unwrap returns the underlying transaction.
+func (d *DebugTx) Unwrap() dialect.Tx { return d.Tx }
+
+// Unwrap returns the primary driver.
+func (d *ReplicaDriver) Unwrap() dialect.Driver { return d.Driver }
+
+// Unwrap returns the underlying driver.
+func (d *CacheDriver) Unwrap() dialect.Driver { return d.Driver }
+
+// Unwrap returns the underlying driver.
+func (d *SingleflightDriver) Unwrap() dialect.Driver { return d.Driver }
+
+// Unwrap returns the underlying driver.
+func (d *MemoDriver) Unwrap() dialect.Driver { return d.Driver }
+
+// Unwrap returns the underlying driver.
+func (d *BatchDriver) Unwrap() dialect.Driver { return d.Driver }
+
+// Unwrap returns the underlying driver.
+func (d *StmtCacheDriver) Unwrap() dialect.Driver { return d.Driver }
+
+// Unwrap returns the primary driver.
+func (d *FailoverDriver) Unwrap() dialect.Driver { return d.primary }
+
+// Unwrap returns the underlying driver.
+func (d *DryRunDriver) Unwrap() dialect.Driver { return d.Driver }
+
+// Unwrap returns the underlying driver.
+func (d *MaintenanceDriver) Unwrap() dialect.Driver { return d.Driver }
+
+// Unwrap returns the underlying driver.
+func (d *AllowlistDriver) Unwrap() dialect.Driver { return d.Driver }
+
+// Unwrap returns the underlying transaction.
+func (tx *cacheTx) Unwrap() dialect.Tx { return tx.Tx }
+
+// Unwrap returns the underlying transaction.
+func (tx *memoTx) Unwrap() dialect.Tx { return tx.Tx }
+
+// Unwrap returns the underlying transaction.
+func (tx *stmtCacheTx) Unwrap() dialect.Tx { return tx.Tx }
@@ -0,0 +1,40 @@
+package driver
+
+import (
+	"testing"
+
+	"entgo.io/ent/dialect"
+	entsql "entgo.io/ent/dialect/sql"
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestAs(t *testing.T) {
+	db, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	drv := entsql.OpenDB(dialect.Postgres, db)
+	tests := []struct {
+		name string
+		drv  Driver
+	}{
+		{"debug", newDebugDriver(drv, nopLog)},
+		{"failover", NewFailoverDriver(drv, nopDriver{}, FailoverConfig{})},
+		{"dryrun", NewDryRunDriver(drv, DryRunConfig{})},
+		{"maintenance", NewMaintenanceDriver(drv, MaintenanceConfig{})},
+		{"allowlist", NewAllowlistDriver(drv, AllowlistConfig{})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got *entsql.Driver
+			if !As(newDebugDriver(tt.drv, nopLog), &got) || got != drv {
+				t.Errorf("As found %v, want the SQL driver", got)
+			}
+		})
+	}
+	var replica *ReplicaDriver
+	if As(newDebugDriver(drv, nopLog), &replica) {
+		t.Error("As found a driver missing from the chain")
+	}
+}
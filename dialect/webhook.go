@@ -0,0 +1,115 @@
+package driver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// WebhookConfig configures a WebhookSink.
+type WebhookConfig struct {
+	// URL is the endpoint the events are posted to. Required.
+	URL string
+	// Client is the HTTP client used to post the events. Defaults to a
+	// client with a 10s timeout.
+	Client *http.Client
+	// Header holds additional request headers, e.g. for authentication.
+	Header http.Header
+	// Filter selects the posted events. Defaults to the slow and failed
+	// statements, and the degradation events.
+	Filter func(*Event) bool
+	// Interval is the minimum time between two requests. Defaults to 5s.
+	Interval time.Duration
+	// BatchSize is the maximum number of events of a request. Defaults to 50.
+	BatchSize int
+	// MaxPending is the maximum number of events waiting to be posted.
+	// Events exceeding it are dropped. Defaults to 1000.
+	MaxPending int
+}
+
+// WebhookSink is a Sink that posts the selected events as JSON to a webhook,
+// in batches of the form {"events": [...]}. At most one request is sent per
+// interval, and events are dropped if the endpoint cannot keep up.
+type WebhookSink struct {
+	cfg WebhookConfig
+	b   *batcher
+}
+
+// NewWebhookSink returns a new WebhookSink and starts its sender.
+func NewWebhookSink(cfg WebhookConfig) *WebhookSink {
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	if cfg.Filter == nil {
+		cfg.Filter = notable
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = 5 * time.Second
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 50
+	}
+	if cfg.MaxPending <= 0 {
+		cfg.MaxPending = 1000
+	}
+	s := &WebhookSink{cfg: cfg}
+	s.b = newBatcher(cfg.Interval, cfg.BatchSize, cfg.MaxPending, s.send)
+	return s
+}
+
+// notable reports whether the event is a slow or failed statement, or a degradation event.
+func notable(e *Event) bool {
+	return e.Query != "" && (e.Slow || e.Err != nil) || e.Op == OpDegraded || e.Op == OpRecovered
+}
+
+// Write queues the event if it is selected by the filter. It returns the
+// error of the last failed request, if any, so that it gets logged.
+func (s *WebhookSink) Write(_ context.Context, e *Event) error {
+	if !s.cfg.Filter(e) {
+		return nil
+	}
+	return s.b.add(e)
+}
+
+// Dropped returns the number of events dropped because too many were pending.
+func (s *WebhookSink) Dropped() int64 {
+	return s.b.dropped.Load()
+}
+
+// Close stops the sender and posts the pending events.
+func (s *WebhookSink) Close() error {
+	return s.b.close()
+}
+
+// send posts the events to the webhook.
+func (s *WebhookSink) send(events []*Event) error {
+	body, err := json.Marshal(map[string]any{"events": events})
+	if err != nil {
+		return err
+	}
+	return post(s.cfg.Client, s.cfg.URL, s.cfg.Header, body, len(events))
+}
+
+// post posts the JSON body holding n events to the URL.
+func post(c *http.Client, url string, header http.Header, body []byte, n int) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.Do(req)
+	if err != nil {
+		return fmt.Errorf("entzlog: posting %d events: %w", n, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("entzlog: posting %d events: unexpected status %s", n, resp.Status)
+	}
+	return nil
+}
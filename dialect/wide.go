@@ -0,0 +1,44 @@
+package driver
+
+import (
+	"net/http"
+	"time"
+)
+
+// WideEvents returns an HTTP middleware aggregating the statements executed
+// with the context of each request, and passing them to emit as a single
+// wide event once the request is served. See WithRequestStats.
+func WideEvents(emit func(r *http.Request, fields map[string]any)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, stats := WithRequestStats(r.Context())
+			r = r.WithContext(ctx)
+			next.ServeHTTP(w, r)
+			emit(r, stats.Event())
+		})
+	}
+}
+
+// Event returns the stats as the flat fields of a wide event, with the
+// durations in milliseconds.
+func (s *RequestStats) Event() map[string]any {
+	fields := map[string]any{
+		"db.statements":   s.Statements(),
+		"db.duration_ms":  float64(s.Duration()) / float64(time.Millisecond),
+		"db.queries":      s.Queries(),
+		"db.rows":         s.Rows(),
+		"db.result_bytes": s.Bytes(),
+	}
+	if query, took := s.Slowest(); query != "" {
+		fields["db.slowest.fingerprint"] = Fingerprint(query)
+		fields["db.slowest.query"] = SanitizeQuery(query)
+		fields["db.slowest.duration_ms"] = float64(took) / float64(time.Millisecond)
+	}
+	var failed int64
+	for class, n := range s.Errors() {
+		fields["db.errors."+string(class)] = n
+		failed += n
+	}
+	fields["db.errors"] = failed
+	return fields
+}